@@ -0,0 +1,23 @@
+package timedcache
+
+// AddWithAccessHook adds a value to the cache along with a hook fired on every
+// Get style hit of the entry, such as to lazily refresh a resource depending on
+// it. Peeks and lookups of other keys do not fire it. The hook is invoked
+// outside of the critical section, after the lookup. It belongs to the entry,
+// so replacing the value of the key drops it.
+func (tc *TimedCache) AddWithAccessHook(key, value interface{}, onAccess func()) (evicted bool) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	seq := tc.seq
+	evicted = tc.add(key, value)
+
+	// Only hook the entry if it was actually inserted, not a rejected value's
+	if val, ok := tc.cache.Peek(key); ok && tc.seq != seq {
+		val.(*timedEntry).onAccess = onAccess
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return
+}
@@ -0,0 +1,58 @@
+package timedcache
+
+import "testing"
+
+func TestAddWithAccessHook(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+
+	var hooked, other int
+	tc.AddWithAccessHook("hooked", 1, func() { hooked++ })
+	tc.AddWithAccessHook("other", 2, func() { other++ })
+	tc.Add("plain", 3)
+
+	// The hook fires on every Get of its key only
+	for i := 1; i <= 3; i++ {
+		if _, ok := tc.Get("hooked"); !ok {
+			t.Fatalf("hooked entry missing")
+		}
+		if hooked != i || other != 0 {
+			t.Fatalf("get %d: hook counts mismatch: have %d and %d, want %d and 0", i, hooked, other, i)
+		}
+	}
+	tc.Get("plain")
+	tc.Get("missing")
+	// Peeks do not count as accesses
+	tc.Peek("hooked")
+	tc.Peek("other")
+	if hooked != 3 || other != 0 {
+		t.Fatalf("hook counts mismatch: have %d and %d, want 3 and 0", hooked, other)
+	}
+	// The hook may use the cache, as it runs outside of the critical section
+	tc.AddWithAccessHook("reentrant", 4, func() { tc.Add("touched", true) })
+	tc.Get("reentrant")
+	if _, ok := tc.Peek("touched"); !ok {
+		t.Fatalf("reentrant hook had no effect")
+	}
+	// Replacing the value drops the hook
+	tc.Add("hooked", 5)
+	tc.Get("hooked")
+	if hooked != 3 {
+		t.Fatalf("replaced entry fired the hook: %d calls", hooked)
+	}
+}
+
+func TestAddWithAccessHookRejected(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+
+	var kept, rejected int
+	tc.AddWithAccessHook("key", 1, func() { kept++ })
+
+	// A rejected value leaves the hook of the previous entry alone
+	tc.AddWithAccessHook("key", &timedEntry{}, func() { rejected++ })
+	if value, ok := tc.Get("key"); !ok || value != 1 {
+		t.Fatalf("previous value mismatch: have %v (present %v), want 1", value, ok)
+	}
+	if kept != 1 || rejected != 0 {
+		t.Fatalf("hook counts mismatch: have %d and %d, want 1 and 0", kept, rejected)
+	}
+}
@@ -0,0 +1,49 @@
+package timedcache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// WithAdaptiveTTL makes the lifetime of entries grow with their popularity. New
+// entries live for baseTTL, and every Get style hit extends the lifetime of an
+// entry to baseTTL times its number of hits since insertion, capped to maxTTL.
+// Entries read at most once thus keep expiring after baseTTL. Unlike refreshing
+// the TTL on access, the lifetime is counted from the insertion, not the hit.
+// The write TTL is capped to baseTTL. A maxTTL below baseTTL is raised to it.
+func (tc *TimedCache) WithAdaptiveTTL(baseTTL, maxTTL time.Duration) *TimedCache {
+	if maxTTL < baseTTL {
+		maxTTL = baseTTL
+	}
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.ttl = int64(baseTTL)
+	if tc.wttl > tc.ttl {
+		tc.wttl = tc.ttl
+	}
+	tc.maxTTL = int64(maxTTL)
+	return tc
+}
+
+// adapt accounts for a hit of an entry, extending its lifetime if adaptive TTL
+// is enabled. It must be called with the lock held.
+func (tc *timedCache) adapt(entry *timedEntry) {
+	entry.accessedAt = tc.now()
+	if tc.maxTTL == 0 {
+		return
+	}
+	entry.reads++
+
+	lifetime := tc.maxTTL
+	if tc.ttl > 0 && entry.reads < uint64(tc.maxTTL/tc.ttl) {
+		lifetime = tc.ttl * int64(entry.reads)
+	}
+	if expiresAt := entry.insertedAt + lifetime; expiresAt > entry.expiresAt {
+		entry.expiresAt = expiresAt
+		if entry.index >= 0 {
+			heap.Fix(&tc.expiry, entry.index)
+		}
+		tc.logOp(opExpiry, entry.key, entry)
+	}
+}
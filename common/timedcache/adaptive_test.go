@@ -0,0 +1,47 @@
+package timedcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTTL(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 100)
+	tc.WithAdaptiveTTL(10*time.Second, 40*time.Second)
+
+	tc.Add("hot", 1)
+	tc.Add("cold", 2)
+	expiresAt := func(key string) int64 {
+		val, _ := tc.cache.Peek(key)
+		return val.(*timedEntry).expiresAt / int64(time.Second)
+	}
+	if have := expiresAt("hot"); have != 1010 {
+		t.Fatalf("initial lifetime mismatch: have %d, want %d", have, 1010)
+	}
+	// Every hit grows the lifetime of a popular entry, up to the maximum
+	for i, want := range []int64{1010, 1020, 1030, 1040, 1040, 1040} {
+		if _, ok := tc.Get("hot"); !ok {
+			t.Fatalf("read %d: hot entry missing", i+1)
+		}
+		if have := expiresAt("hot"); have != want {
+			t.Fatalf("read %d: lifetime mismatch: have %d, want %d", i+1, have, want)
+		}
+		checkExpirySync(t, tc)
+	}
+	// A read once entry keeps the base lifetime
+	tc.Get("cold")
+	if have := expiresAt("cold"); have != 1010 {
+		t.Fatalf("read once lifetime mismatch: have %d, want %d", have, 1010)
+	}
+	clock.time = 1011
+	if _, ok := tc.Peek("cold"); ok {
+		t.Fatalf("read once entry outlived the base TTL")
+	}
+	if _, ok := tc.Peek("hot"); !ok {
+		t.Fatalf("popular entry expired at the base TTL")
+	}
+	clock.time = 1041
+	if _, ok := tc.Peek("hot"); ok {
+		t.Fatalf("popular entry outlived the maximum TTL")
+	}
+}
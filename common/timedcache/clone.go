@@ -0,0 +1,26 @@
+package timedcache
+
+// WithCloner makes lookups return a clone of the cached value, made by clone,
+// rather than the value itself, so that callers mutating returned slices or
+// maps cannot corrupt the cached copy. It applies to every accessor handing out
+// cached values, from Get and Peek to GetOrAdd and the entry listings of TopK,
+// ScanPrefix and SnapshotVersioned, but not to the eviction callbacks, whose
+// values have left the cache. Clones are made outside of the critical section.
+// By default values are returned by reference, which is cheaper.
+func (tc *TimedCache) WithCloner(clone func(value interface{}) interface{}) *TimedCache {
+	tc.lock.Lock()
+	tc.cloner = clone
+	tc.lock.Unlock()
+	return tc
+}
+
+// cloneEntries replaces the values of entries with clones made by clone, if a
+// cloner is configured. It must be called outside of the critical section.
+func cloneEntries(entries []Entry, clone func(interface{}) interface{}) {
+	if clone == nil {
+		return
+	}
+	for i := range entries {
+		entries[i].Value = clone(entries[i].Value)
+	}
+}
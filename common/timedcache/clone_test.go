@@ -0,0 +1,51 @@
+package timedcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloner(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.WithCloner(func(value interface{}) interface{} {
+		return append([]int(nil), value.([]int)...)
+	})
+	tc.Add("key", []int{1, 2, 3})
+
+	// Mutating returned values leaves the cached original untouched
+	value, _ := tc.Get("key")
+	value.([]int)[0] = 100
+	peeked, _ := tc.Peek("key")
+	peeked.([]int)[1] = 200
+	fresh, _, _ := tc.GetFresh("key")
+	fresh.([]int)[2] = 300
+	_, oldest, _ := tc.GetOldest()
+	oldest.([]int)[0] = 400
+	actual, _ := tc.GetOrAdd("key", nil, time.Second)
+	actual.([]int)[1] = 500
+	previous, _, _ := tc.PeekOrAdd("key", nil)
+	previous.([]int)[2] = 600
+	tc.TopK(1)[0].Value.([]int)[0] = 700
+	tc.ScanPrefix("k")[0].Value.([]int)[1] = 800
+	_, snapshot := tc.SnapshotVersioned()
+	snapshot[0].Value.([]int)[2] = 900
+
+	val, _ := tc.cache.Peek("key")
+	if cached := val.(*timedEntry).value.([]int); cached[0] != 1 || cached[1] != 2 || cached[2] != 3 {
+		t.Fatalf("cached value mutated through a returned clone: %v", cached)
+	}
+	// Values inserted by GetOrAdd are cloned as well
+	inserted := []int{1}
+	actual, _ = tc.GetOrAdd("new", inserted, time.Second)
+	actual.([]int)[0] = 100
+	if inserted[0] != 1 {
+		t.Fatalf("inserted value returned by reference")
+	}
+	// Without a cloner values are returned by reference
+	tc.WithCloner(nil)
+	value, _ = tc.Get("key")
+	value.([]int)[0] = 100
+	if value, _ = tc.Get("key"); value.([]int)[0] != 100 {
+		t.Fatalf("value not returned by reference")
+	}
+}
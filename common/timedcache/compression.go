@@ -0,0 +1,93 @@
+package timedcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"io/ioutil"
+)
+
+// CompressionCodec compresses the []byte values held by a cache.
+type CompressionCodec interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// FlateCodec is a CompressionCodec using the DEFLATE format at the given
+// compression level, see compress/flate.
+type FlateCodec struct {
+	Level int
+}
+
+// Compress implements CompressionCodec.
+func (c FlateCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, c.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress implements CompressionCodec.
+func (c FlateCodec) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// WithCompression makes the cache store []byte values compressed by codec,
+// decompressing them transparently on lookup, outside of the critical section.
+// Values of other types, and values which do not shrink, are stored as is.
+// Entries added before the call are left uncompressed. The compressed and
+// logical sizes of the values held are tracked in Stats.
+func (tc *TimedCache) WithCompression(codec CompressionCodec) *TimedCache {
+	tc.lock.Lock()
+	tc.codec = codec
+	tc.lock.Unlock()
+	return tc
+}
+
+// compress replaces the value of an entry with its compressed form, if
+// compression is configured and pays off. It must be called with the lock held.
+func (tc *timedCache) compress(entry *timedEntry) {
+	raw, ok := entry.value.([]byte)
+	if !ok || tc.codec == nil {
+		return
+	}
+	compressed, err := tc.codec.Compress(raw)
+	if err != nil || len(compressed) >= len(raw) {
+		return
+	}
+	entry.value, entry.codec, entry.logicalSize = compressed, tc.codec, len(raw)
+
+	tc.stats.LogicalBytes += uint64(len(raw))
+	tc.stats.CompressedBytes += uint64(len(compressed))
+}
+
+// releaseCompressed drops the sizes of a removed or replaced entry from the
+// compression stats. It must be called with the lock held.
+func (tc *timedCache) releaseCompressed(entry *timedEntry) {
+	if entry.codec != nil {
+		tc.stats.LogicalBytes -= uint64(entry.logicalSize)
+		tc.stats.CompressedBytes -= uint64(len(entry.value.([]byte)))
+	}
+}
+
+// logicalValue returns the value of an entry as it was added, decompressing it
+// if needed. It must be called with the lock held.
+func (tc *timedCache) logicalValue(entry *timedEntry) interface{} {
+	if entry.codec == nil {
+		return entry.value
+	}
+	raw, err := entry.codec.Decompress(entry.value.([]byte))
+	if err != nil {
+		return entry.value
+	}
+	return raw
+}
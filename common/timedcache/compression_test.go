@@ -0,0 +1,92 @@
+package timedcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.WithCompression(FlateCodec{Level: flate.BestCompression})
+
+	large := bytes.Repeat([]byte("quai"), 1024)
+	tc.Add("large", large)
+	tc.Add("small", []byte{0x01})
+	tc.Add("other", 42)
+
+	// Values round trip through every lookup
+	if value, ok := tc.Get("large"); !ok || !bytes.Equal(value.([]byte), large) {
+		t.Fatalf("get mismatch: have %x, %v", value, ok)
+	}
+	if value, ok := tc.Peek("large"); !ok || !bytes.Equal(value.([]byte), large) {
+		t.Fatalf("peek mismatch: have %x, %v", value, ok)
+	}
+	if value, _, ok := tc.GetFresh("large"); !ok || !bytes.Equal(value.([]byte), large) {
+		t.Fatalf("fresh get mismatch: have %x, %v", value, ok)
+	}
+	if value, ok := tc.Get("small"); !ok || !bytes.Equal(value.([]byte), []byte{0x01}) {
+		t.Fatalf("small value mismatch: have %x, %v", value, ok)
+	}
+	if value, ok := tc.Get("other"); !ok || value != 42 {
+		t.Fatalf("non byte value mismatch: have %v, %v", value, ok)
+	}
+	// Only the value which shrinks is stored compressed
+	val, _ := tc.cache.Peek("large")
+	if stored := val.(*timedEntry).value.([]byte); len(stored) >= len(large) {
+		t.Fatalf("stored size not reduced: have %d, logical %d", len(stored), len(large))
+	}
+	val, _ = tc.cache.Peek("small")
+	if val.(*timedEntry).codec != nil {
+		t.Fatalf("incompressible value stored compressed")
+	}
+	stats := tc.Stats()
+	if stats.LogicalBytes != uint64(len(large)) || stats.CompressedBytes == 0 || stats.CompressedBytes >= stats.LogicalBytes {
+		t.Fatalf("size stats mismatch: have %+v", stats)
+	}
+	// Removal releases the accounted sizes
+	tc.Remove("large")
+	if stats := tc.Stats(); stats.LogicalBytes != 0 || stats.CompressedBytes != 0 {
+		t.Fatalf("size stats not released: have %+v", stats)
+	}
+}
+
+func TestCompressionOverwrite(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.WithCompression(FlateCodec{Level: flate.BestCompression})
+
+	large := bytes.Repeat([]byte("quai"), 1024)
+	tc.Add("large", large)
+	want := tc.Stats()
+
+	// Overwriting a key accounts for the latest value only
+	for i := 0; i < 5; i++ {
+		tc.Add("large", large)
+	}
+	if stats := tc.Stats(); stats.LogicalBytes != want.LogicalBytes || stats.CompressedBytes != want.CompressedBytes {
+		t.Fatalf("size stats mismatch after overwrites: have %+v, want %+v", stats, want)
+	}
+	tc.Add("large", 42)
+	if stats := tc.Stats(); stats.LogicalBytes != 0 || stats.CompressedBytes != 0 {
+		t.Fatalf("size stats not released by uncompressed overwrite: have %+v", stats)
+	}
+}
+
+func TestCompressionOldest(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.WithCompression(FlateCodec{Level: flate.BestCompression})
+
+	large := bytes.Repeat([]byte("quai"), 1024)
+	tc.Add("large", large)
+	tc.Add("other", 42)
+
+	if key, value, ok := tc.GetOldest(); !ok || key != "large" || !bytes.Equal(value.([]byte), large) {
+		t.Fatalf("oldest get mismatch: have %v, %x, %v", key, value, ok)
+	}
+	if key, value, ok := tc.RemoveOldest(); !ok || key != "large" || !bytes.Equal(value.([]byte), large) {
+		t.Fatalf("oldest removal mismatch: have %v, %x, %v", key, value, ok)
+	}
+	if stats := tc.Stats(); stats.LogicalBytes != 0 || stats.CompressedBytes != 0 {
+		t.Fatalf("size stats not released: have %+v", stats)
+	}
+}
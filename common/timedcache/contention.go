@@ -0,0 +1,165 @@
+package timedcache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lockSamples is the number of most recent lock waits kept for percentiles.
+const lockSamples = 1024
+
+// LockStats summarizes how long acquisitions of the cache lock waited, as
+// tracked since WithContentionMetrics. Acquisitions which did not wait at all
+// count as waiting zero.
+type LockStats struct {
+	Acquisitions uint64 // Number of tracked lock acquisitions
+	Contended    uint64 // Number of acquisitions which had to wait
+
+	Min time.Duration // Shortest wait
+	Avg time.Duration // Mean wait
+	Max time.Duration // Longest wait
+	P99 time.Duration // 99th percentile of the most recent waits
+}
+
+// lockMetrics accumulates the waits of lock acquisitions.
+type lockMetrics struct {
+	lock      sync.Mutex
+	count     uint64
+	contended uint64
+	total     time.Duration
+	min, max  time.Duration
+	samples   []time.Duration // Ring buffer of the most recent waits
+	next      int             // Position of the next sample in the ring
+}
+
+// record accounts for a lock acquisition which waited for the given duration.
+func (m *lockMetrics) record(wait time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.count == 0 || wait < m.min {
+		m.min = wait
+	}
+	if wait > m.max {
+		m.max = wait
+	}
+	m.count++
+	if wait > 0 {
+		m.contended++
+	}
+	m.total += wait
+
+	if len(m.samples) < lockSamples {
+		m.samples = append(m.samples, wait)
+	} else {
+		m.samples[m.next] = wait
+		m.next = (m.next + 1) % lockSamples
+	}
+}
+
+// contendedLock is the cache lock, optionally timing how long acquisitions
+// wait for it. Uncontended acquisitions are detected with a try-lock and cost
+// no clock reads.
+type contendedLock struct {
+	sync.RWMutex
+	metrics atomic.Pointer[lockMetrics] // Wait statistics, nil if not tracked
+}
+
+// Lock acquires the lock for writing, recording the wait if tracked.
+func (l *contendedLock) Lock() {
+	metrics := l.metrics.Load()
+	if metrics == nil {
+		l.RWMutex.Lock()
+		return
+	}
+	if l.RWMutex.TryLock() {
+		metrics.record(0)
+		return
+	}
+	start := time.Now()
+	l.RWMutex.Lock()
+	metrics.record(time.Since(start))
+}
+
+// RLock acquires the lock for reading, recording the wait if tracked.
+func (l *contendedLock) RLock() {
+	metrics := l.metrics.Load()
+	if metrics == nil {
+		l.RWMutex.RLock()
+		return
+	}
+	if l.RWMutex.TryRLock() {
+		metrics.record(0)
+		return
+	}
+	start := time.Now()
+	l.RWMutex.RLock()
+	metrics.record(time.Since(start))
+}
+
+// lockUntil attempts to acquire the lock for writing until the deadline,
+// backing off between attempts, and returns whether it was acquired. The wait
+// of an acquisition is recorded if tracked, abandoned attempts are not.
+func (l *contendedLock) lockUntil(deadline time.Time) bool {
+	metrics := l.metrics.Load()
+	if l.RWMutex.TryLock() {
+		if metrics != nil {
+			metrics.record(0)
+		}
+		return true
+	}
+	start := time.Now()
+	for backoff := 10 * time.Microsecond; !l.RWMutex.TryLock(); {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return false
+		}
+		if wait > backoff {
+			wait = backoff
+		}
+		time.Sleep(wait)
+		if backoff < time.Millisecond {
+			backoff *= 2
+		}
+	}
+	if metrics != nil {
+		metrics.record(time.Since(start))
+	}
+	return true
+}
+
+// WithContentionMetrics starts tracking how long acquisitions of the cache lock
+// wait, as reported by LockStats, to tell when the single lock of the cache is
+// the bottleneck. Enabling it again resets the statistics.
+func (tc *TimedCache) WithContentionMetrics() *TimedCache {
+	tc.lock.metrics.Store(new(lockMetrics))
+	return tc
+}
+
+// LockStats returns the lock wait statistics tracked since contention metrics
+// were enabled, or zero statistics if they are not.
+func (tc *TimedCache) LockStats() LockStats {
+	metrics := tc.lock.metrics.Load()
+	if metrics == nil {
+		return LockStats{}
+	}
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+
+	if metrics.count == 0 {
+		return LockStats{}
+	}
+	samples := append([]time.Duration(nil), metrics.samples...)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	return LockStats{
+		Acquisitions: metrics.count,
+		Contended:    metrics.contended,
+		Min:          metrics.min,
+		Avg:          metrics.total / time.Duration(metrics.count),
+		Max:          metrics.max,
+		P99:          samples[(len(samples)*99)/100],
+	}
+}
@@ -0,0 +1,95 @@
+package timedcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContentionMetrics(t *testing.T) {
+	tc, _, _ := newTestCache(t, 100, 10)
+	if stats := tc.LockStats(); stats != (LockStats{}) {
+		t.Fatalf("untracked cache reported lock stats: %+v", stats)
+	}
+	tc.WithContentionMetrics()
+
+	// Uncontended acquisitions are tracked as zero waits
+	tc.Add("key", 0)
+	tc.Get("key")
+	if stats := tc.LockStats(); stats.Acquisitions != 2 || stats.Contended != 0 || stats.Max != 0 {
+		t.Fatalf("uncontended lock stats mismatch: have %+v", stats)
+	}
+	// Readers and writers queueing behind a slow transaction wait for it
+	var (
+		held = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tc.Transaction(func(tx *Txn) {
+			close(held)
+			time.Sleep(20 * time.Millisecond)
+		})
+	}()
+	<-held
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				tc.Add(i, i)
+			} else {
+				tc.Len()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := tc.LockStats()
+	if stats.Contended < 8 {
+		t.Fatalf("contended acquisitions mismatch: have %d, want at least 8", stats.Contended)
+	}
+	if stats.Max < 10*time.Millisecond || stats.P99 == 0 || stats.Avg == 0 {
+		t.Fatalf("lock waits not recorded: have %+v", stats)
+	}
+	if stats.Min > stats.Avg || stats.Avg > stats.Max || stats.P99 > stats.Max {
+		t.Fatalf("inconsistent lock stats: have %+v", stats)
+	}
+	// Enabling the metrics again resets them
+	tc.WithContentionMetrics()
+	if stats := tc.LockStats(); stats != (LockStats{}) {
+		t.Fatalf("lock stats not reset: have %+v", stats)
+	}
+}
+
+func TestContentionMetricsDeadline(t *testing.T) {
+	tc, _, _ := newTestCache(t, 100, 10)
+	tc.WithContentionMetrics()
+	tc.Add("key", 0)
+
+	// Deadline bound lookups are tracked like any other acquisition
+	tc.GetWithDeadline("key", time.Now().Add(time.Second))
+	if stats := tc.LockStats(); stats.Acquisitions != 2 || stats.Contended != 0 {
+		t.Fatalf("uncontended lock stats mismatch: have %+v", stats)
+	}
+	held := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		tc.Transaction(func(tx *Txn) {
+			close(held)
+			time.Sleep(20 * time.Millisecond)
+		})
+		close(done)
+	}()
+	<-held
+	if _, ok, timedOut := tc.GetWithDeadline("key", time.Now().Add(time.Second)); !ok || timedOut {
+		t.Fatalf("deadline bound lookup failed: ok %v, timed out %v", ok, timedOut)
+	}
+	<-done
+
+	stats := tc.LockStats()
+	if stats.Contended != 1 || stats.Max < 10*time.Millisecond {
+		t.Fatalf("deadline bound wait not recorded: have %+v", stats)
+	}
+}
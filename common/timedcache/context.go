@@ -0,0 +1,18 @@
+package timedcache
+
+import "context"
+
+// contextKey is the key request scoped caches are stored under in contexts.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying tc, for request scoped caching
+// without global state.
+func NewContext(ctx context.Context, tc *TimedCache) context.Context {
+	return context.WithValue(ctx, contextKey{}, tc)
+}
+
+// FromContext returns the cache carried by ctx, if any.
+func FromContext(ctx context.Context) (*TimedCache, bool) {
+	tc, ok := ctx.Value(contextKey{}).(*TimedCache)
+	return tc, ok && tc != nil
+}
@@ -0,0 +1,34 @@
+package timedcache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatalf("cache found in an empty context")
+	}
+	first, _, _ := newTestCache(t, 10, 10)
+	second, _, _ := newTestCache(t, 10, 10)
+	ctxA := NewContext(context.Background(), first)
+	ctxB := NewContext(context.Background(), second)
+
+	// Caches round trip through derived contexts
+	derived, cancel := context.WithCancel(ctxA)
+	defer cancel()
+	tc, ok := FromContext(derived)
+	if !ok || tc != first {
+		t.Fatalf("cache not carried by the context")
+	}
+	tc.Add("key", "a")
+
+	// Caches of other contexts are isolated
+	tc, ok = FromContext(ctxB)
+	if !ok || tc != second {
+		t.Fatalf("cache of the other context mismatch")
+	}
+	if _, ok := tc.Get("key"); ok {
+		t.Fatalf("entry leaked across contexts")
+	}
+}
@@ -0,0 +1,53 @@
+package timedcache
+
+// WithCostFunc switches eviction from pure LRU to Greedy-Dual-Size-Frequency:
+// each entry is scored by the cost to recompute it times the number of times
+// it was used, plus an inflation value aging out entries which are no longer
+// accessed. When the cache overflows, the entry with the lowest score is
+// evicted, the least recently used one among equals. Finding the victim takes
+// a scan of the cache, so costs should be reserved for small caches of
+// expensive entries.
+func (tc *TimedCache) WithCostFunc(cost func(key, value interface{}) float64) *TimedCache {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.costFn = cost
+	tc.inflation = 0
+	for _, key := range tc.cache.Keys() {
+		val, _ := tc.cache.Peek(key)
+		entry := val.(*timedEntry)
+		if cost != nil {
+			entry.cost = cost(key, tc.logicalValue(entry))
+		}
+		entry.freq = 0
+		tc.touch(entry)
+	}
+	return tc
+}
+
+// touch accounts for a use of the entry, raising its eviction score. It must
+// be called with the lock held.
+func (tc *timedCache) touch(entry *timedEntry) {
+	if tc.costFn == nil {
+		return
+	}
+	entry.freq++
+	entry.score = tc.inflation + float64(entry.freq)*entry.cost
+}
+
+// removeCheapest evicts the entry with the lowest score, returning whether
+// there was any. It must be called with the lock held.
+func (tc *timedCache) removeCheapest() bool {
+	var victim *timedEntry
+	for _, key := range tc.cache.Keys() {
+		val, _ := tc.cache.Peek(key)
+		if entry := val.(*timedEntry); victim == nil || entry.score < victim.score {
+			victim = entry
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	tc.inflation = victim.score
+	return tc.cache.Remove(victim.key)
+}
@@ -0,0 +1,58 @@
+package timedcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+// testCost charges keys prefixed with "expensive" a hundred times more than
+// any other key.
+func testCost(key, value interface{}) float64 {
+	if k, ok := key.(string); ok && len(k) >= 9 && k[:9] == "expensive" {
+		return 100
+	}
+	return 1
+}
+
+func TestCostAwareEviction(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 2, 10)
+	tc.WithCostFunc(testCost)
+
+	tc.Add("expensive", 1)
+	tc.Add("cheap", 2)
+	if !tc.Add("new", 3) {
+		t.Fatalf("overflowing insert reported no eviction")
+	}
+	// Pure LRU would have dropped the expensive entry, being the oldest
+	if want := []interface{}{"cheap"}; fmt.Sprint(*evicted) != fmt.Sprint(want) {
+		t.Fatalf("evicted keys mismatch: have %v, want %v", *evicted, want)
+	}
+	if !tc.Contains("expensive") || !tc.Contains("new") {
+		t.Fatalf("unexpected entries evicted, keys: %v", tc.Keys())
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestCostAwareEvictionFrequency(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 2, 10)
+	tc.WithCostFunc(testCost)
+
+	tc.Add("a", 1)
+	tc.Add("b", 2)
+	// Frequently used entries outlive equally cheap idle ones
+	for i := 0; i < 3; i++ {
+		tc.Get("a")
+	}
+	tc.Add("c", 3)
+	if want := []interface{}{"b"}; fmt.Sprint(*evicted) != fmt.Sprint(want) {
+		t.Fatalf("evicted keys mismatch: have %v, want %v", *evicted, want)
+	}
+	// Inflation ages out the hot entry once newcomers are worth more
+	for i := 0; i < 4; i++ {
+		tc.Add(fmt.Sprintf("d%d", i), i)
+	}
+	if tc.Contains("a") {
+		t.Fatalf("idle entry never aged out")
+	}
+	checkExpirySync(t, tc)
+}
@@ -0,0 +1,61 @@
+package timedcache
+
+import "sync"
+
+// lazyValue caches the decoded form of an encoded value, decoding it on first
+// access. It is shared by all readers of an entry.
+type lazyValue struct {
+	decoder func([]byte) (interface{}, error) // Decoder in place when the value was added
+
+	lock    sync.Mutex
+	done    bool
+	decoded interface{}
+}
+
+// decode returns the decoded value, running the decoder over the encoded bytes
+// returned by raw if no previous call succeeded. Failures are not cached, so
+// that the next access retries.
+func (lv *lazyValue) decode(raw func() ([]byte, error)) (interface{}, error) {
+	lv.lock.Lock()
+	defer lv.lock.Unlock()
+
+	if !lv.done {
+		encoded, err := raw()
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := lv.decoder(encoded)
+		if err != nil {
+			return nil, err
+		}
+		lv.decoded, lv.done = decoded, true
+	}
+	return lv.decoded, nil
+}
+
+// WithDecoder makes the cache decode []byte values lazily: they are stored
+// encoded, decoded by Get and GetDecoded on first access, and the decoded form
+// is shared by all later readers of the entry. Decoding happens outside of the
+// critical section. Other accessors, such as Peek and the eviction callback,
+// keep seeing the encoded bytes. Entries added before the decoder are not
+// affected.
+func (tc *TimedCache) WithDecoder(decoder func([]byte) (interface{}, error)) *TimedCache {
+	tc.lock.Lock()
+	tc.decoder = decoder
+	tc.lock.Unlock()
+	return tc
+}
+
+// GetDecoded is like Get, but surfaces decoding errors instead of reporting
+// them as cache misses. A failed decode leaves the entry cached, to be decoded
+// again on next access.
+func (tc *TimedCache) GetDecoded(key interface{}) (value interface{}, ok bool, err error) {
+	found, ok := tc.get(key, "")
+	if !ok {
+		return nil, false, nil
+	}
+	if value, err = found.resolve(); err != nil {
+		return nil, true, err
+	}
+	return value, true, nil
+}
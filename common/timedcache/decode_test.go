@@ -0,0 +1,91 @@
+package timedcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLazyDecoding(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+
+	decodes := 0
+	tc.WithDecoder(func(raw []byte) (interface{}, error) {
+		decodes++
+		return strconv.Atoi(string(raw))
+	})
+	tc.Add("good", []byte("42"))
+	tc.Add("bad", []byte("forty-two"))
+
+	// The decoder runs once, all readers sharing its result
+	for i := 0; i < 3; i++ {
+		value, ok := tc.Get("good")
+		if !ok || value != 42 {
+			t.Fatalf("decoded value mismatch: have %v ok=%v, want 42", value, ok)
+		}
+	}
+	if decodes != 1 {
+		t.Fatalf("decode count mismatch: have %d, want 1", decodes)
+	}
+	// Encoded bytes are still visible to peeks
+	if value, _ := tc.Peek("good"); string(value.([]byte)) != "42" {
+		t.Fatalf("peeked value mismatch: have %v, want encoded bytes", value)
+	}
+	// Decode errors surface and are retried rather than cached
+	for i := 0; i < 2; i++ {
+		if _, ok, err := tc.GetDecoded("bad"); !ok || err == nil {
+			t.Fatalf("decode error not surfaced: ok=%v err=%v", ok, err)
+		}
+	}
+	if decodes != 3 {
+		t.Fatalf("failed decode cached: have %d decodes, want 3", decodes)
+	}
+	if _, ok := tc.Get("bad"); ok {
+		t.Fatalf("undecodable value served")
+	}
+	// Overwriting the entry with a valid encoding recovers it
+	tc.Add("bad", []byte("7"))
+	if value, ok, err := tc.GetDecoded("bad"); !ok || err != nil || value != 7 {
+		t.Fatalf("recovered value mismatch: have %v ok=%v err=%v, want 7", value, ok, err)
+	}
+	// Values which are not encoded are returned as is
+	tc.Add("plain", 1)
+	if value, ok := tc.Get("plain"); !ok || value != 1 {
+		t.Fatalf("plain value mismatch: have %v ok=%v, want 1", value, ok)
+	}
+	if _, ok, err := tc.GetDecoded("missing"); ok || err != nil {
+		t.Fatalf("missing key mismatch: have ok=%v err=%v, want a plain miss", ok, err)
+	}
+}
+
+func TestLazyDecodingGetFresh(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.WithDecoder(func(raw []byte) (interface{}, error) {
+		return strconv.Atoi(string(raw))
+	})
+	tc.Add("good", []byte("42"))
+	tc.Add("bad", []byte("forty-two"))
+
+	if value, fresh, ok := tc.GetFresh("good"); !ok || !fresh || value != 42 {
+		t.Fatalf("decoded value mismatch: have %v fresh=%v ok=%v, want 42", value, fresh, ok)
+	}
+	if _, _, ok := tc.GetFresh("bad"); ok {
+		t.Fatalf("undecodable value served")
+	}
+}
+
+func TestLazyDecodingGetConsistent(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.WithDecoder(func(raw []byte) (interface{}, error) {
+		return strconv.Atoi(string(raw))
+	})
+	tc.Add("good", []byte("42"))
+	tc.Add("bad", []byte("forty-two"))
+
+	if value, ok := tc.GetConsistent("good", time.Second); !ok || value != 42 {
+		t.Fatalf("decoded value mismatch: have %v ok=%v, want 42", value, ok)
+	}
+	if _, ok := tc.GetConsistent("bad", time.Second); ok {
+		t.Fatalf("undecodable value served")
+	}
+}
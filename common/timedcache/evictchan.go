@@ -0,0 +1,44 @@
+package timedcache
+
+import "sync/atomic"
+
+// EvictedEntry is an entry which left the cache, as delivered by EvictedChan.
+type EvictedEntry struct {
+	Key    interface{}
+	Value  interface{}
+	Reason EvictReason // Why the entry left the cache
+}
+
+// WithEvictedChan makes every entry leaving the cache, for whatever reason, be
+// delivered to a channel of the given buffer size, returned by EvictedChan,
+// for pipelines processing evictions asynchronously. Deliveries never block
+// the cache: evictions finding the buffer full are dropped, and counted in the
+// EvictionsDropped statistic. The channel is never closed. A non-positive
+// buffer disables the channel.
+func (tc *TimedCache) WithEvictedChan(buffer int) *TimedCache {
+	tc.lock.Lock()
+	tc.evictedCh = nil
+	if buffer > 0 {
+		tc.evictedCh = make(chan EvictedEntry, buffer)
+	}
+	tc.lock.Unlock()
+	return tc
+}
+
+// EvictedChan returns the channel evictions are delivered to, or nil unless
+// enabled through WithEvictedChan.
+func (tc *TimedCache) EvictedChan() <-chan EvictedEntry {
+	tc.lock.RLock()
+	defer tc.lock.RUnlock()
+	return tc.evictedCh
+}
+
+// sendEvicted delivers an eviction to the channel unless its buffer is full,
+// in which case it is dropped.
+func (tc *timedCache) sendEvicted(sink chan EvictedEntry, evicted EvictedEntry) {
+	select {
+	case sink <- evicted:
+	default:
+		atomic.AddUint64(&tc.evictedDrops, 1)
+	}
+}
@@ -0,0 +1,59 @@
+package timedcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvictedChan(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 2, 10)
+	if tc.EvictedChan() != nil {
+		t.Fatalf("eviction channel enabled by default")
+	}
+	tc.WithEvictedChan(8)
+	evicted := tc.EvictedChan()
+
+	tc.Add("a", 1)
+	tc.Add("b", 2)
+	tc.Add("c", 3) // evicts a
+	tc.Remove("b")
+	clock.time += 11
+	tc.Len() // expires c
+
+	want := []EvictedEntry{
+		{Key: "a", Value: 1, Reason: EvictCapacity},
+		{Key: "b", Value: 2, Reason: EvictManual},
+		{Key: "c", Value: 3, Reason: EvictExpired},
+	}
+	for i, want := range want {
+		select {
+		case have := <-evicted:
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("eviction %d mismatch: have %+v, want %+v", i, have, want)
+			}
+		default:
+			t.Fatalf("eviction %d not delivered", i)
+		}
+	}
+}
+
+func TestEvictedChanFull(t *testing.T) {
+	tc, _, _ := newTestCache(t, 1, 10)
+	tc.WithEvictedChan(2)
+
+	// Evictions past the buffer are dropped instead of blocking the cache
+	for i := 0; i < 6; i++ {
+		tc.Add(i, i)
+	}
+	if have := len(tc.EvictedChan()); have != 2 {
+		t.Fatalf("buffered evictions mismatch: have %d, want %d", have, 2)
+	}
+	if have := tc.Stats().EvictionsDropped; have != 3 {
+		t.Fatalf("dropped evictions mismatch: have %d, want %d", have, 3)
+	}
+	for i := 0; i < 2; i++ {
+		if have := <-tc.EvictedChan(); have.Key != i {
+			t.Fatalf("eviction %d mismatch: have key %v", i, have.Key)
+		}
+	}
+}
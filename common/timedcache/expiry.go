@@ -0,0 +1,38 @@
+package timedcache
+
+// expiryQueue is a min-heap of cache entries ordered by their expiration time,
+// allowing expired entries to be swept in exact expiry order without scanning
+// the entire cache. Entries expiring together are ordered by insertion. It
+// implements heap.Interface.
+type expiryQueue []*timedEntry
+
+func (eq expiryQueue) Len() int { return len(eq) }
+
+func (eq expiryQueue) Less(i, j int) bool {
+	if eq[i].expiresAt != eq[j].expiresAt {
+		return eq[i].expiresAt < eq[j].expiresAt
+	}
+	return eq[i].seq < eq[j].seq
+}
+
+func (eq expiryQueue) Swap(i, j int) {
+	eq[i], eq[j] = eq[j], eq[i]
+	eq[i].index = i
+	eq[j].index = j
+}
+
+func (eq *expiryQueue) Push(x interface{}) {
+	entry := x.(*timedEntry)
+	entry.index = len(*eq)
+	*eq = append(*eq, entry)
+}
+
+func (eq *expiryQueue) Pop() interface{} {
+	old := *eq
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*eq = old[:n-1]
+	return entry
+}
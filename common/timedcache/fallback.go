@@ -0,0 +1,44 @@
+package timedcache
+
+// ReadOnly is the lookup side of a cache, such as a TimedCache or a plain LRU.
+type ReadOnly interface {
+	Get(key interface{}) (value interface{}, ok bool)
+}
+
+// WithFallback makes Get style lookups missing locally consult fallback, such
+// as a shared cache of a higher tier, before reporting a miss. Hits of the
+// fallback are promoted into this cache with its own ttl, unless a value was
+// inserted locally meanwhile. The local statistics still count the lookup as a
+// miss. The fallback must not lead back to this cache. A nil fallback disables
+// it.
+func (tc *TimedCache) WithFallback(fallback ReadOnly) *TimedCache {
+	tc.lock.Lock()
+	tc.fallback = fallback
+	tc.lock.Unlock()
+	return tc
+}
+
+// getFallback looks up a key missing locally in the fallback cache, promoting
+// a hit into the local cache.
+func (tc *timedCache) getFallback(key interface{}) (value interface{}, ok bool) {
+	tc.lock.RLock()
+	fallback := tc.fallback
+	tc.lock.RUnlock()
+
+	if fallback == nil {
+		return nil, false
+	}
+	if value, ok = fallback.Get(key); !ok {
+		return nil, false
+	}
+	tc.lock.Lock()
+	tc.removeExpired()
+	if _, live := tc.peek(key); !live {
+		tc.add(key, value)
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return value, true
+}
@@ -0,0 +1,44 @@
+package timedcache
+
+import "testing"
+
+func TestFallback(t *testing.T) {
+	shared, _, _ := newTestCache(t, 10, 100)
+	shared.Add("shared", "value")
+
+	local, clock, _ := newTestCache(t, 10, 10)
+	local.WithFallback(shared)
+
+	// A local miss is served by the fallback and promoted locally
+	if _, ok := local.Peek("shared"); ok {
+		t.Fatalf("key cached locally before the lookup")
+	}
+	if have, ok := local.Get("shared"); !ok || have != "value" {
+		t.Fatalf("fallback hit mismatch: have %v (found %v), want %v", have, ok, "value")
+	}
+	if have, ok := local.Peek("shared"); !ok || have != "value" {
+		t.Fatalf("fallback hit not promoted: have %v (found %v)", have, ok)
+	}
+	if stats := local.Stats(); stats.Misses != 1 || stats.Hits != 0 {
+		t.Fatalf("local stats mismatch: have %+v, want one miss", stats)
+	}
+	checkExpirySync(t, local)
+
+	// Promoted entries live for the local ttl
+	clock.time += 11
+	if _, ok := local.Peek("shared"); ok {
+		t.Fatalf("promoted entry outlived the local ttl")
+	}
+	// Keys missing from both caches miss
+	if _, ok := local.Get("missing"); ok {
+		t.Fatalf("missing key hit")
+	}
+	if local.Len() != 0 {
+		t.Fatalf("miss promoted into the local cache")
+	}
+	// Without a fallback, local misses are final
+	local.WithFallback(nil)
+	if _, ok := local.Get("shared"); ok {
+		t.Fatalf("disabled fallback consulted")
+	}
+}
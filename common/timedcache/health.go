@@ -0,0 +1,50 @@
+package timedcache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when operating on a cache which has been closed.
+	ErrClosed = errors.New("timed cache closed")
+
+	// ErrUnresponsive is returned by HealthCheck if the cache lock could not be
+	// acquired in time, hinting at a wedged cache.
+	ErrUnresponsive = errors.New("timed cache unresponsive")
+
+	// ErrSweeperStopped is returned by HealthCheck if the sweeper the cache is
+	// registered with was closed or has not swept in a while.
+	ErrSweeperStopped = errors.New("timed cache sweeper stopped")
+)
+
+// healthCheckTimeout is the time HealthCheck waits for the cache lock.
+var healthCheckTimeout = time.Second
+
+// HealthCheck verifies that the cache is operable, for use by readiness probes.
+// It fails with ErrClosed once the cache has been closed, which also stops its
+// background workers, and with ErrUnresponsive if the lock cannot be acquired
+// within a second. It also checks that the LRU and the expiry queue agree on
+// the cache contents and, if the cache is registered with a sweeper, that the
+// sweeper is still sweeping, failing with ErrSweeperStopped otherwise.
+func (tc *TimedCache) HealthCheck() error {
+	if tc.closed() {
+		return ErrClosed
+	}
+	// Give up on a wedged lock rather than leave a goroutine blocked on it
+	if !tc.lock.lockUntil(time.Now().Add(healthCheckTimeout)) {
+		return ErrUnresponsive
+	}
+	entries, queued := tc.cache.Len(), len(tc.expiry)
+	sweeper := tc.sweeper
+	tc.lock.Unlock()
+
+	if entries != queued {
+		return fmt.Errorf("timed cache inconsistent: %d entries, %d queued for expiry", entries, queued)
+	}
+	if sweeper != nil {
+		return sweeper.alive()
+	}
+	return nil
+}
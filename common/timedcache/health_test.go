@@ -0,0 +1,67 @@
+package timedcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.Add("key", "value")
+	if err := tc.HealthCheck(); err != nil {
+		t.Fatalf("healthy cache failed check: %v", err)
+	}
+	// A wedged lock is reported as unresponsive
+	defer func(timeout time.Duration) { healthCheckTimeout = timeout }(healthCheckTimeout)
+	healthCheckTimeout = 10 * time.Millisecond
+
+	tc.lock.Lock()
+	err := tc.HealthCheck()
+	tc.lock.Unlock()
+	if err != ErrUnresponsive {
+		t.Fatalf("wedged cache error mismatch: have %v, want %v", err, ErrUnresponsive)
+	}
+	// A closed cache is reported as such
+	tc.Close()
+	if err := tc.HealthCheck(); err != ErrClosed {
+		t.Fatalf("closed cache error mismatch: have %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestHealthCheckSweeper(t *testing.T) {
+	defer func(timeout time.Duration) { healthCheckTimeout = timeout }(healthCheckTimeout)
+	healthCheckTimeout = 10 * time.Millisecond
+
+	s := NewSweeper(5 * time.Millisecond)
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.RegisterWithSweeper(s)
+	time.Sleep(20 * time.Millisecond)
+	if err := tc.HealthCheck(); err != nil {
+		t.Fatalf("swept cache failed check: %v", err)
+	}
+	// A sweeper blocked on another wedged cache is reported
+	wedged, _, _ := newTestCache(t, 10, 10)
+	wedged.RegisterWithSweeper(s)
+	wedged.lock.Lock()
+	time.Sleep(50 * time.Millisecond)
+	err := tc.HealthCheck()
+	wedged.lock.Unlock()
+	if !errors.Is(err, ErrSweeperStopped) {
+		t.Fatalf("stalled sweeper error mismatch: have %v, want %v", err, ErrSweeperStopped)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := tc.HealthCheck(); err != nil {
+		t.Fatalf("recovered sweeper failed check: %v", err)
+	}
+	// A closed sweeper is reported, until the cache is unregistered from it
+	s.Close()
+	<-s.done
+	if err := tc.HealthCheck(); err != ErrSweeperStopped {
+		t.Fatalf("closed sweeper error mismatch: have %v, want %v", err, ErrSweeperStopped)
+	}
+	s.Unregister(tc)
+	if err := tc.HealthCheck(); err != nil {
+		t.Fatalf("unregistered cache failed check: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package timedcache
+
+import (
+	"sort"
+	"time"
+)
+
+// KeyCount is a cached key along with its number of hits.
+type KeyCount struct {
+	Key   interface{}
+	Count uint64
+}
+
+// hotKeys counts the hits of live keys over a rolling window, made up of the
+// current and the previous window generations. Only keys present in the cache
+// are tracked, bounding its memory by the cache size.
+type hotKeys struct {
+	window int64                  // Length of a window generation, in nanoseconds
+	start  int64                  // Unix time the current generation started at
+	cur    map[interface{}]uint64 // Hits in the current generation
+	prev   map[interface{}]uint64 // Hits in the previous generation
+}
+
+// rotate moves to a fresh generation if the current one is over.
+func (h *hotKeys) rotate(now int64) {
+	if now < h.start+h.window {
+		return
+	}
+	if now < h.start+2*h.window {
+		h.prev = h.cur
+	} else {
+		h.prev = make(map[interface{}]uint64)
+	}
+	h.cur = make(map[interface{}]uint64)
+	h.start = now
+}
+
+// hit records an access to a key. A nil tracker ignores it.
+func (h *hotKeys) hit(key interface{}, now int64) {
+	if h == nil {
+		return
+	}
+	h.rotate(now)
+	h.cur[key]++
+}
+
+// forget stops tracking a key which left the cache. A nil tracker ignores it.
+func (h *hotKeys) forget(key interface{}) {
+	if h == nil {
+		return
+	}
+	delete(h.cur, key)
+	delete(h.prev, key)
+}
+
+// WithHotKeyTracking enables counting the hits of each live key over a rolling
+// window of between one and two times the given length, reported by HotKeys.
+func (tc *TimedCache) WithHotKeyTracking(window time.Duration) *TimedCache {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.hot = &hotKeys{
+		window: int64(window),
+		start:  tc.now(),
+		cur:    make(map[interface{}]uint64),
+		prev:   make(map[interface{}]uint64),
+	}
+	return tc
+}
+
+// HotKeys returns up to n of the live keys with the most hits in the rolling
+// window of the hot key tracker, most hit first. Keys with equal hits are
+// ordered from the most recently used. Returns nil if tracking is disabled, and
+// no keys if n is not positive.
+func (tc *TimedCache) HotKeys(n int) []KeyCount {
+	tc.lock.Lock()
+	var counts []KeyCount
+	if tc.hot != nil {
+		tc.removeExpired()
+		tc.hot.rotate(tc.now())
+
+		keys := tc.cache.Keys()
+		for i := len(keys) - 1; i >= 0; i-- {
+			if count := tc.hot.cur[keys[i]] + tc.hot.prev[keys[i]]; count > 0 {
+				counts = append(counts, KeyCount{Key: keys[i], Count: count})
+			}
+		}
+		sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+		if n < 0 {
+			n = 0
+		}
+		if n < len(counts) {
+			counts = counts[:n]
+		}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return counts
+}
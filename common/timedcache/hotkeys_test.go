@@ -0,0 +1,51 @@
+package timedcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHotKeys(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 100)
+	if tc.HotKeys(3) != nil {
+		t.Fatalf("hot keys reported while tracking is disabled")
+	}
+	tc.WithHotKeyTracking(10 * time.Second)
+
+	for i := 0; i < 5; i++ {
+		tc.Add(i, i)
+	}
+	// Skewed access: key 3 is the hottest, then 1, then 4
+	for key, hits := range map[int]int{3: 10, 1: 5, 4: 2, 0: 1} {
+		for i := 0; i < hits; i++ {
+			tc.Get(key)
+		}
+	}
+	want := []KeyCount{{3, 10}, {1, 5}, {4, 2}}
+	if have := tc.HotKeys(3); fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Fatalf("hot keys mismatch: have %v, want %v", have, want)
+	}
+	if have := tc.HotKeys(-1); len(have) != 0 {
+		t.Fatalf("hot keys reported for negative n: have %v", have)
+	}
+	// Evicted keys are no longer reported
+	tc.Remove(3)
+	if have := tc.HotKeys(1); len(have) != 1 || have[0].Key != 1 {
+		t.Fatalf("hot keys mismatch after removal: have %v", have)
+	}
+	// Hits fall out of the rolling window with time
+	clock.time += 10
+	tc.Get(4)
+	if have := tc.HotKeys(1); fmt.Sprint(have) != fmt.Sprint([]KeyCount{{1, 5}}) {
+		t.Fatalf("hot keys mismatch in next window: have %v", have)
+	}
+	clock.time += 10
+	if have := tc.HotKeys(5); fmt.Sprint(have) != fmt.Sprint([]KeyCount{{4, 1}}) {
+		t.Fatalf("hot keys mismatch after window rolled: have %v", have)
+	}
+	clock.time += 20
+	if have := tc.HotKeys(5); len(have) != 0 {
+		t.Fatalf("stale hot keys reported: have %v", have)
+	}
+}
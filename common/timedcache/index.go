@@ -0,0 +1,56 @@
+package timedcache
+
+// WithSecondaryIndex maintains an index from an attribute of the cached values,
+// as extracted by attr, to their primary keys, allowing lookups through
+// GetBySecondary. If multiple live entries share an attribute, the index points
+// to the most recently added one. Once that entry leaves the cache, the
+// attribute is unindexed until an entry carrying it is added again, even if
+// older entries sharing it are still cached.
+func (tc *TimedCache) WithSecondaryIndex(attr func(value interface{}) interface{}) *TimedCache {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.indexFn = attr
+	tc.secondary = nil
+	if attr != nil {
+		tc.secondary = make(map[interface{}]interface{})
+		for _, key := range tc.cache.Keys() {
+			val, _ := tc.cache.Peek(key)
+			tc.reindex(key, tc.logicalValue(val.(*timedEntry)))
+		}
+	}
+	return tc
+}
+
+// GetBySecondary looks up a value by its secondary attribute, updating the
+// recent-ness of its entry like Get does.
+func (tc *TimedCache) GetBySecondary(attr interface{}) (value interface{}, ok bool) {
+	tc.lock.RLock()
+	key, indexed := tc.secondary[attr]
+	tc.lock.RUnlock()
+	if !indexed {
+		return nil, false
+	}
+	return tc.Get(key)
+}
+
+// reindex points the secondary attribute of value to key. It must be called
+// with the lock held.
+func (tc *timedCache) reindex(key, value interface{}) {
+	if tc.indexFn == nil {
+		return
+	}
+	tc.secondary[tc.indexFn(value)] = key
+}
+
+// unindex drops the secondary attribute of an entry, if it still points to
+// key. It must be called with the lock held.
+func (tc *timedCache) unindex(key interface{}, entry *timedEntry) {
+	if tc.indexFn == nil {
+		return
+	}
+	attr := tc.indexFn(tc.logicalValue(entry))
+	if indexed, ok := tc.secondary[attr]; ok && indexed == key {
+		delete(tc.secondary, attr)
+	}
+}
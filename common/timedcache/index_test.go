@@ -0,0 +1,71 @@
+package timedcache
+
+import "testing"
+
+// testBlock is a value indexed by its number as well as its hash.
+type testBlock struct {
+	hash   string
+	number uint64
+}
+
+func blockNumber(value interface{}) interface{} {
+	return value.(testBlock).number
+}
+
+func TestSecondaryIndex(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 3, 10)
+	tc.WithSecondaryIndex(blockNumber)
+
+	blocks := []testBlock{{"a", 1}, {"b", 2}, {"c", 3}}
+	for _, block := range blocks {
+		tc.Add(block.hash, block)
+	}
+	for _, block := range blocks {
+		if value, ok := tc.Get(block.hash); !ok || value != block {
+			t.Errorf("primary lookup of %s mismatch: have %v", block.hash, value)
+		}
+		if value, ok := tc.GetBySecondary(block.number); !ok || value != block {
+			t.Errorf("secondary lookup of %d mismatch: have %v", block.number, value)
+		}
+	}
+	// Evicted entries disappear from the secondary index
+	tc.Add("d", testBlock{"d", 4})
+	if _, ok := tc.GetBySecondary(uint64(1)); ok {
+		t.Fatalf("evicted entry still indexed")
+	}
+	// Removed and expired entries too
+	tc.Remove("b")
+	if _, ok := tc.GetBySecondary(uint64(2)); ok {
+		t.Fatalf("removed entry still indexed")
+	}
+	clock.time += 11
+	tc.Len()
+	if len(tc.secondary) != 0 {
+		t.Fatalf("expired entries still indexed: %v", tc.secondary)
+	}
+}
+
+func TestSecondaryIndexCollision(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.WithSecondaryIndex(blockNumber)
+
+	// Sibling blocks at the same height resolve to the newest one
+	tc.Add("a", testBlock{"a", 1})
+	tc.Add("b", testBlock{"b", 1})
+	if value, _ := tc.GetBySecondary(uint64(1)); value != (testBlock{"b", 1}) {
+		t.Fatalf("collision resolved to %v, want newest", value)
+	}
+	// Dropping an older sibling keeps the index intact
+	tc.Remove("a")
+	if value, _ := tc.GetBySecondary(uint64(1)); value != (testBlock{"b", 1}) {
+		t.Fatalf("index lost after removing older sibling: %v", value)
+	}
+	// Replacing a value moves its entry to the new attribute
+	tc.Add("b", testBlock{"b", 2})
+	if _, ok := tc.GetBySecondary(uint64(1)); ok {
+		t.Fatalf("stale attribute still indexed after replacement")
+	}
+	if value, _ := tc.GetBySecondary(uint64(2)); value != (testBlock{"b", 2}) {
+		t.Fatalf("replacement not indexed: %v", value)
+	}
+}
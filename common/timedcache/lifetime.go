@@ -0,0 +1,69 @@
+package timedcache
+
+import (
+	"math/bits"
+	"time"
+)
+
+// lifetimeBuckets is the number of power of two buckets lifetimes are sorted
+// into, the last one also holding all longer lifetimes.
+const lifetimeBuckets = 20
+
+// lifetimes accumulates the time removed entries lived.
+type lifetimes struct {
+	count   uint64
+	total   uint64 // Sum of the lifetimes in microseconds, not to overflow
+	buckets [lifetimeBuckets]uint64
+}
+
+// record accounts for the removal of an entry which lived for the given number
+// of nanoseconds.
+func (l *lifetimes) record(lifetime int64) {
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	bucket := bits.Len64(uint64(lifetime / int64(time.Second)))
+	if bucket >= lifetimeBuckets {
+		bucket = lifetimeBuckets - 1
+	}
+	l.count++
+	l.total += uint64(lifetime / int64(time.Microsecond))
+	l.buckets[bucket]++
+}
+
+// LifetimeBucket counts the removed entries which lived at least Min, and less
+// than the Min of the next bucket.
+type LifetimeBucket struct {
+	Min   time.Duration
+	Count uint64
+}
+
+// AvgLifetime returns the average time entries lived until their removal, by
+// any cause, or zero if none was removed yet. An average well below the TTL
+// points to a cache too small for its working set, one close to it shows that
+// expiry drives the removals.
+func (tc *TimedCache) AvgLifetime() time.Duration {
+	tc.lock.RLock()
+	defer tc.lock.RUnlock()
+
+	if tc.lifetimes.count == 0 {
+		return 0
+	}
+	return time.Duration(tc.lifetimes.total/tc.lifetimes.count) * time.Microsecond
+}
+
+// LifetimeDistribution returns the number of removed entries by lifetime, in
+// power of two second buckets from the shortest to the longest lifetimes.
+func (tc *TimedCache) LifetimeDistribution() []LifetimeBucket {
+	tc.lock.RLock()
+	defer tc.lock.RUnlock()
+
+	buckets := make([]LifetimeBucket, lifetimeBuckets)
+	for i := range buckets {
+		if i > 0 {
+			buckets[i].Min = time.Duration(1<<(i-1)) * time.Second
+		}
+		buckets[i].Count = tc.lifetimes.buckets[i]
+	}
+	return buckets
+}
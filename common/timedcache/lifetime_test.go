@@ -0,0 +1,39 @@
+package timedcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAvgLifetime(t *testing.T) {
+	// A cache too small for its working set evicts entries right away
+	small, clock, _ := newTestCache(t, 2, 60)
+	for i := 0; i < 20; i++ {
+		small.Add(i, i)
+		clock.time++
+	}
+	if have := small.AvgLifetime(); have > 2*time.Second {
+		t.Fatalf("small cache lifetime mismatch: have %v, want at most 2s", have)
+	}
+	// A spacious cache lets entries live until their TTL
+	spacious, clock, _ := newTestCache(t, 100, 60)
+	for i := 0; i < 20; i++ {
+		spacious.Add(i, i)
+	}
+	clock.time += 61
+	spacious.Len()
+	if have := spacious.AvgLifetime(); have != 61*time.Second {
+		t.Fatalf("spacious cache lifetime mismatch: have %v, want %v", have, 61*time.Second)
+	}
+	buckets := spacious.LifetimeDistribution()
+	if buckets[6].Min != 32*time.Second || buckets[6].Count != 20 {
+		t.Fatalf("lifetime bucket mismatch: have %+v, want 20 entries from 32s", buckets[6])
+	}
+	var total uint64
+	for _, bucket := range buckets {
+		total += bucket.Count
+	}
+	if total != 20 {
+		t.Fatalf("lifetime count mismatch: have %d, want 20", total)
+	}
+}
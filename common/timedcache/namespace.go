@@ -0,0 +1,86 @@
+package timedcache
+
+// NamespacedKey is a cache key scoped to a namespace, allowing the number of
+// entries per namespace to be limited with WithNamespaceQuota.
+type NamespacedKey struct {
+	Namespace string
+	Key       interface{}
+}
+
+// namespaceOf returns the namespace of a key, if it is namespaced.
+func namespaceOf(key interface{}) (string, bool) {
+	if nk, ok := key.(NamespacedKey); ok {
+		return nk.Namespace, true
+	}
+	return "", false
+}
+
+// WithNamespaceQuota limits the number of entries each of the given namespaces
+// may hold. When a namespace exceeds its quota, its own oldest entry is evicted
+// instead of the globally oldest one. Namespaces without a quota are only bound
+// by the cache size.
+func (tc *TimedCache) WithNamespaceQuota(quotas map[string]int) *TimedCache {
+	tc.lock.Lock()
+	tc.nsQuota = make(map[string]int, len(quotas))
+	for ns, quota := range quotas {
+		tc.nsQuota[ns] = quota
+	}
+	tc.nsCount = make(map[string]int)
+	for _, key := range tc.cache.Keys() {
+		if ns, ok := namespaceOf(key); ok {
+			tc.nsCount[ns]++
+		}
+	}
+	// Trim any namespace already above its new quota
+	for ns := range tc.nsQuota {
+		for tc.nsCount[ns] > tc.nsQuota[ns] && tc.removeOldestIn(ns) {
+		}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return tc
+}
+
+// reserveNamespace makes room for a new key in its namespace, evicting the
+// oldest entry of the namespace if it is at its quota. It must be called with
+// the lock held.
+func (tc *timedCache) reserveNamespace(key interface{}) {
+	if tc.nsQuota == nil {
+		return
+	}
+	ns, ok := namespaceOf(key)
+	if !ok {
+		return
+	}
+	if quota, ok := tc.nsQuota[ns]; ok {
+		for tc.nsCount[ns] >= quota && tc.removeOldestIn(ns) {
+		}
+	}
+	tc.nsCount[ns]++
+}
+
+// releaseNamespace accounts for a key leaving the cache. It must be called
+// with the lock held.
+func (tc *timedCache) releaseNamespace(key interface{}) {
+	if tc.nsQuota == nil {
+		return
+	}
+	if ns, ok := namespaceOf(key); ok {
+		if tc.nsCount[ns]--; tc.nsCount[ns] <= 0 {
+			delete(tc.nsCount, ns)
+		}
+	}
+}
+
+// removeOldestIn removes the least recently used entry of a namespace,
+// returning whether there was any to remove.
+func (tc *timedCache) removeOldestIn(ns string) bool {
+	for _, key := range tc.cache.Keys() {
+		if keyNs, ok := namespaceOf(key); ok && keyNs == ns {
+			return tc.cache.Remove(key)
+		}
+	}
+	return false
+}
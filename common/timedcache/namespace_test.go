@@ -0,0 +1,53 @@
+package timedcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNamespaceQuota(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 10, 10)
+	tc.WithNamespaceQuota(map[string]int{"small": 2})
+
+	for i := 0; i < 3; i++ {
+		tc.Add(NamespacedKey{"large", i}, i)
+		tc.Add(NamespacedKey{"small", i}, i)
+	}
+	tc.Add("plain", 0)
+
+	// Only the oldest entry of the namespace over quota is evicted
+	if want := []interface{}{NamespacedKey{"small", 0}}; fmt.Sprint(*evicted) != fmt.Sprint(want) {
+		t.Fatalf("evicted keys mismatch: have %v, want %v", *evicted, want)
+	}
+	for i := 0; i < 3; i++ {
+		if !tc.Contains(NamespacedKey{"large", i}) {
+			t.Errorf("unlimited namespace entry %d evicted", i)
+		}
+	}
+	if have := tc.Len(); have != 6 {
+		t.Fatalf("cache length mismatch: have %d, want 6", have)
+	}
+	// Replacing an entry does not count against the quota
+	tc.Add(NamespacedKey{"small", 2}, 20)
+	if !tc.Contains(NamespacedKey{"small", 1}) {
+		t.Fatalf("replacing an entry evicted a namespace sibling")
+	}
+	// Removing an entry frees up quota
+	tc.Remove(NamespacedKey{"small", 1})
+	tc.Add(NamespacedKey{"small", 3}, 3)
+	if !tc.Contains(NamespacedKey{"small", 2}) || !tc.Contains(NamespacedKey{"small", 3}) {
+		t.Fatalf("namespace entries evicted below quota")
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestNamespaceQuotaTrim(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	for i := 0; i < 5; i++ {
+		tc.Add(NamespacedKey{"ns", i}, i)
+	}
+	tc.WithNamespaceQuota(map[string]int{"ns": 2})
+	if have := tc.Keys(); fmt.Sprint(have) != fmt.Sprint([]interface{}{NamespacedKey{"ns", 3}, NamespacedKey{"ns", 4}}) {
+		t.Fatalf("trimmed keys mismatch: have %v", have)
+	}
+}
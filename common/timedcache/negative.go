@@ -0,0 +1,65 @@
+package timedcache
+
+import "github.com/hashicorp/golang-lru/simplelru"
+
+// WithNegativeCapacity enables negative caching, remembering up to n keys known
+// to be missing, see AddNegative. Negative entries are bounded and evicted in
+// their own LRU, so a flood of lookups for missing keys cannot evict positive
+// entries. Previously recorded negative entries are dropped.
+func (tc *TimedCache) WithNegativeCapacity(n int) (*TimedCache, error) {
+	negative, err := simplelru.NewLRU(n, nil)
+	if err != nil {
+		return nil, err
+	}
+	tc.lock.Lock()
+	tc.negative = negative
+	tc.lock.Unlock()
+	return tc, nil
+}
+
+// AddNegative records that a key is known to be missing, for the cache's ttl,
+// removing any live entry of it. Adding a value for the key drops the negative
+// entry. It is a no-op unless negative caching is enabled, or once closed.
+func (tc *TimedCache) AddNegative(key interface{}) {
+	tc.lock.Lock()
+	if tc.negative != nil && !tc.closed() {
+		tc.removeFor(key, EvictManual)
+		tc.negative.Add(key, tc.calcExpireTime(tc.ttl))
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+}
+
+// IsNegative returns whether a key is known to be missing, that is whether it
+// has a live negative entry, marking it as recently used.
+func (tc *TimedCache) IsNegative(key interface{}) bool {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	if tc.negative == nil {
+		return false
+	}
+	val, ok := tc.negative.Get(key)
+	if !ok {
+		return false
+	}
+	if val.(int64) < tc.now() {
+		tc.negative.Remove(key)
+		return false
+	}
+	return true
+}
+
+// NegativeLen returns the number of negative entries, including expired ones
+// not yet dropped.
+func (tc *TimedCache) NegativeLen() int {
+	tc.lock.RLock()
+	defer tc.lock.RUnlock()
+
+	if tc.negative == nil {
+		return 0
+	}
+	return tc.negative.Len()
+}
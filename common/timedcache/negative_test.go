@@ -0,0 +1,43 @@
+package timedcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNegativeCapacity(t *testing.T) {
+	tc, clock, evicted := newTestCache(t, 4, 10)
+	if _, err := tc.WithNegativeCapacity(8); err != nil {
+		t.Fatalf("failed to enable negative caching: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		tc.Add(i, i)
+	}
+	// Flooding the negative entries past their capacity leaves positive ones be
+	for i := 0; i < 20; i++ {
+		tc.AddNegative(fmt.Sprintf("missing-%d", i))
+	}
+	if have := tc.NegativeLen(); have != 8 {
+		t.Fatalf("negative entry count mismatch: have %d, want 8", have)
+	}
+	if have := tc.Len(); have != 4 || len(*evicted) != 0 {
+		t.Fatalf("positive entries affected: have %d live, evicted %v", have, *evicted)
+	}
+	if tc.IsNegative("missing-0") || !tc.IsNegative("missing-19") {
+		t.Fatalf("negative entries not evicted oldest first")
+	}
+	// A negative entry replaces a positive one, and vice versa
+	tc.AddNegative(0)
+	if _, ok := tc.Get(0); ok || !tc.IsNegative(0) {
+		t.Fatalf("negative entry did not replace the positive one")
+	}
+	tc.Add(0, 0)
+	if _, ok := tc.Get(0); !ok || tc.IsNegative(0) {
+		t.Fatalf("positive entry did not replace the negative one")
+	}
+	// Negative entries expire with the cache ttl
+	clock.time += 11
+	if tc.IsNegative("missing-19") {
+		t.Fatalf("negative entry outlived the ttl")
+	}
+}
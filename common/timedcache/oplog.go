@@ -0,0 +1,123 @@
+package timedcache
+
+import (
+	"container/heap"
+	"encoding/json"
+	"io"
+)
+
+const (
+	opAdd    = "add"
+	opRemove = "remove"
+	opExpiry = "expiry" // Lifetime change of a live entry, see RefreshTTL and WithAdaptiveTTL
+)
+
+// opRecord is a single serialized cache operation of the operation log.
+type opRecord struct {
+	Op         string      `json:"op"`
+	Key        interface{} `json:"key"`
+	Value      interface{} `json:"value,omitempty"`
+	Time       int64       `json:"time"`
+	ExpiresAt  int64       `json:"expiresAt,omitempty"`
+	FreshUntil int64       `json:"freshUntil,omitempty"`
+}
+
+// WithOpLog records every insertion, removal and lifetime change to w as an
+// append-only log of JSON lines, which ReplayOpLog can use to rebuild the cache
+// after a restart.
+// Keys and values round trip through encoding/json, so they should be of types
+// which decode back to themselves, such as strings. The first failed write
+// disables the log, and is reported by OpLogErr.
+func (tc *TimedCache) WithOpLog(w io.Writer) *TimedCache {
+	tc.lock.Lock()
+	tc.opLog, tc.opLogErr = nil, nil
+	if w != nil {
+		tc.opLog = json.NewEncoder(w)
+	}
+	tc.lock.Unlock()
+	return tc
+}
+
+// OpLogErr returns the write error which disabled the operation log, if any.
+func (tc *TimedCache) OpLogErr() error {
+	tc.lock.RLock()
+	defer tc.lock.RUnlock()
+	return tc.opLogErr
+}
+
+// ReplayOpLog rebuilds the cache state from an operation log recorded through
+// WithOpLog, on top of the current contents. Entries keep the expiry they were
+// last recorded with, including lifetime changes made after their insertion,
+// so those which have expired in the meantime are dropped. The
+// replayed operations are not recorded to the cache's own log. Replaying into
+// a closed cache fails with ErrClosed.
+func (tc *TimedCache) ReplayOpLog(r io.Reader) error {
+	if tc.closed() {
+		return ErrClosed
+	}
+	tc.lock.Lock()
+	opLog := tc.opLog
+	tc.opLog = nil
+
+	var (
+		err     error
+		expired = make(map[interface{}]opRecord) // Expired insertions, which later lifetime changes may revive
+	)
+	for dec := json.NewDecoder(r); ; {
+		var record opRecord
+		if err = dec.Decode(&record); err != nil {
+			break
+		}
+		switch record.Op {
+		case opAdd:
+			delete(expired, record.Key)
+			if record.ExpiresAt < tc.now() {
+				tc.removeFor(record.Key, EvictManual)
+				expired[record.Key] = record
+			} else {
+				tc.addAt(record.Key, record.Value, record.ExpiresAt, record.FreshUntil)
+			}
+		case opRemove:
+			delete(expired, record.Key)
+			tc.removeFor(record.Key, EvictManual)
+		case opExpiry:
+			if val, ok := tc.cache.Peek(record.Key); ok {
+				entry := val.(*timedEntry)
+				entry.expiresAt = record.ExpiresAt
+				heap.Fix(&tc.expiry, entry.index)
+			} else if add, ok := expired[record.Key]; ok && record.ExpiresAt >= tc.now() {
+				delete(expired, record.Key)
+				tc.addAt(record.Key, add.Value, record.ExpiresAt, add.FreshUntil)
+			}
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	tc.removeExpired()
+	tc.opLog = opLog
+
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return err
+}
+
+// logOp appends an operation to the operation log, if one is configured. It
+// must be called with the lock held.
+func (tc *timedCache) logOp(op string, key interface{}, entry *timedEntry) {
+	if tc.opLog == nil {
+		return
+	}
+	record := opRecord{Op: op, Key: key, Time: tc.now()}
+	switch op {
+	case opAdd:
+		record.Value, record.ExpiresAt, record.FreshUntil = entry.value, entry.expiresAt, entry.freshUntil
+	case opExpiry:
+		record.ExpiresAt = entry.expiresAt
+	}
+	if err := tc.opLog.Encode(&record); err != nil {
+		tc.opLog, tc.opLogErr = nil, err
+	}
+}
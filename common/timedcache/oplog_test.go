@@ -0,0 +1,103 @@
+package timedcache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOpLogReplay(t *testing.T) {
+	var log bytes.Buffer
+	tc, clock, _ := newTestCache(t, 3, 10)
+	tc.WithOpLog(&log)
+
+	tc.Add("a", "1")
+	tc.Add("b", "2")
+	tc.AddWithDeadline("c", "3", time.Unix(clock.time+30, 0))
+	tc.Remove("b")
+	tc.Add("a", "4")
+	clock.time += 5
+	tc.Add("d", "5")
+	tc.Add("e", "6") // evicts the least recently used entry
+
+	// Replaying into a fresh cache rebuilds the same state
+	restored, restoredClock, _ := newTestCache(t, 3, 10)
+	restoredClock.time = clock.time
+	if err := restored.ReplayOpLog(bytes.NewReader(log.Bytes())); err != nil {
+		t.Fatalf("failed to replay op log: %v", err)
+	}
+	if have, want := restored.Keys(), tc.Keys(); len(have) != len(want) {
+		t.Fatalf("replayed keys mismatch: have %v, want %v", have, want)
+	}
+	for _, key := range tc.Keys() {
+		want, _ := tc.Peek(key)
+		if have, ok := restored.Peek(key); !ok || have != want {
+			t.Errorf("key %v: replayed value mismatch: have %v, want %v", key, have, want)
+		}
+	}
+	checkExpirySync(t, restored)
+
+	// Entries expired since they were recorded are dropped
+	restored, restoredClock, _ = newTestCache(t, 3, 10)
+	restoredClock.time = clock.time + 8
+	if err := restored.ReplayOpLog(bytes.NewReader(log.Bytes())); err != nil {
+		t.Fatalf("failed to replay op log: %v", err)
+	}
+	for key, want := range map[string]bool{"a": false, "c": false, "d": true, "e": true} {
+		if have := restored.Contains(key); have != want {
+			t.Errorf("key %s: presence mismatch after expiry: have %v, want %v", key, have, want)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestOpLogWriteError(t *testing.T) {
+	tc, _, _ := newTestCache(t, 3, 10)
+	tc.WithOpLog(failingWriter{})
+	tc.Add("a", "1")
+	if err := tc.OpLogErr(); err == nil {
+		t.Fatalf("write error not reported")
+	}
+	if _, ok := tc.Get("a"); !ok {
+		t.Fatalf("entry dropped on log failure")
+	}
+}
+
+func TestOpLogReplayExpiry(t *testing.T) {
+	var log bytes.Buffer
+	tc, clock, _ := newTestCache(t, 10, 10)
+	tc.WithAdaptiveTTL(10*time.Second, 40*time.Second)
+	tc.WithOpLog(&log)
+
+	tc.Add("refreshed", "1")
+	tc.Add("popular", "2")
+	tc.Add("plain", "3")
+	clock.time += 5
+	tc.RefreshTTL([]interface{}{"refreshed"}, 20*time.Second)
+	tc.Get("popular")
+	tc.Get("popular")
+
+	// Lifetime changes survive the replay, outliving the plain entry
+	restored, restoredClock, _ := newTestCache(t, 10, 10)
+	restoredClock.time = clock.time + 12
+	if err := restored.ReplayOpLog(bytes.NewReader(log.Bytes())); err != nil {
+		t.Fatalf("failed to replay op log: %v", err)
+	}
+	for key, want := range map[string]bool{"refreshed": true, "popular": true, "plain": false} {
+		if have := restored.Contains(key); have != want {
+			t.Errorf("key %s: presence mismatch: have %v, want %v", key, have, want)
+		}
+	}
+	checkExpirySync(t, restored)
+	for _, key := range []string{"refreshed", "popular"} {
+		want, _ := tc.cache.Peek(key)
+		have, _ := restored.cache.Peek(key)
+		if have.(*timedEntry).expiresAt != want.(*timedEntry).expiresAt {
+			t.Errorf("key %s: replayed expiry mismatch: have %d, want %d", key, have.(*timedEntry).expiresAt, want.(*timedEntry).expiresAt)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package timedcache
+
+import (
+	"sort"
+	"strings"
+)
+
+// prefixIndex keeps the string keys of a cache sorted, so that the keys sharing
+// a prefix form a contiguous range.
+type prefixIndex struct {
+	keys []string
+}
+
+// insert adds a key to the index, if it is a string. It is a no-op on a nil
+// index.
+func (p *prefixIndex) insert(key interface{}) {
+	s, ok := key.(string)
+	if p == nil || !ok {
+		return
+	}
+	i := sort.SearchStrings(p.keys, s)
+	p.keys = append(p.keys, "")
+	copy(p.keys[i+1:], p.keys[i:])
+	p.keys[i] = s
+}
+
+// remove drops a key from the index, if present. It is a no-op on a nil index.
+func (p *prefixIndex) remove(key interface{}) {
+	s, ok := key.(string)
+	if p == nil || !ok {
+		return
+	}
+	if i := sort.SearchStrings(p.keys, s); i < len(p.keys) && p.keys[i] == s {
+		p.keys = append(p.keys[:i], p.keys[i+1:]...)
+	}
+}
+
+// scan returns the indexed keys starting with prefix, in key order.
+func (p *prefixIndex) scan(prefix string) []string {
+	start := sort.SearchStrings(p.keys, prefix)
+	end := start
+	for end < len(p.keys) && strings.HasPrefix(p.keys[end], prefix) {
+		end++
+	}
+	return p.keys[start:end]
+}
+
+// WithPrefixIndex maintains a sorted index of the string keys of the cache, so
+// that ScanPrefix costs O(log n + k) for k matches instead of scanning every
+// entry. The index costs O(n) per insertion of a new key, so it only pays off
+// for caches scanned frequently.
+func (tc *TimedCache) WithPrefixIndex() *TimedCache {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.prefixes = new(prefixIndex)
+	for _, key := range tc.cache.Keys() {
+		tc.prefixes.insert(key)
+	}
+	return tc
+}
+
+// ScanPrefix returns the live entries whose string key starts with prefix, in
+// key order, without updating their recent-ness. Entries with keys of other
+// types never match. As the LRU is not ordered by key, this scans every entry
+// in O(n) unless WithPrefixIndex is enabled.
+func (tc *TimedCache) ScanPrefix(prefix string) []Entry {
+	tc.lock.Lock()
+	tc.removeExpired()
+
+	var keys []string
+	if tc.prefixes != nil {
+		keys = tc.prefixes.scan(prefix)
+	} else {
+		for _, key := range tc.cache.Keys() {
+			if s, ok := key.(string); ok && strings.HasPrefix(s, prefix) {
+				keys = append(keys, s)
+			}
+		}
+		sort.Strings(keys)
+	}
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		val, _ := tc.cache.Peek(key)
+		entry := val.(*timedEntry)
+		entries = append(entries, Entry{Key: entry.key, Value: tc.logicalValue(entry), ExpiresAt: entry.expiresAt})
+	}
+	clone := tc.cloner
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	cloneEntries(entries, clone)
+	return entries
+}
@@ -0,0 +1,49 @@
+package timedcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScanPrefix(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		tc, clock, _ := newTestCache(t, 10, 10)
+		if indexed {
+			tc.WithPrefixIndex()
+		}
+		tc.Add("block:2", 2)
+		tc.Add("tx:1", 10)
+		tc.Add("block:1", 1)
+		tc.Add(42, "not a string")
+		tc.Add("block", 0)
+		tc.Add("block:stale", -1)
+		clock.time += 5
+		tc.Add("block:3", 3)
+		tc.Add("block:stale", -1) // refreshed entries are not duplicated
+		tc.Remove("block:1")
+
+		clock.time += 6 // expires all but the last two insertions
+		have := tc.ScanPrefix("block:")
+		if want := "[block:3 block:stale]"; fmt.Sprint(keysOf(have)) != want {
+			t.Errorf("indexed %v: scanned keys mismatch: have %v, want %v", indexed, keysOf(have), want)
+		}
+		if len(have) == 2 && (have[0].Value != 3 || have[1].Value != -1) {
+			t.Errorf("indexed %v: scanned values mismatch: have %v", indexed, have)
+		}
+		if have := tc.ScanPrefix("missing"); len(have) != 0 {
+			t.Errorf("indexed %v: unexpected matches: %v", indexed, have)
+		}
+		if indexed && fmt.Sprint(tc.prefixes.keys) != "[block:3 block:stale]" {
+			t.Errorf("prefix index out of sync: have %v", tc.prefixes.keys)
+		}
+	}
+}
+
+// keysOf returns the keys of the entries.
+func keysOf(entries []Entry) []interface{} {
+	keys := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	return keys
+}
@@ -0,0 +1,38 @@
+package timedcache
+
+// AddWithPriority adds a value to the cache in the given priority tier. When
+// the cache is full, the least recently used entry of the lowest tier present
+// is evicted, regardless of how recently entries of higher tiers were used, so
+// that bulk low priority data cannot flush out the entries which matter most.
+// Entries added without a priority are in tier 0, and re-adding a key moves it
+// to the tier of the new insertion.
+func (tc *TimedCache) AddWithPriority(key, value interface{}, priority int) (evicted bool) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	tc.prioritized = true
+	tc.priority = priority
+	evicted = tc.add(key, value)
+	tc.priority = 0
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return
+}
+
+// removeLowestPriority evicts the least recently used entry of the lowest
+// priority tier, returning whether there was any. It must be called with the
+// lock held.
+func (tc *timedCache) removeLowestPriority() bool {
+	var victim *timedEntry
+	for _, key := range tc.cache.Keys() {
+		val, _ := tc.cache.Peek(key)
+		if entry := val.(*timedEntry); victim == nil || entry.priority < victim.priority {
+			victim = entry
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	return tc.removeFor(victim.key, EvictCapacity)
+}
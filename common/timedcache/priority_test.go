@@ -0,0 +1,46 @@
+package timedcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddWithPriority(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 4, 10)
+
+	tc.AddWithPriority("high-1", 1, 1)
+	tc.AddWithPriority("low-1", 2, 0)
+	tc.AddWithPriority("high-2", 3, 1)
+	tc.Add("low-2", 4)
+
+	// Using the low priority entries does not keep them over high priority ones
+	tc.Get("low-1")
+	tc.Get("low-2")
+	if !tc.AddWithPriority("low-3", 5, 0) {
+		t.Fatalf("full cache insertion reported no eviction")
+	}
+	tc.Add("low-4", 6)
+	if want := []interface{}{"low-1", "low-2"}; !reflect.DeepEqual(*evicted, want) {
+		t.Fatalf("evicted keys mismatch: have %v, want %v", *evicted, want)
+	}
+	// Once only high priority entries are left, they are evicted by recency
+	tc.AddWithPriority("high-3", 7, 2)
+	tc.AddWithPriority("high-4", 8, 2)
+	tc.AddWithPriority("high-5", 9, 2)
+	if want := []interface{}{"low-1", "low-2", "low-3", "low-4", "high-1"}; !reflect.DeepEqual(*evicted, want) {
+		t.Fatalf("evicted keys mismatch: have %v, want %v", *evicted, want)
+	}
+	for _, key := range []string{"high-2", "high-3", "high-4", "high-5"} {
+		if _, ok := tc.Peek(key); !ok {
+			t.Fatalf("high priority entry %q evicted", key)
+		}
+	}
+	checkExpirySync(t, tc)
+
+	// Replacing a key moves it to the tier of the new insertion
+	tc.Add("high-2", 10)
+	tc.AddWithPriority("high-6", 11, 2)
+	if have := (*evicted)[len(*evicted)-1]; have != "high-2" {
+		t.Fatalf("demoted entry not evicted first: have %v", have)
+	}
+}
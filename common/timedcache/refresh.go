@@ -0,0 +1,118 @@
+package timedcache
+
+import (
+	"sync"
+	"time"
+)
+
+// refreshAheadConcurrency is the maximum number of loads the refresh-ahead
+// worker runs at once.
+const refreshAheadConcurrency = 4
+
+// Loader loads the value of a key from the backing store.
+type Loader func(key interface{}) (interface{}, error)
+
+// WithRefreshAhead starts a background worker reloading entries through loader
+// shortly before they expire, so that hot keys never miss. Every threshold/2,
+// any entry with less than threshold left to live, which has been read since it
+// was written, is reloaded and reinserted to live as long as it was given to,
+// keeping its tags, priority and access hook. Idle entries are left to expire.
+// At most a few loads run at once, and failed loads leave the entry to expire.
+// The worker stops once the cache is closed.
+func (tc *TimedCache) WithRefreshAhead(threshold time.Duration, loader Loader) *TimedCache {
+	state := tc.timedCache // retain the state only, see WithCloseOnGC
+	go func() {
+		interval := threshold / 2
+		if interval <= 0 {
+			interval = time.Millisecond
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				state.refreshAhead(int64(threshold), loader)
+			case <-state.quit:
+				return
+			}
+		}
+	}()
+	return tc
+}
+
+// refreshAhead reloads the entries read since written which expire within
+// threshold nanoseconds, returning once all the loads are done.
+func (tc *timedCache) refreshAhead(threshold int64, loader Loader) {
+	tc.lock.Lock()
+	tc.removeExpired()
+
+	var (
+		now     = tc.now()
+		entries []*timedEntry
+	)
+	for _, key := range tc.cache.Keys() {
+		val, _ := tc.cache.Peek(key)
+		entry := val.(*timedEntry)
+		if _, ok := tc.refreshing[key]; ok {
+			continue
+		}
+		if entry.expiresAt-now < threshold && entry.accessedAt >= entry.insertedAt {
+			if tc.refreshing == nil {
+				tc.refreshing = make(map[interface{}]struct{})
+			}
+			tc.refreshing[key] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+
+	var (
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, refreshAheadConcurrency)
+	)
+	for _, entry := range entries {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(entry *timedEntry) {
+			defer func() { <-slots; wg.Done() }()
+			tc.reload(entry, loader)
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// reload loads the value of an entry and reinserts it, unless the entry has
+// been replaced or removed while loading. The reloaded entry lives as long as
+// the original was given to, and keeps its tags, priority and access hook.
+func (tc *timedCache) reload(entry *timedEntry, loader Loader) {
+	value, err := loader(entry.key)
+
+	tc.lock.Lock()
+	delete(tc.refreshing, entry.key)
+	if err == nil {
+		if val, ok := tc.cache.Peek(entry.key); ok && val.(*timedEntry) == entry {
+			var (
+				now = tc.now()
+				seq = tc.seq
+			)
+			tc.addAt(entry.key, value, now+entry.expiresAt-entry.insertedAt, now+entry.freshUntil-entry.insertedAt)
+
+			if val, ok := tc.cache.Peek(entry.key); ok && tc.seq != seq {
+				reloaded := val.(*timedEntry)
+				reloaded.priority = entry.priority
+				reloaded.onAccess = entry.onAccess
+				if len(entry.tags) > 0 {
+					tc.tag(entry.key, reloaded, entry.tags)
+				}
+			}
+		}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+}
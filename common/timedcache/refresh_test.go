@@ -0,0 +1,167 @@
+package timedcache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAhead(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+
+	var loads int32
+	loader := func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		return key.(string) + "-reloaded", nil
+	}
+	tc.Add("hot", "hot")
+	tc.Add("idle", "idle")
+
+	// Nothing is close enough to expiry yet
+	clock.time = 1004
+	tc.Get("hot")
+	tc.refreshAhead(int64(5*time.Second), loader)
+	if have := atomic.LoadInt32(&loads); have != 0 {
+		t.Fatalf("refreshed before the threshold: %d loads", have)
+	}
+	// Once within the threshold, only the entry read since written is reloaded
+	clock.time = 1007
+	tc.refreshAhead(int64(5*time.Second), loader)
+	if have := atomic.LoadInt32(&loads); have != 1 {
+		t.Fatalf("load count mismatch: have %d, want %d", have, 1)
+	}
+	checkExpirySync(t, tc)
+
+	clock.time = 1011
+	if _, ok := tc.Peek("idle"); ok {
+		t.Fatalf("idle entry outlived its TTL")
+	}
+	if have, ok := tc.Peek("hot"); !ok || have != "hot-reloaded" {
+		t.Fatalf("hot entry mismatch: have %v (present %v), want %v", have, ok, "hot-reloaded")
+	}
+	// The reloaded entry is not hot again until read
+	tc.refreshAhead(int64(10*time.Second), loader)
+	if have := atomic.LoadInt32(&loads); have != 1 {
+		t.Fatalf("unread reloaded entry refreshed: %d loads", have)
+	}
+}
+
+func TestRefreshAheadFailure(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+
+	tc.Add("hot", "hot")
+	tc.Get("hot")
+	clock.time = 1007
+	tc.refreshAhead(int64(5*time.Second), func(key interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("backend down")
+	})
+	if have, ok := tc.Peek("hot"); !ok || have != "hot" {
+		t.Fatalf("failed reload altered the entry: have %v (present %v)", have, ok)
+	}
+	clock.time = 1011
+	if _, ok := tc.Peek("hot"); ok {
+		t.Fatalf("entry outlived its TTL despite the failed reload")
+	}
+}
+
+func TestRefreshAheadConcurrency(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 100, 10)
+	for i := 0; i < 20; i++ {
+		tc.Add(i, i)
+		tc.Get(i)
+	}
+	clock.time = 1007
+
+	var (
+		lock         sync.Mutex
+		active, peak int
+	)
+	tc.refreshAhead(int64(5*time.Second), func(key interface{}) (interface{}, error) {
+		lock.Lock()
+		if active++; active > peak {
+			peak = active
+		}
+		lock.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		lock.Lock()
+		active--
+		lock.Unlock()
+		return key, nil
+	})
+	if peak > refreshAheadConcurrency {
+		t.Fatalf("concurrent loads exceeded the bound: have %d, want at most %d", peak, refreshAheadConcurrency)
+	}
+	for i := 0; i < 20; i++ {
+		val, _ := tc.cache.Peek(i)
+		if have := val.(*timedEntry).expiresAt / int64(time.Second); have != 1017 {
+			t.Fatalf("entry %d expiry mismatch: have %d, want %d", i, have, 1017)
+		}
+	}
+}
+
+func TestRefreshAheadWorker(t *testing.T) {
+	tc, err := NewWithTTL(10, 50*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer tc.Close()
+
+	reloaded := make(chan struct{}, 1)
+	tc.WithRefreshAhead(40*time.Millisecond, func(key interface{}) (interface{}, error) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+		return "reloaded", nil
+	})
+	tc.Add("hot", "hot")
+	tc.Get("hot")
+
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatalf("entry not refreshed ahead of expiry")
+	}
+}
+
+func TestRefreshAheadKeepsMetadata(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+
+	var hooked int
+	tc.AddWithTags("tagged", "tagged", "group")
+	tc.AddWithAccessHook("hooked", "hooked", func() { hooked++ })
+	tc.AddWithPriority("prioritized", "prioritized", 2)
+	tc.GetOrAdd("short", "short", 4*time.Second)
+	for _, key := range []string{"tagged", "hooked", "prioritized", "short"} {
+		tc.Get(key)
+	}
+	hooked = 0
+
+	clock.time = 1002
+	tc.refreshAhead(int64(9*time.Second), func(key interface{}) (interface{}, error) {
+		return key.(string) + "-reloaded", nil
+	})
+	checkExpirySync(t, tc)
+
+	if have, ok := tc.Get("hooked"); !ok || have != "hooked-reloaded" || hooked != 1 {
+		t.Fatalf("access hook lost on reload: have %v (present %v), %d hook calls", have, ok, hooked)
+	}
+	val, _ := tc.cache.Peek("prioritized")
+	if have := val.(*timedEntry).priority; have != 2 {
+		t.Fatalf("priority lost on reload: have %d, want %d", have, 2)
+	}
+	val, _ = tc.cache.Peek("short")
+	if have := val.(*timedEntry).expiresAt / int64(time.Second); have != 1006 {
+		t.Fatalf("reloaded ttl mismatch: have expiry %d, want %d", have, 1006)
+	}
+	if have := tc.RemoveByTag("group"); have != 1 {
+		t.Fatalf("tagged removal mismatch: have %d, want %d", have, 1)
+	}
+	if _, ok := tc.Peek("tagged"); ok {
+		t.Fatalf("reloaded entry survived the invalidation of its tag")
+	}
+}
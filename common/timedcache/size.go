@@ -0,0 +1,44 @@
+package timedcache
+
+import "math/rand"
+
+// WithSizer registers a function measuring the memory footprint of an entry in
+// bytes, used by EstimatedBytesSampled.
+func (tc *TimedCache) WithSizer(sizer func(key, value interface{}) int64) *TimedCache {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.sizer = sizer
+	return tc
+}
+
+// EstimatedBytesSampled estimates the memory footprint of the live entries by
+// measuring sampleSize of them picked at random, with replacement, and scaling
+// their average size up to the length of the cache. Caches holding no more than
+// sampleSize entries are measured exactly. Zero is returned if no sizer has
+// been registered by WithSizer.
+func (tc *TimedCache) EstimatedBytesSampled(sampleSize int) int64 {
+	tc.lock.Lock()
+	tc.removeExpired()
+
+	var total int64
+	if length := len(tc.expiry); tc.sizer != nil && length > 0 && sampleSize > 0 {
+		if sampleSize >= length {
+			for _, entry := range tc.expiry {
+				total += tc.sizer(entry.key, tc.logicalValue(entry))
+			}
+		} else {
+			var sampled int64
+			for i := 0; i < sampleSize; i++ {
+				entry := tc.expiry[rand.Intn(length)]
+				sampled += tc.sizer(entry.key, tc.logicalValue(entry))
+			}
+			total = int64(float64(sampled) / float64(sampleSize) * float64(length))
+		}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return total
+}
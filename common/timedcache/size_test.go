@@ -0,0 +1,60 @@
+package timedcache
+
+import (
+	"math"
+	"testing"
+)
+
+// testSizer measures entries holding byte slices by their length.
+func testSizer(key, value interface{}) int64 {
+	return int64(len(value.([]byte)))
+}
+
+func TestEstimatedBytesSampledUniform(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10000, 10)
+	tc.WithSizer(testSizer)
+
+	for i := 0; i < 5000; i++ {
+		tc.Add(i, make([]byte, 64))
+	}
+	if have, want := tc.EstimatedBytesSampled(100), int64(5000*64); have != want {
+		t.Fatalf("estimate mismatch: have %d, want %d", have, want)
+	}
+}
+
+func TestEstimatedBytesSampledTolerance(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10000, 10)
+	tc.WithSizer(testSizer)
+
+	// Sizes cycle uniformly through 32..95 bytes
+	var exact int64
+	for i := 0; i < 5000; i++ {
+		tc.Add(i, make([]byte, 32+i%64))
+		exact += int64(32 + i%64)
+	}
+	have := tc.EstimatedBytesSampled(1000)
+	if diff := math.Abs(float64(have-exact)) / float64(exact); diff > 0.1 {
+		t.Fatalf("estimate too far off: have %d, want %d±10%%", have, exact)
+	}
+}
+
+func TestEstimatedBytesSampledExact(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+	if have := tc.EstimatedBytesSampled(10); have != 0 {
+		t.Fatalf("estimate without sizer: have %d, want 0", have)
+	}
+	tc.WithSizer(testSizer)
+
+	tc.Add("a", make([]byte, 10))
+	clock.time += 5
+	tc.Add("b", make([]byte, 20))
+	tc.Add("c", make([]byte, 30))
+	if have, want := tc.EstimatedBytesSampled(3), int64(60); have != want {
+		t.Fatalf("estimate mismatch: have %d, want %d", have, want)
+	}
+	// Expired entries are not accounted for
+	clock.time += 6
+	if have, want := tc.EstimatedBytesSampled(3), int64(50); have != want {
+		t.Fatalf("estimate after expiry mismatch: have %d, want %d", have, want)
+	}
+}
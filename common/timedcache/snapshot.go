@@ -0,0 +1,116 @@
+package timedcache
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrSnapshotVersion is returned by RestoreDelta if the cache does not hold the
+// snapshot the delta was taken against.
+var ErrSnapshotVersion = errors.New("timed cache snapshot version mismatch")
+
+// SnapshotVersioned returns the live entries of the cache, from the least to
+// the most recently used, along with the version of the contents they capture.
+// The version changes with every insertion and every removal but expiries, so
+// two snapshots of the same version hold the same entries, bar those expired in
+// between.
+func (tc *TimedCache) SnapshotVersioned() (version uint64, entries []Entry) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	keys := tc.cache.Keys()
+	entries = make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		val, _ := tc.cache.Peek(key)
+		entry := val.(*timedEntry)
+		entries = append(entries, Entry{Key: entry.key, Value: tc.logicalValue(entry), ExpiresAt: entry.expiresAt})
+	}
+	version, clone := tc.ver, tc.cloner
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	cloneEntries(entries, clone)
+	return version, entries
+}
+
+// DiffSnapshots computes the delta from a base snapshot to a newer one, as the
+// entries added or changed since and the keys removed since, for persisting
+// only the changes of a mostly unchanged cache.
+func DiffSnapshots(base, next []Entry) (added []Entry, removedKeys []interface{}) {
+	previous := make(map[interface{}]Entry, len(base))
+	for _, entry := range base {
+		previous[entry.Key] = entry
+	}
+	for _, entry := range next {
+		if old, ok := previous[entry.Key]; !ok || old.ExpiresAt != entry.ExpiresAt || !reflect.DeepEqual(old.Value, entry.Value) {
+			added = append(added, entry)
+		}
+		delete(previous, entry.Key)
+	}
+	for _, entry := range base {
+		if _, ok := previous[entry.Key]; ok {
+			removedKeys = append(removedKeys, entry.Key)
+		}
+	}
+	return added, removedKeys
+}
+
+// Restore replaces the contents of the cache with the entries of a snapshot
+// taken by SnapshotVersioned, adopting its version so that deltas against it
+// can be applied by RestoreDelta. The dropped contents are reported to the
+// eviction callbacks as manual removals. Entries keep the expiry they were
+// snapshotted with, so those which have expired in the meantime are dropped.
+func (tc *TimedCache) Restore(version uint64, entries []Entry) {
+	tc.lock.Lock()
+	tc.reason = EvictManual
+	tc.cache.Purge()
+	tc.reason = EvictCapacity
+	tc.restore(entries)
+	tc.ver = version
+
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+}
+
+// RestoreDelta applies a delta computed by DiffSnapshots on top of the snapshot
+// of version baseVersion, which the cache must still hold unmodified, as after
+// a Restore of it. The cache then adopts version, the one of the snapshot the
+// delta leads to, so that the next delta can be chained on top. It fails with
+// ErrSnapshotVersion otherwise, leaving the cache untouched. Entries keep the
+// expiry they were snapshotted with, so those which have expired in the
+// meantime are dropped.
+func (tc *TimedCache) RestoreDelta(baseVersion, version uint64, added []Entry, removedKeys []interface{}) error {
+	tc.lock.Lock()
+	tc.removeExpired()
+
+	var err error
+	if tc.ver != baseVersion {
+		err = ErrSnapshotVersion
+	} else {
+		for _, key := range removedKeys {
+			tc.removeFor(key, EvictManual)
+		}
+		tc.restore(added)
+		tc.ver = version
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return err
+}
+
+// restore inserts snapshotted entries in order, replacing and dropping the
+// ones which have expired since. It must be called with the lock held.
+func (tc *timedCache) restore(entries []Entry) {
+	now := tc.now()
+	for _, entry := range entries {
+		if entry.ExpiresAt < now {
+			tc.removeFor(entry.Key, EvictManual)
+		} else {
+			tc.addAt(entry.Key, entry.Value, entry.ExpiresAt, entry.ExpiresAt)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package timedcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+// entryMap indexes snapshotted entries by key, for order insensitive checks.
+func entryMap(entries []Entry) map[interface{}]Entry {
+	m := make(map[interface{}]Entry, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry
+	}
+	return m
+}
+
+func TestRestoreDelta(t *testing.T) {
+	source, clock, _ := newTestCache(t, 10, 10)
+	for i := 0; i < 6; i++ {
+		source.Add(i, i)
+	}
+	baseVersion, base := source.SnapshotVersioned()
+
+	// Warm up a fresh cache from the full base snapshot
+	restored, _, evicted := newTestCache(t, 10, 10)
+	restored.Add("stale", 0)
+	restored.Restore(baseVersion, base)
+	if len(*evicted) != 1 {
+		t.Fatalf("dropped contents not reported: have %v", *evicted)
+	}
+	if _, entries := restored.SnapshotVersioned(); !reflect.DeepEqual(entries, base) {
+		t.Fatalf("full restore mismatch: have %v, want %v", entries, base)
+	}
+	// Modify the source and restore only the delta
+	clock.time++
+	source.Add(6, 6)
+	source.Add(1, "one")
+	source.Remove(2)
+	nextVersion, next := source.SnapshotVersioned()
+	if nextVersion == baseVersion {
+		t.Fatalf("version unchanged by modifications")
+	}
+	added, removed := DiffSnapshots(base, next)
+	if len(added) != 2 || len(removed) != 1 {
+		t.Fatalf("delta mismatch: have %d added, %d removed, want 2 and 1", len(added), len(removed))
+	}
+	if err := restored.RestoreDelta(baseVersion, nextVersion, added, removed); err != nil {
+		t.Fatalf("failed to restore delta: %v", err)
+	}
+	restoredVersion, have := restored.SnapshotVersioned()
+	if !reflect.DeepEqual(entryMap(have), entryMap(next)) {
+		t.Fatalf("delta restore mismatch: have %v, want %v", have, next)
+	}
+	if restoredVersion != nextVersion {
+		t.Fatalf("restored version mismatch: have %d, want %d", restoredVersion, nextVersion)
+	}
+	checkExpirySync(t, restored)
+
+	// A delta against another version is rejected without touching the cache
+	if err := restored.RestoreDelta(baseVersion, nextVersion, added, removed); err != ErrSnapshotVersion {
+		t.Fatalf("version mismatch error: have %v, want %v", err, ErrSnapshotVersion)
+	}
+	if _, entries := restored.SnapshotVersioned(); !reflect.DeepEqual(entries, have) {
+		t.Fatalf("rejected delta modified the cache")
+	}
+}
+
+func TestRestoreExpired(t *testing.T) {
+	source, clock, _ := newTestCache(t, 10, 10)
+	source.Add("old", 1)
+	clock.time += 5
+	source.Add("new", 2)
+	version, entries := source.SnapshotVersioned()
+
+	// Entries which expired since the snapshot are dropped, without breaking
+	// the version of the restored snapshot
+	restored, restoredClock, _ := newTestCache(t, 10, 10)
+	restoredClock.time = 1011
+	restored.Restore(version, entries)
+	if _, ok := restored.Peek("old"); ok {
+		t.Fatalf("expired entry restored")
+	}
+	if _, ok := restored.Peek("new"); !ok {
+		t.Fatalf("live entry not restored")
+	}
+	restoredClock.time = 1016
+	if err := restored.RestoreDelta(version, version, nil, nil); err != nil {
+		t.Fatalf("expiry broke the restored version: %v", err)
+	}
+}
+
+func TestRestoreDeltaChain(t *testing.T) {
+	source, _, _ := newTestCache(t, 10, 10)
+	for i := 0; i < 4; i++ {
+		source.Add(i, i)
+	}
+	baseVersion, base := source.SnapshotVersioned()
+
+	restored, _, _ := newTestCache(t, 10, 10)
+	restored.Restore(baseVersion, base)
+
+	// Each delta is applied on top of the snapshot the previous one led to
+	prevVersion, prev := baseVersion, base
+	for round := 0; round < 2; round++ {
+		source.Add(10+round, round)
+		source.Remove(round)
+		nextVersion, next := source.SnapshotVersioned()
+
+		added, removed := DiffSnapshots(prev, next)
+		if err := restored.RestoreDelta(prevVersion, nextVersion, added, removed); err != nil {
+			t.Fatalf("round %d: failed to restore delta: %v", round, err)
+		}
+		version, have := restored.SnapshotVersioned()
+		if version != nextVersion || !reflect.DeepEqual(entryMap(have), entryMap(next)) {
+			t.Fatalf("round %d: chained restore mismatch: have %d %v, want %d %v", round, version, have, nextVersion, next)
+		}
+		prevVersion, prev = nextVersion, next
+	}
+	checkExpirySync(t, restored)
+}
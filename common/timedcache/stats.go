@@ -0,0 +1,168 @@
+package timedcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds the lookup statistics of a cache.
+type Stats struct {
+	Hits   uint64 // Number of lookups which found a live entry
+	Misses uint64 // Number of lookups which found no or an expired entry
+
+	LogicalBytes    uint64 // Uncompressed size of the compressed values held
+	CompressedBytes uint64 // Stored size of the compressed values held
+
+	EvictionsDropped uint64 // Evictions dropped off a full EvictedChan
+}
+
+// Summary is a point in time overview of the cache contents.
+type Summary struct {
+	Len     int           // Number of live entries
+	Stats   Stats         // Lookup statistics at the time of the summary
+	TopKeys []interface{} // Most recently used keys, newest first
+}
+
+// Entry is an exported view of a live cache entry.
+type Entry struct {
+	Key       interface{}
+	Value     interface{}
+	ExpiresAt int64 // Unix time in nanoseconds after which the entry expires
+}
+
+// recordLookup accounts for a Get style lookup. It must be called with the
+// lock held.
+func (tc *timedCache) recordLookup(hit bool) {
+	if hit {
+		tc.stats.Hits++
+	} else {
+		tc.stats.Misses++
+	}
+}
+
+// recordLabeledLookup accounts for a lookup attributed to a label. It must be
+// called with the lock held.
+func (tc *timedCache) recordLabeledLookup(label string, hit bool) {
+	if tc.labeledStats == nil {
+		tc.labeledStats = make(map[string]Stats)
+	}
+	stats := tc.labeledStats[label]
+	if hit {
+		stats.Hits++
+	} else {
+		stats.Misses++
+	}
+	tc.labeledStats[label] = stats
+}
+
+// GetTagged is like Get, but additionally attributes the lookup to the given
+// label, so that TaggedStats can reveal which call sites suffer from poor cache
+// locality. An empty label is only accounted in the global statistics.
+func (tc *TimedCache) GetTagged(key interface{}, label string) (value interface{}, ok bool) {
+	return tc.getLabeled(key, label)
+}
+
+// TaggedStats returns the lookup statistics of each label passed to GetTagged.
+func (tc *TimedCache) TaggedStats() map[string]Stats {
+	tc.lock.RLock()
+	defer tc.lock.RUnlock()
+
+	stats := make(map[string]Stats, len(tc.labeledStats))
+	for label, labelStats := range tc.labeledStats {
+		stats[label] = labelStats
+	}
+	return stats
+}
+
+// Stats returns the lookup statistics of the cache.
+func (tc *TimedCache) Stats() Stats {
+	tc.lock.RLock()
+	defer tc.lock.RUnlock()
+
+	stats := tc.stats
+	stats.EvictionsDropped = atomic.LoadUint64(&tc.evictedDrops)
+	return stats
+}
+
+// summary builds an overview of the cache with up to topN of the most recently
+// used keys, none if topN is negative. It must be called with the lock held.
+func (tc *timedCache) summary(topN int) Summary {
+	tc.removeExpired()
+	keys := tc.cache.Keys()
+	if topN > len(keys) {
+		topN = len(keys)
+	}
+	if topN < 0 {
+		topN = 0
+	}
+	top := make([]interface{}, 0, topN)
+	for i := len(keys) - 1; i >= len(keys)-topN; i-- {
+		top = append(top, keys[i])
+	}
+	return Summary{
+		Len:     len(keys),
+		Stats:   tc.stats,
+		TopKeys: top,
+	}
+}
+
+// TopK returns up to k of the live entries, from the most to the least recently
+// used, without updating their recent-ness. Returns nil if k is not positive.
+func (tc *TimedCache) TopK(k int) []Entry {
+	if k <= 0 {
+		return nil
+	}
+	tc.lock.Lock()
+	tc.removeExpired()
+	keys := tc.cache.Keys()
+	if k > len(keys) {
+		k = len(keys)
+	}
+	entries := make([]Entry, 0, k)
+	for i := len(keys) - 1; i >= len(keys)-k; i-- {
+		val, _ := tc.cache.Peek(keys[i])
+		entry := val.(*timedEntry)
+		entries = append(entries, Entry{Key: entry.key, Value: tc.logicalValue(entry), ExpiresAt: entry.expiresAt})
+	}
+	clone := tc.cloner
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	cloneEntries(entries, clone)
+	return entries
+}
+
+// WithSnapshotStream starts a background worker publishing a summary of the
+// cache, with up to topN of its most recently used keys, every interval. Sends
+// never block: summaries are dropped if the consumer falls behind. The returned
+// channel is closed once the cache is closed.
+func (tc *TimedCache) WithSnapshotStream(interval time.Duration, topN int) <-chan Summary {
+	sink := make(chan Summary, 1)
+	state := tc.timedCache // retain the state only, see WithCloseOnGC
+	go func() {
+		defer close(sink)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				state.lock.Lock()
+				summary := state.summary(topN)
+				pending := state.takeEvicted()
+				state.lock.Unlock()
+				state.notifyEvicted(pending)
+
+				select {
+				case sink <- summary:
+				default:
+				}
+			case <-state.quit:
+				return
+			}
+		}
+	}()
+	return sink
+}
@@ -0,0 +1,135 @@
+package timedcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+	tc.Add(1, 1)
+	tc.Get(1)
+	tc.Get(2)
+	clock.time += 11
+	tc.Get(1)
+	if have, want := tc.Stats(), (Stats{Hits: 1, Misses: 2}); have != want {
+		t.Fatalf("stats mismatch: have %+v, want %+v", have, want)
+	}
+}
+
+func TestTaggedStats(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	for i := 0; i < 4; i++ {
+		tc.Add(i, i)
+	}
+	// The hot path hits its working set, the cold path mostly misses
+	for i := 0; i < 10; i++ {
+		tc.GetTagged(i%2, "hot")
+		tc.GetTagged(i, "cold")
+	}
+	tc.Get(9)
+
+	stats := tc.TaggedStats()
+	if have, want := stats["hot"], (Stats{Hits: 10}); have != want {
+		t.Errorf("hot stats mismatch: have %+v, want %+v", have, want)
+	}
+	if have, want := stats["cold"], (Stats{Hits: 4, Misses: 6}); have != want {
+		t.Errorf("cold stats mismatch: have %+v, want %+v", have, want)
+	}
+	if len(stats) != 2 {
+		t.Errorf("label count mismatch: have %d, want 2", len(stats))
+	}
+	// Labeled lookups still count towards the global statistics
+	if have, want := tc.Stats(), (Stats{Hits: 14, Misses: 7}); have != want {
+		t.Errorf("global stats mismatch: have %+v, want %+v", have, want)
+	}
+}
+
+func TestSnapshotStream(t *testing.T) {
+	tc, err := New(10, 60)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		tc.Add(i, i)
+	}
+	tc.Get(0)
+
+	stream := tc.WithSnapshotStream(10*time.Millisecond, 3)
+	for i := 0; i < 3; i++ {
+		select {
+		case summary := <-stream:
+			if summary.Len != 5 {
+				t.Errorf("summary %d: length mismatch: have %d, want 5", i, summary.Len)
+			}
+			if summary.Stats.Hits != 1 {
+				t.Errorf("summary %d: hits mismatch: have %d, want 1", i, summary.Stats.Hits)
+			}
+			if want := []interface{}{0, 4, 3}; fmt.Sprint(summary.TopKeys) != fmt.Sprint(want) {
+				t.Errorf("summary %d: top keys mismatch: have %v, want %v", i, summary.TopKeys, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("summary %d not delivered", i)
+		}
+	}
+	tc.Close()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-stream:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("stream not closed after Close")
+		}
+	}
+}
+
+func TestSnapshotStreamNegativeTopN(t *testing.T) {
+	tc, err := New(10, 60)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer tc.Close()
+	tc.Add(0, 0)
+
+	select {
+	case summary := <-tc.WithSnapshotStream(10*time.Millisecond, -1):
+		if summary.Len != 1 || len(summary.TopKeys) != 0 {
+			t.Fatalf("summary mismatch: have %d entries and top keys %v, want 1 and none", summary.Len, summary.TopKeys)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("summary not delivered")
+	}
+}
+
+func TestTopK(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+	for i := 0; i < 6; i++ {
+		tc.Add(i, i*10)
+		if i == 1 {
+			clock.time += 5
+		}
+	}
+	tc.Get(2)
+	clock.time += 6 // expires keys 0 and 1
+
+	entries := tc.TopK(3)
+	expiresAt := 1015 * int64(time.Second)
+	want := []Entry{{2, 20, expiresAt}, {5, 50, expiresAt}, {4, 40, expiresAt}}
+	if fmt.Sprint(entries) != fmt.Sprint(want) {
+		t.Fatalf("top entries mismatch: have %v, want %v", entries, want)
+	}
+	// Asking for more than available returns only the live entries
+	if have := tc.TopK(10); len(have) != 4 {
+		t.Fatalf("live entries mismatch: have %v", have)
+	}
+	for _, k := range []int{0, -1} {
+		if have := tc.TopK(k); have != nil {
+			t.Fatalf("unexpected entries for k=%d: %v", k, have)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package timedcache
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentStress hammers a shared cache with random operations from many
+// goroutines. It is meant to be run with -race, and is skipped in -short mode.
+func TestConcurrentStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	const (
+		workers  = 32
+		ops      = 20000
+		keys     = 256
+		maxSize  = 64
+		duration = 5 * time.Second
+	)
+	tc, err := NewWithEvict(maxSize, 1, func(k, v interface{}) {
+		if k.(int) != v.(int) {
+			t.Errorf("evicted value mismatch: key %v, value %v", k, v)
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer tc.Close()
+
+	var (
+		wg       sync.WaitGroup
+		deadline = time.Now().Add(duration)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+
+			for n := 0; n < ops && time.Now().Before(deadline); n++ {
+				key := rng.Intn(keys)
+				switch op := rng.Intn(100); {
+				case op < 40:
+					tc.Add(key, key)
+				case op < 80:
+					if value, ok := tc.Get(key); ok && value.(int) != key {
+						t.Errorf("value mismatch: key %v, value %v", key, value)
+					}
+				case op < 95:
+					tc.Remove(key)
+				case op < 99:
+					tc.Resize(1 + rng.Intn(maxSize))
+				default:
+					tc.Purge()
+				}
+				if have := tc.Len(); have > maxSize {
+					t.Errorf("cache overflow: have %d entries, max %d", have, maxSize)
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	if have := tc.cache.Len(); have > tc.size {
+		t.Fatalf("cache overflow: have %d entries, size %d", have, tc.size)
+	}
+	checkExpirySync(t, tc)
+}
@@ -0,0 +1,128 @@
+package timedcache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sweeper periodically removes the expired entries of all the caches registered
+// with it from a single goroutine, so that nodes running many caches need not
+// run a background worker per cache. Caches expire entries lazily on access
+// either way; sweeping releases the expired entries of idle caches, reporting
+// them to their eviction callbacks in a timely manner.
+type Sweeper struct {
+	caches map[*timedCache]struct{} // Registered caches
+	lock   sync.Mutex
+
+	interval  time.Duration // Time between two sweeps
+	lastSweep int64         // Unix time in nanoseconds of the last completed sweep, accessed atomically
+
+	quit      chan struct{} // Quit channel to stop the sweeping goroutine
+	done      chan struct{} // Channel closed once the sweeping goroutine exited
+	closeOnce sync.Once     // Ensures the quit channel will not be closed twice
+}
+
+// NewSweeper creates a sweeper sweeping its registered caches every interval.
+func NewSweeper(interval time.Duration) *Sweeper {
+	s := &Sweeper{
+		caches:    make(map[*timedCache]struct{}),
+		interval:  interval,
+		lastSweep: time.Now().UnixNano(),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.loop(interval)
+	return s
+}
+
+// loop sweeps the registered caches on every tick until the sweeper is closed.
+func (s *Sweeper) loop(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// sweep removes the expired entries of every registered cache, dropping the
+// caches which have been closed.
+func (s *Sweeper) sweep() {
+	s.lock.Lock()
+	caches := make([]*timedCache, 0, len(s.caches))
+	for tc := range s.caches {
+		if tc.closed() {
+			delete(s.caches, tc)
+			continue
+		}
+		caches = append(caches, tc)
+	}
+	s.lock.Unlock()
+
+	for _, tc := range caches {
+		tc.lock.Lock()
+		tc.removeExpired()
+		pending := tc.takeEvicted()
+		tc.lock.Unlock()
+		// invoke callback outside of critical section
+		tc.notifyEvicted(pending)
+	}
+	atomic.StoreInt64(&s.lastSweep, time.Now().UnixNano())
+}
+
+// alive checks that the sweeping goroutine is still running and that a sweep
+// completed recently, as a wedged cache would block it.
+func (s *Sweeper) alive() error {
+	select {
+	case <-s.done:
+		return ErrSweeperStopped
+	default:
+	}
+	if since := time.Since(time.Unix(0, atomic.LoadInt64(&s.lastSweep))); since > 2*s.interval+healthCheckTimeout {
+		return fmt.Errorf("%w: no sweep completed for %v", ErrSweeperStopped, since)
+	}
+	return nil
+}
+
+// Unregister stops sweeping the cache. Closed caches are unregistered on their
+// own.
+func (s *Sweeper) Unregister(tc *TimedCache) {
+	s.lock.Lock()
+	delete(s.caches, tc.timedCache)
+	s.lock.Unlock()
+
+	tc.lock.Lock()
+	if tc.sweeper == s {
+		tc.sweeper = nil
+	}
+	tc.lock.Unlock()
+}
+
+// Close stops the sweeper. The registered caches are left as they are.
+func (s *Sweeper) Close() {
+	s.closeOnce.Do(func() {
+		close(s.quit)
+	})
+}
+
+// RegisterWithSweeper makes the shared sweeper remove the expired entries of
+// the cache periodically, until unregistered from it or closed.
+func (tc *TimedCache) RegisterWithSweeper(s *Sweeper) *TimedCache {
+	s.lock.Lock()
+	s.caches[tc.timedCache] = struct{}{} // retain the state only, see WithCloseOnGC
+	s.lock.Unlock()
+
+	tc.lock.Lock()
+	tc.sweeper = s
+	tc.lock.Unlock()
+	return tc
+}
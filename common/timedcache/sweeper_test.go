@@ -0,0 +1,89 @@
+package timedcache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSweeper(t *testing.T) {
+	s := NewSweeper(time.Hour) // swept manually
+	defer s.Close()
+
+	var (
+		caches  []*TimedCache
+		clocks  []*testClock
+		evicted []*[]interface{}
+	)
+	for i := 0; i < 3; i++ {
+		tc, clock, keys := newTestCache(t, 10, 10)
+		tc.Add(i, i)
+		tc.RegisterWithSweeper(s)
+		caches, clocks, evicted = append(caches, tc), append(clocks, clock), append(evicted, keys)
+	}
+	// Expired entries of all the caches are removed by a single sweep, without
+	// any access to the caches
+	for _, clock := range clocks {
+		clock.time += 11
+	}
+	s.sweep()
+	for i := range caches {
+		if want := []interface{}{i}; !reflect.DeepEqual(*evicted[i], want) {
+			t.Fatalf("cache %d: swept keys mismatch: have %v, want %v", i, *evicted[i], want)
+		}
+	}
+	// Unregistering stops sweeping that cache only
+	s.Unregister(caches[1])
+	for i, tc := range caches {
+		tc.Add("next", i)
+		clocks[i].time += 11
+	}
+	s.sweep()
+	for i := range caches {
+		want := []interface{}{i, "next"}
+		if i == 1 {
+			want = []interface{}{i}
+		}
+		if !reflect.DeepEqual(*evicted[i], want) {
+			t.Fatalf("cache %d: swept keys mismatch: have %v, want %v", i, *evicted[i], want)
+		}
+	}
+	// Closed caches are dropped by the sweeper
+	caches[2].Close()
+	s.sweep()
+	s.lock.Lock()
+	registered := len(s.caches)
+	s.lock.Unlock()
+	if registered != 1 {
+		t.Fatalf("registered caches mismatch: have %d, want %d", registered, 1)
+	}
+}
+
+func TestSweeperTicks(t *testing.T) {
+	s := NewSweeper(time.Millisecond)
+	defer s.Close()
+
+	swept := make(chan interface{}, 2)
+	for i := 0; i < 2; i++ {
+		tc, err := NewWithTTL(10, time.Millisecond, func(key, value interface{}) { swept <- key })
+		if err != nil {
+			t.Fatalf("failed to create cache: %v", err)
+		}
+		tc.Add(i, i)
+		tc.RegisterWithSweeper(s)
+	}
+	var keys []int
+	for len(keys) < 2 {
+		select {
+		case key := <-swept:
+			keys = append(keys, key.(int))
+		case <-time.After(time.Second):
+			t.Fatalf("caches not swept: have %v", keys)
+		}
+	}
+	sort.Ints(keys)
+	if !reflect.DeepEqual(keys, []int{0, 1}) {
+		t.Fatalf("swept keys mismatch: have %v", keys)
+	}
+}
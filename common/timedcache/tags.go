@@ -0,0 +1,69 @@
+package timedcache
+
+// AddWithTags adds a value to the cache like Add, labelling its entry with the
+// given tags so it can be invalidated in bulk through RemoveByTag. The tags
+// replace those of any previous entry for the key. Returns true if an eviction
+// occurred.
+func (tc *TimedCache) AddWithTags(key, value interface{}, tags ...string) (evicted bool) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	seq := tc.seq
+	evicted = tc.add(key, value)
+
+	// Only tag the entry if it was actually inserted, not a rejected value's
+	if val, ok := tc.cache.Peek(key); ok && tc.seq != seq && len(tags) > 0 {
+		tc.tag(key, val.(*timedEntry), tags)
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return
+}
+
+// RemoveByTag removes all the live entries carrying the given tag, returning
+// the number of entries removed.
+func (tc *TimedCache) RemoveByTag(tag string) (removed int) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	keys := make([]interface{}, 0, len(tc.tagged[tag]))
+	for key := range tc.tagged[tag] {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if tc.removeFor(key, EvictManual) {
+			removed++
+		}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return removed
+}
+
+// tag labels an entry with the given tags, replacing any it carried. It must
+// be called with the lock held.
+func (tc *timedCache) tag(key interface{}, entry *timedEntry, tags []string) {
+	tc.untag(key, entry)
+	if tc.tagged == nil {
+		tc.tagged = make(map[string]map[interface{}]struct{})
+	}
+	entry.tags = append([]string(nil), tags...) // the caller may reuse its slice
+	for _, tag := range tags {
+		if tc.tagged[tag] == nil {
+			tc.tagged[tag] = make(map[interface{}]struct{})
+		}
+		tc.tagged[tag][key] = struct{}{}
+	}
+}
+
+// untag drops an entry from the tag index. It must be called with the lock
+// held.
+func (tc *timedCache) untag(key interface{}, entry *timedEntry) {
+	for _, tag := range entry.tags {
+		if delete(tc.tagged[tag], key); len(tc.tagged[tag]) == 0 {
+			delete(tc.tagged, tag)
+		}
+	}
+}
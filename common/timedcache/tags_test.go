@@ -0,0 +1,92 @@
+package timedcache
+
+import "testing"
+
+func TestRemoveByTag(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 4, 10)
+
+	tc.AddWithTags("a", 1, "block", "zone")
+	tc.AddWithTags("b", 2, "block")
+	tc.AddWithTags("c", 3, "zone")
+	tc.Add("d", 4)
+
+	// Bulk removal drops every entry carrying the tag, and only those
+	if have := tc.RemoveByTag("block"); have != 2 {
+		t.Fatalf("removed count mismatch: have %d, want 2", have)
+	}
+	if tc.Contains("a") || tc.Contains("b") {
+		t.Fatalf("tagged entries survived removal")
+	}
+	if !tc.Contains("c") || !tc.Contains("d") {
+		t.Fatalf("entries without the tag removed")
+	}
+	if _, ok := tc.tagged["block"]; ok {
+		t.Fatalf("removed tag still indexed")
+	}
+	if have := len(tc.tagged["zone"]); have != 1 {
+		t.Fatalf("zone tag size mismatch: have %d, want 1", have)
+	}
+	// Re-adding a key without tags drops it from the index
+	tc.Add("c", 5)
+	if len(tc.tagged) != 0 {
+		t.Fatalf("retagged key still indexed: %v", tc.tagged)
+	}
+	// Evicted and expired entries are dropped from the index
+	tc.AddWithTags("e", 6, "tx")
+	for i := 0; i < 4; i++ {
+		tc.Add(i, i)
+	}
+	if len(tc.tagged) != 0 {
+		t.Fatalf("evicted key still indexed: %v", tc.tagged)
+	}
+	tc.AddWithTags("f", 7, "tx")
+	clock.time += 11
+	if have := tc.RemoveByTag("tx"); have != 0 {
+		t.Fatalf("expired entries removed: have %d, want 0", have)
+	}
+	if len(tc.tagged) != 0 {
+		t.Fatalf("expired key still indexed: %v", tc.tagged)
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestAddWithTagsReplace(t *testing.T) {
+	tc, _, _ := newTestCache(t, 4, 10)
+
+	// Retagging a key moves it between the tag sets
+	tc.AddWithTags("a", 1, "old")
+	tc.AddWithTags("a", 2, "new")
+	if _, ok := tc.tagged["old"]; ok {
+		t.Fatalf("replaced tag still indexed: %v", tc.tagged)
+	}
+	if have := tc.RemoveByTag("new"); have != 1 {
+		t.Fatalf("removed count mismatch: have %d, want 1", have)
+	}
+	// A rejected value leaves the tags of the previous entry alone
+	tc.AddWithTags("b", 1, "kept")
+	tc.AddWithTags("b", &timedEntry{}, "rejected")
+	if _, ok := tc.tagged["rejected"]; ok {
+		t.Fatalf("rejected insertion tagged: %v", tc.tagged)
+	}
+	if value, _ := tc.Peek("b"); value != 1 {
+		t.Fatalf("previous value mismatch: have %v, want 1", value)
+	}
+	if have := len(tc.tagged["kept"]); have != 1 {
+		t.Fatalf("previous tag size mismatch: have %d, want 1", have)
+	}
+	// Reusing the tag slice after the insertion leaves the index intact
+	tags := []string{"c1", "c2"}
+	tc.AddWithTags("c", 1, tags...)
+	tags[0] = "mutated"
+	tc.AddWithTags("c", 2, "c3")
+	if _, ok := tc.tagged["c1"]; ok {
+		t.Fatalf("tag of a mutated slice left in the index: %v", tc.tagged)
+	}
+	// Directly retagging an entry drops its previous tags from the index
+	val, _ := tc.cache.Peek("b")
+	tc.tag("b", val.(*timedEntry), []string{"other"})
+	if _, ok := tc.tagged["kept"]; ok {
+		t.Fatalf("overwritten tag still indexed: %v", tc.tagged)
+	}
+	checkExpirySync(t, tc)
+}
@@ -1,36 +1,137 @@
 package timedcache
 
 import (
+	"container/heap"
+	"encoding/json"
+	"runtime"
 	"sync"
 	"time"
 
-	lru "github.com/hashicorp/golang-lru"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	// evictedBufferSize defines the default buffer size to store evicted key/val
+	evictedBufferSize = 16
 )
 
 // timedEntry provides a wrapper to store an entry in an LRU cache, with a
 // specified expiration time
 type timedEntry struct {
-	expiresAt int64
-	value     interface{}
+	key        interface{}
+	value      interface{}
+	expiresAt  int64
+	freshUntil int64 // Time until which the entry is authoritative, at most expiresAt
+	insertedAt int64 // Time the entry was written
+
+	cost  float64 // Cost to recompute the entry, if cost-aware eviction is enabled
+	freq  uint64  // Number of times the entry was inserted or hit
+	score float64 // Greedy-Dual-Size-Frequency priority, lowest is evicted first
+	index int     // Position of the entry in the expiry queue, -1 if not queued
+	seq   uint64  // Insertion sequence number, ordering entries expiring together
+	reads uint64  // Number of Get style hits, if adaptive TTL is enabled
+
+	priority int    // Eviction tier of the entry, lower tiers are evicted first
+	onAccess func() // Hook fired on Get style hits of the entry, see AddWithAccessHook
+
+	accessedAt int64 // Time of the last Get style hit, 0 if never hit
+
+	tags []string   // Labels for bulk invalidation, see AddWithTags
+	lazy *lazyValue // Decoded form of an encoded value, nil if not decoded lazily
+
+	codec       CompressionCodec // Codec the value was compressed with, nil if stored as is
+	logicalSize int              // Uncompressed size of a compressed value
 }
 
-// expired returns whether or not the given entry has expired
-func (te *timedEntry) expired() bool {
-	return te.expiresAt < time.Now().Unix()
+// expired returns whether or not the given entry has expired at time now
+func (te *timedEntry) expired(now int64) bool {
+	return te.expiresAt < now
 }
 
 // TimedCache defines a new cache, where entries are removed after exceeding
 // their ttl. The entry is not guaranteed to live this long (i.e. if it gets
 // evicted when the cache fills up). Conversely, the entry also isn't guaranteed
 // to expire at exactly the ttl time. The expiration mechanism is 'lazy', and
-// will only remove expired objects at next access.
+// will only remove expired objects at next access, in the order they expired.
 type TimedCache struct {
-	ttl   int64        // Time to live in seconds
-	cache *lru.Cache   // Underlying size-limited LRU cache
-	lock  sync.RWMutex
+	*timedCache // State shared with background workers, which must not retain the handle
+}
+
+// timedCache holds the state of a TimedCache. Background workers only reference
+// it rather than the TimedCache handle, so that the handle can be garbage
+// collected while they run.
+type timedCache struct {
+	ttl    int64          // Time to live in nanoseconds
+	wttl   int64          // Time in nanoseconds an entry stays fresh, at most ttl
+	maxTTL int64          // Time in nanoseconds hits may extend the life of an entry to, 0 if not adaptive
+	size   int            // Maximum number of entries in the cache
+	cache  *simplelru.LRU // Underlying size-limited LRU cache
+	expiry expiryQueue    // Min-heap of the cached entries by expiration time
+	now    func() int64   // Current unix time in nanoseconds, overridable for tests
+	seq    uint64         // Sequence number of the last inserted entry
+	ver    uint64         // Version of the contents, bumped on insertions and non-expiry removals
+	fifo   bool           // Whether lookups leave the eviction order untouched
+	lock   contendedLock
+
+	nsQuota map[string]int // Maximum number of entries per namespace, nil if unlimited
+	nsCount map[string]int // Number of cached entries per quota tracked namespace
+
+	stats        Stats            // Lookup statistics since creation
+	labeledStats map[string]Stats // Lookup statistics per caller supplied label
+
+	costFn    func(key, value interface{}) float64 // Recompute cost of entries, nil for pure LRU eviction
+	inflation float64                              // Score of the last cost-aware victim, aging the remaining entries
+
+	sizer func(key, value interface{}) int64 // Memory footprint of entries in bytes, nil if not measured
+
+	indexFn   func(value interface{}) interface{} // Secondary attribute of values, nil if not indexed
+	secondary map[interface{}]interface{}         // Secondary attribute to newest primary key
+
+	hot *hotKeys // Access frequency tracker, nil if disabled
+
+	lifetimes lifetimes // Lifetime distribution of the removed entries
+
+	tombstones map[interface{}]int64 // Soft deleted keys to the time their tombstone expires
+
+	tagged map[string]map[interface{}]struct{} // Tag to the keys of the entries carrying it
+
+	waiters map[interface{}]*waiter // Keys awaited by WaitFor to the waiter released on their insertion
+
+	prefixes *prefixIndex // Sorted string keys for ScanPrefix, nil if not indexed
+
+	sweeper *Sweeper // Shared sweeper the cache is registered with, nil if none
+
+	negative *simplelru.LRU // Keys known to be missing to their expiry time, nil if disabled
+
+	refreshing map[interface{}]struct{} // Keys being reloaded by the refresh-ahead worker
+
+	fallback ReadOnly // Cache consulted on Get misses, nil if disabled
+
+	prioritized bool // Whether entries were inserted with priorities, see AddWithPriority
+	priority    int  // Priority of the entry being inserted
+
+	decoder func([]byte) (interface{}, error) // Decoder of encoded values, nil if values are stored as is
+	cloner  func(interface{}) interface{}     // Cloner of returned values, nil if returned by reference
+	codec   CompressionCodec                  // Codec compressing []byte values, nil if stored as is
+
+	opLog    *json.Encoder // Operation log to record insertions and removals to, nil if disabled
+	opLogErr error         // Write error which disabled the operation log
+
+	quit      chan struct{} // Quit channel to stop background workers
+	closeOnce sync.Once     // Ensures the quit channel will not be closed twice
 
 	evictedKeys, evictedVals []interface{}
+	evictedReasons           []EvictReason
 	onEvictedCB              func(k, v interface{})
+	onEvictReasonCB          func(k, v interface{}, reason EvictReason)
+	reason                   EvictReason // Reason reported for removals in progress
+
+	evictedCh    chan EvictedEntry // Channel evictions are delivered to, nil if disabled
+	evictedDrops uint64            // Evictions dropped off a full channel, accessed atomically
+
+	expiredBatches   [][]interface{}          // Keys expired by each sweep, pending notification
+	onExpiredBatchCB func(keys []interface{}) // Batched expiry callback, nil if disabled
 }
 
 // New creates a new cache with a given size and ttl. TTL defines the time in
@@ -42,102 +143,570 @@ func New(size int, ttl int) (*TimedCache, error) {
 // NewWithEvict constructs a fixed size cache with the given ttl & eviction
 // callback.
 func NewWithEvict(size int, ttl int, onEvicted func(key, value interface{})) (*TimedCache, error) {
-	tc := &TimedCache{
+	return NewWithTTL(size, time.Duration(ttl)*time.Second, onEvicted)
+}
+
+// NewWithTTL is like NewWithEvict, but takes the ttl as a duration, honoured at
+// nanosecond precision.
+func NewWithTTL(size int, ttl time.Duration, onEvicted func(key, value interface{})) (*TimedCache, error) {
+	tc := &timedCache{
 		ttl:         int64(ttl),
+		wttl:        int64(ttl),
+		size:        size,
+		now:         unixNow,
+		quit:        make(chan struct{}),
 		onEvictedCB: onEvicted,
 	}
 	if onEvicted != nil {
 		tc.initEvictBuffers()
-		onEvicted = tc.onEvictedCB
 	}
-	cache, err := lru.NewWithEvict(size, onEvicted)
+	// The LRU always reports removals back, so the expiry queue can be kept in
+	// sync with entries the LRU drops on its own.
+	cache, err := simplelru.NewLRU(size, tc.onEvicted)
 	if err != nil {
 		return nil, err
 	}
 	tc.cache = cache
-	return tc, nil
+	return &TimedCache{tc}, nil
 }
 
-func (tc *TimedCache) initEvictBuffers() {
-	tc.evictedKeys = make([]interface{}, 0, lru.DefaultEvictedBufferSize)
-	tc.evictedVals = make([]interface{}, 0, lru.DefaultEvictedBufferSize)
+// unixNow returns the current unix time in nanoseconds.
+func unixNow() int64 {
+	return time.Now().UnixNano()
 }
 
-// onEvicted save evicted key/val and sent in externally registered callback
-// outside of critical section
-func (tc *TimedCache) onEvicted(k, v interface{}) {
-	tc.evictedKeys = append(tc.evictedKeys, k)
-	tc.evictedVals = append(tc.evictedVals, v)
+func (tc *timedCache) initEvictBuffers() {
+	tc.evictedKeys = make([]interface{}, 0, evictedBufferSize)
+	tc.evictedVals = make([]interface{}, 0, evictedBufferSize)
+	tc.evictedReasons = make([]EvictReason, 0, evictedBufferSize)
+}
+
+// onEvicted drops a removed entry from the expiry queue, and saves the evicted
+// key/val to be sent to the externally registered callback outside of the
+// critical section
+func (tc *timedCache) onEvicted(k, v interface{}) {
+	entry := v.(*timedEntry)
+	if entry.index >= 0 {
+		heap.Remove(&tc.expiry, entry.index)
+	}
+	tc.releaseNamespace(k)
+	tc.prefixes.remove(k)
+	tc.unindex(k, entry)
+	tc.untag(k, entry)
+	tc.hot.forget(k)
+	tc.logOp(opRemove, k, entry)
+	if tc.reason != EvictExpired {
+		tc.ver++
+	}
+	tc.lifetimes.record(tc.now() - entry.insertedAt)
+	tc.releaseCompressed(entry)
+	if tc.onEvictedCB != nil || tc.onEvictReasonCB != nil || tc.evictedCh != nil {
+		tc.evictedKeys = append(tc.evictedKeys, k)
+		tc.evictedVals = append(tc.evictedVals, tc.logicalValue(entry))
+		tc.evictedReasons = append(tc.evictedReasons, tc.reason)
+	}
+}
+
+// removeFor removes a key from the LRU, reporting the given eviction reason
+// for it. It must be called with the lock held.
+func (tc *timedCache) removeFor(key interface{}, reason EvictReason) bool {
+	tc.reason = reason
+	present := tc.cache.Remove(key)
+	tc.reason = EvictCapacity
+	return present
+}
+
+// evictions holds the removals buffered in a critical section, to be reported
+// to the registered callbacks once the lock is released.
+type evictions struct {
+	keys, vals []interface{}   // Evicted entries for the per-entry callbacks
+	reasons    []EvictReason   // Reasons the entries were evicted for
+	expired    [][]interface{} // Keys expired by each sweep, for the batch callback
+
+	onReason  func(key, value interface{}, reason EvictReason) // Reason aware callback in effect
+	onExpired func(keys []interface{})                         // Batched expiry callback in effect
+	sink      chan EvictedEntry                                // Eviction channel in effect, nil if disabled
+}
+
+// EvictReason tells why an entry left the cache.
+type EvictReason uint8
+
+const (
+	EvictCapacity EvictReason = iota // Evicted to make room for other entries
+	EvictExpired                     // Expired after its ttl
+	EvictManual                      // Explicitly removed or purged
+)
+
+// String implements fmt.Stringer.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictManual:
+		return "manual"
+	default:
+		return "unknown"
+	}
+}
+
+// WithEvictReasonCallback registers a callback invoked for every entry leaving
+// the cache along with the reason it left for, next to the eviction callback
+// passed to NewWithEvict. It is invoked outside of the critical section.
+func (tc *TimedCache) WithEvictReasonCallback(onEvicted func(key, value interface{}, reason EvictReason)) *TimedCache {
+	tc.lock.Lock()
+	tc.onEvictReasonCB = onEvicted
+	tc.lock.Unlock()
+	return tc
+}
+
+// takeEvicted returns the buffered evictions and resets the buffers. It must
+// be called with the lock held.
+func (tc *timedCache) takeEvicted() (pending evictions) {
+	if len(tc.evictedKeys) > 0 {
+		pending.keys, pending.vals, pending.reasons = tc.evictedKeys, tc.evictedVals, tc.evictedReasons
+		tc.initEvictBuffers()
+	}
+	pending.onReason = tc.onEvictReasonCB
+	pending.onExpired = tc.onExpiredBatchCB
+	pending.sink = tc.evictedCh
+	pending.expired, tc.expiredBatches = tc.expiredBatches, nil
+	return pending
+}
+
+// notifyEvicted invokes the eviction callbacks for the given evictions. It must
+// be called outside of the critical section.
+func (tc *timedCache) notifyEvicted(pending evictions) {
+	for i := 0; i < len(pending.keys); i++ {
+		if tc.onEvictedCB != nil {
+			tc.onEvictedCB(pending.keys[i], pending.vals[i])
+		}
+		if pending.onReason != nil {
+			pending.onReason(pending.keys[i], pending.vals[i], pending.reasons[i])
+		}
+		if pending.sink != nil {
+			tc.sendEvicted(pending.sink, EvictedEntry{Key: pending.keys[i], Value: pending.vals[i], Reason: pending.reasons[i]})
+		}
+	}
+	if pending.onExpired != nil {
+		for _, keys := range pending.expired {
+			pending.onExpired(keys)
+		}
+	}
 }
 
 // calcExpireTime calculates the expiration time given a TTL relative to now.
-func calcExpireTime(ttl int64) int64 {
-	t := time.Now().Unix() + ttl
+func (tc *timedCache) calcExpireTime(ttl int64) int64 {
+	t := tc.now() + ttl
 	return t
 }
 
-// removeExpired removes any expired entries from the cache
-func (tc *TimedCache) removeExpired() {
-	for k := range tc.cache.Keys() {
-		if val, ok := tc.cache.Peek(k); ok {
-			if v := val.(timedEntry); v.expired() {
-				tc.cache.Remove(k)
-			}
+// removeExpired removes any expired entries from the cache, popping them off
+// the expiry queue until the first entry which is still live.
+func (tc *timedCache) removeExpired() {
+	var (
+		now   = tc.now()
+		batch []interface{}
+	)
+	for len(tc.expiry) > 0 && tc.expiry[0].expired(now) {
+		entry := heap.Pop(&tc.expiry).(*timedEntry)
+		tc.removeFor(entry.key, EvictExpired)
+		if tc.onExpiredBatchCB != nil {
+			batch = append(batch, entry.key)
+		}
+	}
+	if len(batch) > 0 {
+		tc.expiredBatches = append(tc.expiredBatches, batch)
+	}
+}
+
+// WithBatchExpireNotify registers a callback invoked once per expiry sweep with
+// all the keys expired in it, sparing high churn callers a call per entry. It
+// complements the per-entry eviction callback, which still fires for each of
+// them. The callback is invoked outside of the critical section.
+func (tc *TimedCache) WithBatchExpireNotify(onExpired func(keys []interface{})) *TimedCache {
+	tc.lock.Lock()
+	tc.onExpiredBatchCB = onExpired
+	tc.lock.Unlock()
+	return tc
+}
+
+// add wraps the value into a timed entry living for the cache's ttl and inserts
+// it into both the LRU and the expiry queue, replacing any previous entry for
+// the same key.
+func (tc *timedCache) add(key, value interface{}) (evicted bool) {
+	return tc.addAt(key, value, tc.calcExpireTime(tc.ttl), tc.calcExpireTime(tc.wttl))
+}
+
+// addAt is like add, but with explicit expiry and freshness deadlines.
+func (tc *timedCache) addAt(key, value interface{}, expiresAt, freshUntil int64) (evicted bool) {
+	if tc.closed() || tc.tombstoned(key) || wrapped(key, value) {
+		return false
+	}
+	if tc.negative != nil {
+		tc.negative.Remove(key)
+	}
+	val, replaced := tc.cache.Peek(key)
+	if replaced {
+		old := val.(*timedEntry)
+		if old.index >= 0 {
+			heap.Remove(&tc.expiry, old.index)
 		}
+		tc.unindex(key, old)
+		tc.untag(key, old)
+		tc.releaseCompressed(old)
+	} else {
+		tc.reserveNamespace(key)
+	}
+	entry := &timedEntry{
+		key:        key,
+		value:      value,
+		expiresAt:  expiresAt,
+		freshUntil: freshUntil,
+		insertedAt: tc.now(),
+		priority:   tc.priority,
+	}
+	tc.seq++
+	tc.ver++
+	entry.seq = tc.seq
+	if _, ok := value.([]byte); ok && tc.decoder != nil {
+		entry.lazy = &lazyValue{decoder: tc.decoder}
+	}
+	if tc.costFn != nil {
+		// Make room by cost instead of letting the LRU drop its oldest entry
+		if !tc.cache.Contains(key) && tc.cache.Len() >= tc.size {
+			evicted = tc.removeCheapest()
+		}
+		entry.cost = tc.costFn(key, value)
+		tc.touch(entry)
+	}
+	if tc.prioritized && !tc.cache.Contains(key) && tc.cache.Len() >= tc.size {
+		// Make room from the lowest priority tier instead of the LRU's oldest
+		evicted = tc.removeLowestPriority() || evicted
+	}
+	tc.logOp(opAdd, key, entry)
+	tc.compress(entry)
+	heap.Push(&tc.expiry, entry)
+	evicted = tc.cache.Add(key, entry) || evicted
+	tc.reindex(key, value)
+	if !replaced {
+		tc.prefixes.insert(key)
+	}
+	tc.wake(key)
+	return evicted
+}
+
+// wrapped reports whether a value is the cache's own entry wrapper, as leaked
+// through WithUnderlying, which must never be stored lest it be mistaken for
+// the entry of another key. Such values are rejected with an error log rather
+// than a panic, which would leave the lock held.
+func wrapped(key, value interface{}) bool {
+	switch value.(type) {
+	case *timedEntry, timedEntry:
+		log.Error("Rejected caching an internal timed cache entry as a value", "key", key)
+		return true
+	}
+	return false
+}
+
+// lookup fetches the entry of a key from the LRU, marking it as recently used
+// unless eviction is deterministic.
+func (tc *timedCache) lookup(key interface{}) (interface{}, bool) {
+	if tc.fifo {
+		return tc.cache.Peek(key)
+	}
+	return tc.cache.Get(key)
+}
+
+// peek returns the live entry for key without updating its recent-ness,
+// removing it if it has expired.
+func (tc *timedCache) peek(key interface{}) (*timedEntry, bool) {
+	val, ok := tc.cache.Peek(key)
+	if !ok {
+		return nil, false
+	}
+	entry := val.(*timedEntry)
+	if entry.expired(tc.now()) {
+		tc.removeFor(key, EvictExpired)
+		return nil, false
 	}
+	return entry, true
 }
 
 // Purge is used to completely clear the cache.
 func (tc *TimedCache) Purge() {
-	var ks, vs []interface{}
 	tc.lock.Lock()
+	tc.reason = EvictManual
 	tc.cache.Purge()
-	if tc.onEvictedCB != nil && len(tc.evictedKeys) > 0 {
-		ks, vs = tc.evictedKeys, tc.evictedVals
-		tc.initEvictBuffers()
+	tc.reason = EvictCapacity
+	tc.expiry = nil
+	tc.tombstones = nil
+	if tc.negative != nil {
+		tc.negative.Purge()
 	}
+	pending := tc.takeEvicted()
 	tc.lock.Unlock()
 	// invoke callback outside of critical section
-	if tc.onEvictedCB != nil {
-		for i := 0; i < len(ks); i++ {
-			tc.onEvictedCB(ks[i], vs[i])
+	tc.notifyEvicted(pending)
+}
+
+// PurgeOlderThan removes every entry inserted more than age ago regardless of
+// its ttl, as coarse emergency memory relief. The removals are reported to the
+// eviction callbacks as manual ones. Returns the number of entries removed.
+func (tc *TimedCache) PurgeOlderThan(age time.Duration) (removed int) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	cutoff := tc.now() - int64(age)
+	for _, key := range tc.cache.Keys() {
+		if val, ok := tc.cache.Peek(key); ok && val.(*timedEntry).insertedAt < cutoff {
+			tc.removeFor(key, EvictManual)
+			removed++
 		}
 	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return removed
 }
 
 // Add adds a value to the cache. Returns true if an eviction occurred.
 func (tc *TimedCache) Add(key, value interface{}) (evicted bool) {
-	var k, v interface{}
 	tc.lock.Lock()
 	// First remove expired entries, so that LRU cache doesn't evict more than
 	// necessary, if there is not enough room to add this entry.
 	tc.removeExpired()
 	// Wrap the entry and add it to the cache
-	evicted = tc.cache.Add(key, timedEntry{expiresAt: calcExpireTime(tc.ttl), value: value})
+	evicted = tc.add(key, value)
+	pending := tc.takeEvicted()
 	tc.lock.Unlock()
 	// invoke callback outside of critical section
-	if tc.onEvictedCB != nil {
-		tc.onEvictedCB(k, v)
+	tc.notifyEvicted(pending)
+	return
+}
+
+// AddWithDeadline adds a value to the cache which expires at the given wall
+// clock time rather than after the cache's ttl. A deadline which has already
+// passed expires the value immediately: it is not stored, and any previous value
+// for the key is removed. Returns true if an eviction occurred.
+func (tc *TimedCache) AddWithDeadline(key, value interface{}, deadline time.Time) (evicted bool) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	if expiresAt := deadline.UnixNano(); expiresAt < tc.now() {
+		tc.removeFor(key, EvictManual)
+	} else {
+		evicted = tc.addAt(key, value, expiresAt, expiresAt)
 	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
 	return
 }
 
 // Get looks up a key's value from the cache, removing it if it has expired.
 func (tc *TimedCache) Get(key interface{}) (value interface{}, ok bool) {
+	return tc.getLabeled(key, "")
+}
+
+// GetWithFallback looks up the primary key, and if it holds no live entry, the
+// fallback key, as for values known under an alias. Returns the value of the
+// first live hit. Both keys are looked up atomically, and count as one lookup.
+func (tc *TimedCache) GetWithFallback(primary, fallback interface{}) (value interface{}, ok bool) {
 	tc.lock.Lock()
-	defer tc.lock.Unlock()
-	val, ok := tc.cache.Get(key)
+	key := primary
+	if _, live := tc.peek(primary); !live {
+		key = fallback
+	}
+	found, ok := tc.getAndUnlock(key, "")
+	if !ok {
+		return nil, false
+	}
+	if value, err := found.resolve(); err == nil {
+		return value, true
+	}
+	return nil, false
+}
+
+// getLabeled is like Get, additionally attributing the lookup to a label if it
+// is not empty.
+func (tc *timedCache) getLabeled(key interface{}, label string) (value interface{}, ok bool) {
+	found, ok := tc.get(key, label)
+	if !ok {
+		return tc.getFallback(key)
+	}
+	if value, err := found.resolve(); err == nil {
+		return value, true
+	}
+	return nil, false
+}
+
+// lookupResult is a value found in the cache, to be resolved outside of the
+// critical section.
+type lookupResult struct {
+	value interface{}
+	lazy  *lazyValue                    // Decoded form of the value, nil if stored as is
+	codec CompressionCodec              // Codec the value is compressed with, nil if stored as is
+	clone func(interface{}) interface{} // Cloner of returned values, nil if returned by reference
+	seq   uint64                        // Insertion sequence number of the entry, if set by the lookup
+}
+
+// resolve returns the value to hand out to the caller, decompressing and
+// decoding it if it is stored so, and cloning it if a cloner is configured.
+func (r lookupResult) resolve() (interface{}, error) {
+	raw := func() ([]byte, error) {
+		if r.codec == nil {
+			return r.value.([]byte), nil
+		}
+		return r.codec.Decompress(r.value.([]byte))
+	}
+	var (
+		value = r.value
+		err   error
+	)
+	switch {
+	case r.lazy != nil:
+		value, err = r.lazy.decode(raw)
+	case r.codec != nil:
+		value, err = raw()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.clone != nil {
+		value = r.clone(value)
+	}
+	return value, nil
+}
+
+// get looks up the raw value of a key, along with its lazily decoded form if
+// a decoder is configured. Lookups with a non-empty label are also accounted
+// in the label's statistics.
+func (tc *timedCache) get(key interface{}, label string) (found lookupResult, ok bool) {
+	tc.lock.Lock()
+	return tc.getAndUnlock(key, label)
+}
+
+// getAndUnlock is like get, but must be called with the lock held, which it
+// releases.
+func (tc *timedCache) getAndUnlock(key interface{}, label string) (found lookupResult, ok bool) {
+	var onAccess func()
+	val, ok := tc.lookup(key)
 	if ok {
-		v := val.(timedEntry)
-		if v.expired() {
-			tc.cache.Remove(key)
-			return nil, false
+		entry := val.(*timedEntry)
+		if entry.expired(tc.now()) {
+			tc.removeFor(key, EvictExpired)
+			ok = false
 		} else {
-			return v.value, true
+			found = lookupResult{value: entry.value, lazy: entry.lazy, codec: entry.codec, clone: tc.cloner, seq: entry.seq}
+			onAccess = entry.onAccess
+			tc.touch(entry)
+			tc.adapt(entry)
+			tc.hot.hit(key, tc.now())
 		}
-	} else {
+	}
+	tc.recordLookup(ok)
+	if label != "" {
+		tc.recordLabeledLookup(label, ok)
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callbacks outside of critical section
+	tc.notifyEvicted(pending)
+	if onAccess != nil {
+		onAccess()
+	}
+	return found, ok
+}
+
+// GetWithDeadline is like Get, but gives up with a miss if the lock cannot be
+// acquired before the deadline, for latency sensitive callers preferring to
+// reload a value over stalling behind writers. timedOut reports whether the
+// lookup was abandoned.
+func (tc *TimedCache) GetWithDeadline(key interface{}, deadline time.Time) (value interface{}, ok, timedOut bool) {
+	if !tc.lock.lockUntil(deadline) {
+		return nil, false, true
+	}
+	found, ok := tc.getAndUnlock(key, "")
+	if !ok {
+		return nil, false, false
+	}
+	if value, err := found.resolve(); err == nil {
+		return value, true, false
+	}
+	return nil, false, false
+}
+
+// GetFresh is like Get, but additionally reports whether the value is still
+// within its write TTL. Values past their write TTL are still served as stale
+// until their read TTL elapses.
+func (tc *TimedCache) GetFresh(key interface{}) (value interface{}, fresh, ok bool) {
+	var found lookupResult
+	tc.lock.Lock()
+	val, ok := tc.lookup(key)
+	if ok {
+		entry := val.(*timedEntry)
+		if now := tc.now(); entry.expired(now) {
+			tc.removeFor(key, EvictExpired)
+			ok = false
+		} else {
+			found, fresh = lookupResult{value: entry.value, lazy: entry.lazy, codec: entry.codec, clone: tc.cloner}, entry.freshUntil >= now
+			tc.touch(entry)
+			tc.adapt(entry)
+			tc.hot.hit(key, now)
+		}
+	}
+	tc.recordLookup(ok)
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	if !ok {
+		return nil, false, false
+	}
+	var err error
+	if value, err = found.resolve(); err != nil {
+		return nil, false, false
+	}
+	return value, fresh, ok
+}
+
+// GetConsistent is like Get, but only serves values written at most maxStaleness
+// ago, reporting older ones as misses so that the caller reloads them from the
+// backing store. Unlike the ttl, the staleness bound is chosen per read. Stale
+// entries are left in the cache.
+func (tc *TimedCache) GetConsistent(key interface{}, maxStaleness time.Duration) (value interface{}, ok bool) {
+	var found lookupResult
+	tc.lock.Lock()
+	val, ok := tc.lookup(key)
+	if ok {
+		entry := val.(*timedEntry)
+		if now := tc.now(); entry.expired(now) {
+			tc.removeFor(key, EvictExpired)
+			ok = false
+		} else if now-entry.insertedAt > int64(maxStaleness) {
+			ok = false
+		} else {
+			found = lookupResult{value: entry.value, lazy: entry.lazy, codec: entry.codec, clone: tc.cloner}
+			tc.touch(entry)
+			tc.adapt(entry)
+			tc.hot.hit(key, now)
+		}
+	}
+	tc.recordLookup(ok)
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	if !ok {
+		return nil, false
+	}
+	var err error
+	if value, err = found.resolve(); err != nil {
 		return nil, false
 	}
+	return value, ok
 }
 
 // Contains checks if a key is in the cache, without updating the
@@ -150,38 +719,42 @@ func (tc *TimedCache) Contains(key interface{}) bool {
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness or ttl of the key.
 func (tc *TimedCache) Peek(key interface{}) (value interface{}, ok bool) {
+	var found lookupResult
 	tc.lock.Lock()
-	defer tc.lock.Unlock()
-	val, ok := tc.cache.Peek(key)
+	entry, ok := tc.peek(key)
 	if ok {
-		v := val.(timedEntry)
-		if v.expired() {
-			tc.cache.Remove(key)
-			return nil, false
-		} else {
-			return v.value, ok
-		}
-	} else {
+		found = lookupResult{value: entry.value, codec: entry.codec, clone: tc.cloner}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	if !ok {
 		return nil, false
 	}
+	var err error
+	if value, err = found.resolve(); err != nil {
+		return nil, false
+	}
+	return value, ok
 }
 
 // ContainsOrAdd checks if a key is in the cache without updating the
 // recent-ness, ttl, or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (tc *TimedCache) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
-	var k, v interface{}
 	tc.lock.Lock()
 	// First remove expired entries, so that LRU cache doesn't evict more than
 	// necessary, if there is not enough room to add this entry.
 	tc.removeExpired()
 	// Wrap the entry and add it to the cache
-	ok, evicted = tc.cache.ContainsOrAdd(key, timedEntry{expiresAt: calcExpireTime(tc.ttl), value: value})
+	if ok = tc.cache.Contains(key); !ok {
+		evicted = tc.add(key, value)
+	}
+	pending := tc.takeEvicted()
 	tc.lock.Unlock()
 	// invoke callback outside of critical section
-	if tc.onEvictedCB != nil {
-		tc.onEvictedCB(k, v)
-	}
+	tc.notifyEvicted(pending)
 	return
 }
 
@@ -189,92 +762,240 @@ func (tc *TimedCache) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
 // recent-ness, ttl, or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (tc *TimedCache) PeekOrAdd(key, value interface{}) (previous interface{}, ok, evicted bool) {
-	var k, v interface{}
 	tc.lock.Lock()
 	// First remove expired entries, so that LRU cache doesn't evict more than
 	// necessary, if there is not enough room to add this entry.
 	tc.removeExpired()
 	// Wrap the entry and add it to the cache
-	previous, ok, evicted = tc.cache.PeekOrAdd(key, timedEntry{expiresAt: calcExpireTime(tc.ttl), value: value})
+	if val, found := tc.cache.Peek(key); found {
+		previous, ok = tc.logicalValue(val.(*timedEntry)), true
+	} else {
+		evicted = tc.add(key, value)
+	}
+	clone := tc.cloner
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	if ok && clone != nil {
+		previous = clone(previous)
+	}
+	return
+}
+
+// GetOrAdd returns the live value of a key if present, without updating its
+// recent-ness, or otherwise adds the given value to live for ttl. Returns the
+// value in the cache, nil if the key is soft deleted, and whether it was
+// already present.
+func (tc *TimedCache) GetOrAdd(key, value interface{}, ttl time.Duration) (actual interface{}, loaded bool) {
+	var cached bool // Whether actual is held by the cache, to be cloned
+	tc.lock.Lock()
+	tc.removeExpired()
+	if entry, ok := tc.peek(key); ok {
+		actual, loaded, cached = tc.logicalValue(entry), true, true
+	} else if !tc.tombstoned(key) {
+		expiresAt := tc.calcExpireTime(int64(ttl))
+		freshUntil := tc.calcExpireTime(tc.wttl)
+		if freshUntil > expiresAt {
+			freshUntil = expiresAt
+		}
+		seq := tc.seq
+		tc.addAt(key, value, expiresAt, freshUntil)
+		actual, cached = value, tc.seq != seq
+	}
+	clone := tc.cloner
+	pending := tc.takeEvicted()
 	tc.lock.Unlock()
 	// invoke callback outside of critical section
-	if tc.onEvictedCB != nil {
-		tc.onEvictedCB(k, v)
+	tc.notifyEvicted(pending)
+	if cached && clone != nil {
+		actual = clone(actual)
 	}
 	return
 }
 
 // Remove removes the provided key from the cache.
 func (tc *TimedCache) Remove(key interface{}) (present bool) {
-	var k, v interface{}
 	tc.lock.Lock()
 	tc.removeExpired()
-	present = tc.cache.Remove(key)
+	present = tc.removeFor(key, EvictManual)
+	pending := tc.takeEvicted()
 	tc.lock.Unlock()
 	// invoke callback outside of critical section
-	if tc.onEvictedCB != nil {
-		tc.onEvictedCB(k, v)
-	}
+	tc.notifyEvicted(pending)
 	return
 }
 
 // Resize changes the cache size.
 func (tc *TimedCache) Resize(size int) (evicted int) {
-	var k, v interface{}
 	tc.lock.Lock()
 	tc.removeExpired()
 	evicted = tc.cache.Resize(size)
+	tc.size = size
+	pending := tc.takeEvicted()
 	tc.lock.Unlock()
 	// invoke callback outside of critical section
-	if tc.onEvictedCB != nil {
-		tc.onEvictedCB(k, v)
+	tc.notifyEvicted(pending)
+	return
+}
+
+// Reserve frees up room for n upcoming insertions. Expired entries are removed
+// first, and if the cache is still short of n free slots, the oldest live
+// entries are evicted. Returns the number of live entries evicted.
+func (tc *TimedCache) Reserve(n int) (evicted int) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	if n > tc.size {
+		n = tc.size
 	}
+	for short := tc.cache.Len() + n - tc.size; evicted < short; evicted++ {
+		tc.cache.RemoveOldest()
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
 	return
 }
 
 // RemoveOldest removes the oldest item from the cache.
 func (tc *TimedCache) RemoveOldest() (key, value interface{}, ok bool) {
-	var k, v interface{}
 	tc.lock.Lock()
 	tc.removeExpired()
+	tc.reason = EvictManual
 	key, value, ok = tc.cache.RemoveOldest()
+	tc.reason = EvictCapacity
+	var found lookupResult
 	if ok {
-		value = value.(timedEntry).value
+		entry := value.(*timedEntry)
+		found = lookupResult{value: entry.value, lazy: entry.lazy, codec: entry.codec, clone: tc.cloner}
 	}
+	pending := tc.takeEvicted()
 	tc.lock.Unlock()
 	// invoke callback outside of critical section
-	if tc.onEvictedCB != nil {
-		tc.onEvictedCB(k, v)
+	tc.notifyEvicted(pending)
+	if !ok {
+		return nil, nil, false
 	}
-	return
+	var err error
+	if value, err = found.resolve(); err != nil {
+		return nil, nil, false
+	}
+	return key, value, true
 }
 
 // GetOldest returns the oldest entry
 func (tc *TimedCache) GetOldest() (key, value interface{}, ok bool) {
 	tc.lock.Lock()
-	defer tc.lock.Unlock()
 	tc.removeExpired()
 	key, value, ok = tc.cache.GetOldest()
+	var found lookupResult
 	if ok {
-		value = value.(timedEntry).value
+		entry := value.(*timedEntry)
+		found = lookupResult{value: entry.value, lazy: entry.lazy, codec: entry.codec, clone: tc.cloner}
 	}
-	return
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	if !ok {
+		return nil, nil, false
+	}
+	var err error
+	if value, err = found.resolve(); err != nil {
+		return nil, nil, false
+	}
+	return key, value, true
 }
 
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 func (tc *TimedCache) Keys() []interface{} {
 	tc.lock.Lock()
-	defer tc.lock.Unlock()
 	tc.removeExpired()
-	return tc.cache.Keys()
+	keys := tc.cache.Keys()
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return keys
+}
+
+// WithDeterministicEviction switches the cache to strict insertion order
+// eviction: lookups no longer mark entries as recently used, so RemoveOldest and
+// capacity evictions always drop the earliest inserted entry, re-adding a key
+// counting as a new insertion. Entries expiring at the same second expire in
+// insertion order regardless of the mode. It is meant for reproducible tests
+// of eviction callbacks only, and has no effect on cost-aware eviction.
+func (tc *TimedCache) WithDeterministicEviction() *TimedCache {
+	tc.lock.Lock()
+	tc.fifo = true
+	tc.lock.Unlock()
+	return tc
+}
+
+// WithUnderlying invokes fn with the underlying LRU while holding the cache
+// lock, for advanced callers needing features TimedCache does not surface.
+//
+// Use with care: the LRU holds the values wrapped in unexported entries, and
+// neither requests through it nor the returned values honor expiration. Removals
+// through the LRU are reported to the eviction callback and keep the expiry
+// queue consistent, but entries must never be added directly. fn must not call
+// back into the TimedCache, which would deadlock.
+func (tc *TimedCache) WithUnderlying(fn func(cache *simplelru.LRU)) {
+	tc.lock.Lock()
+	fn(tc.cache)
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
 }
 
 // Len returns the number of items in the cache.
 func (tc *TimedCache) Len() int {
 	tc.lock.Lock()
-	defer tc.lock.Unlock()
 	tc.removeExpired()
-	return tc.cache.Len()
+	n := tc.cache.Len()
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return n
+}
+
+// RefreshTTL extends the lifetime of each of the given keys still live in the
+// cache to ttl from now, without updating their recent-ness. Missing or expired
+// keys are skipped. Returns the number of refreshed keys.
+func (tc *TimedCache) RefreshTTL(keys []interface{}, ttl time.Duration) (refreshed int) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	expiresAt := tc.calcExpireTime(int64(ttl))
+	for _, key := range keys {
+		if entry, ok := tc.peek(key); ok {
+			entry.expiresAt = expiresAt
+			heap.Fix(&tc.expiry, entry.index)
+			tc.logOp(opExpiry, key, entry)
+			refreshed++
+		}
+	}
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return
+}
+
+// WithReadWriteTTL separates the lifetime of new entries into a write TTL,
+// during which they are fresh, and a longer read TTL, until which they are
+// still served as stale. The write TTL is capped to the read TTL.
+func (tc *TimedCache) WithReadWriteTTL(readTTL, writeTTL time.Duration) *TimedCache {
+	if writeTTL > readTTL {
+		writeTTL = readTTL
+	}
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	tc.ttl = int64(readTTL)
+	tc.wttl = int64(writeTTL)
+	return tc
 }
 
 // Ttl returns the number of seconds each item is allowed to live (except if
@@ -282,5 +1003,44 @@ func (tc *TimedCache) Len() int {
 func (tc *TimedCache) Ttl() int64 {
 	tc.lock.RLock()
 	defer tc.lock.RUnlock()
-	return tc.ttl
+	return tc.ttl / int64(time.Second)
+}
+
+// WithCloseOnGC registers a finalizer closing the cache if it is garbage
+// collected without having been closed, so that forgotten background workers do
+// not leak. Each such rescue logs a warning, as it points to a missing Close:
+// finalizers run at the discretion of the runtime, so relying on this safety
+// net is discouraged.
+func (tc *TimedCache) WithCloseOnGC() *TimedCache {
+	runtime.SetFinalizer(tc, func(tc *TimedCache) {
+		select {
+		case <-tc.quit:
+		default:
+			log.Warn("Timed cache garbage collected without being closed")
+			tc.Close()
+		}
+	})
+	return tc
+}
+
+// Close stops any background workers of the cache and drops its contents,
+// reporting them to the eviction callbacks as manual removals. Afterwards the
+// cache is inert: insertions are rejected, so lookups always miss, and since
+// nothing is left to remove, removals report nothing removed. HealthCheck tells
+// a closed cache apart.
+func (tc *TimedCache) Close() {
+	tc.closeOnce.Do(func() {
+		close(tc.quit)
+		tc.Purge()
+	})
+}
+
+// closed returns whether the cache has been closed.
+func (tc *timedCache) closed() bool {
+	select {
+	case <-tc.quit:
+		return true
+	default:
+		return false
+	}
 }
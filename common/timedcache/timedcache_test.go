@@ -0,0 +1,647 @@
+package timedcache
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// testClock is a manually advanced clock for driving cache expiration. It
+// counts in seconds, reported to the cache in nanoseconds.
+type testClock struct {
+	time int64
+}
+
+func (c *testClock) now() int64 { return c.time * int64(time.Second) }
+
+// newTestCache creates a cache driven by a manual clock, recording the keys
+// reported to the eviction callback.
+func newTestCache(t testing.TB, size, ttl int) (*TimedCache, *testClock, *[]interface{}) {
+	var evicted []interface{}
+	tc, err := NewWithEvict(size, ttl, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	clock := &testClock{time: 1000}
+	tc.now = clock.now
+	return tc, clock, &evicted
+}
+
+// checkExpirySync verifies that the expiry queue tracks exactly the entries
+// held by the LRU.
+func checkExpirySync(t *testing.T, tc *TimedCache) {
+	t.Helper()
+	if have, want := len(tc.expiry), tc.cache.Len(); have != want {
+		t.Fatalf("expiry queue out of sync: have %d entries, want %d", have, want)
+	}
+	for i, entry := range tc.expiry {
+		if entry.index != i {
+			t.Fatalf("entry %v has index %d, want %d", entry.key, entry.index, i)
+		}
+		val, ok := tc.cache.Peek(entry.key)
+		if !ok || val.(*timedEntry) != entry {
+			t.Fatalf("queued entry %v not present in the LRU", entry.key)
+		}
+	}
+}
+
+func TestExpiryOrder(t *testing.T) {
+	tc, clock, evicted := newTestCache(t, 10, 10)
+
+	// Insert keys one second apart, out of key order
+	for _, key := range []int{3, 1, 4, 2} {
+		tc.Add(key, key)
+		clock.time++
+	}
+	// Refreshing a key moves it to the back of the expiry order
+	tc.Add(3, 3)
+	checkExpirySync(t, tc)
+
+	clock.time += 10
+	if have := tc.Len(); have != 1 {
+		t.Fatalf("live entries mismatch: have %d, want 1", have)
+	}
+	want := []interface{}{1, 4, 2}
+	if fmt.Sprint(*evicted) != fmt.Sprint(want) {
+		t.Fatalf("expiry order mismatch: have %v, want %v", *evicted, want)
+	}
+	if _, ok := tc.Get(3); !ok {
+		t.Fatalf("refreshed key expired early")
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestExpirySync(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 8, 5)
+
+	for i := 0; i < 16; i++ {
+		tc.Add(i, i)
+		clock.time++
+	}
+	checkExpirySync(t, tc)
+
+	tc.Remove(12)
+	checkExpirySync(t, tc)
+
+	tc.Resize(4)
+	checkExpirySync(t, tc)
+
+	tc.RemoveOldest()
+	checkExpirySync(t, tc)
+
+	clock.time += 3
+	tc.Get(13)
+	checkExpirySync(t, tc)
+
+	tc.Purge()
+	checkExpirySync(t, tc)
+}
+
+func BenchmarkRemoveExpired(b *testing.B) {
+	for _, size := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("size-%d", size), func(b *testing.B) {
+			// Stagger the entries so exactly one of them expires every second
+			tc, clock, _ := newTestCache(b, size, size)
+			for i := 0; i < size; i++ {
+				tc.Add(i, i)
+				clock.time++
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				clock.time++
+				tc.removeExpired()
+				tc.add(size+i, i)
+			}
+		})
+	}
+}
+
+func TestRefreshTTL(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+	for i := 0; i < 4; i++ {
+		tc.Add(i, i)
+	}
+	clock.time += 5
+	if have := tc.RefreshTTL([]interface{}{1, 3, 7}, 20*time.Second); have != 2 {
+		t.Fatalf("refreshed count mismatch: have %d, want 2", have)
+	}
+	checkExpirySync(t, tc)
+	for i := 0; i < 4; i++ {
+		val, _ := tc.cache.Peek(i)
+		want := int64(1010)
+		if i == 1 || i == 3 {
+			want = 1025
+		}
+		if have := val.(*timedEntry).expiresAt / int64(time.Second); have != want {
+			t.Errorf("key %d: expiry mismatch: have %d, want %d", i, have, want)
+		}
+	}
+	// Expired keys are not resurrected
+	clock.time += 6
+	if have := tc.RefreshTTL([]interface{}{0, 1}, 20*time.Second); have != 1 {
+		t.Fatalf("refreshed count mismatch after expiry: have %d, want 1", have)
+	}
+	if tc.Contains(0) {
+		t.Fatalf("expired key refreshed")
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestReserve(t *testing.T) {
+	tc, clock, evicted := newTestCache(t, 8, 10)
+	for i := 0; i < 8; i++ {
+		tc.Add(i, i)
+		if i == 1 {
+			clock.time += 5
+		}
+	}
+	// Reserving in a full cache evicts the oldest live entries
+	if have := tc.Reserve(3); have != 3 {
+		t.Fatalf("evicted count mismatch: have %d, want 3", have)
+	}
+	if want := []interface{}{0, 1, 2}; fmt.Sprint(*evicted) != fmt.Sprint(want) {
+		t.Fatalf("evicted keys mismatch: have %v, want %v", *evicted, want)
+	}
+	checkExpirySync(t, tc)
+
+	// Expired entries are reclaimed before any live one
+	clock.time += 6
+	for i := 8; i < 11; i++ {
+		tc.Add(i, i)
+	}
+	*evicted = nil
+	clock.time += 5
+	if have := tc.Reserve(3); have != 0 {
+		t.Fatalf("evicted live count mismatch: have %d, want 0", have)
+	}
+	if have := len(*evicted); have != 5 {
+		t.Fatalf("expired count mismatch: have %d, want 5", have)
+	}
+	// Reserving more than the capacity empties the cache
+	if have := tc.Reserve(100); have != 3 {
+		t.Fatalf("evicted count mismatch: have %d, want 3", have)
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestReadWriteTTL(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+	tc.WithReadWriteTTL(20*time.Second, 5*time.Second)
+	tc.Add("key", "value")
+
+	tests := []struct {
+		elapsed int64
+		fresh   bool
+		ok      bool
+	}{
+		{elapsed: 0, fresh: true, ok: true},   // just written
+		{elapsed: 5, fresh: true, ok: true},   // end of the write TTL
+		{elapsed: 6, fresh: false, ok: true},  // stale but served
+		{elapsed: 20, fresh: false, ok: true}, // end of the read TTL
+		{elapsed: 21, fresh: false, ok: false},
+	}
+	for _, tt := range tests {
+		clock.time = 1000 + tt.elapsed
+		value, fresh, ok := tc.GetFresh("key")
+		if fresh != tt.fresh || ok != tt.ok {
+			t.Errorf("elapsed %d: have fresh=%v ok=%v, want fresh=%v ok=%v", tt.elapsed, fresh, ok, tt.fresh, tt.ok)
+		}
+		if ok && value != "value" {
+			t.Errorf("elapsed %d: value mismatch: have %v, want %v", tt.elapsed, value, "value")
+		}
+	}
+	if tc.Contains("key") {
+		t.Fatalf("entry served past its read TTL")
+	}
+}
+
+func TestAddWithDeadline(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+
+	// A future deadline outlives the cache ttl
+	tc.AddWithDeadline("token", 1, time.Unix(clock.time+30, 0))
+	clock.time += 30
+	if _, ok := tc.Get("token"); !ok {
+		t.Fatalf("entry expired before its deadline")
+	}
+	clock.time++
+	if _, ok := tc.Get("token"); ok {
+		t.Fatalf("entry served past its deadline")
+	}
+	// A past deadline expires immediately, dropping any previous value
+	tc.Add("token", 2)
+	if tc.AddWithDeadline("token", 3, time.Unix(clock.time-1, 0)) {
+		t.Fatalf("past deadline reported an eviction")
+	}
+	if _, ok := tc.Get("token"); ok {
+		t.Fatalf("entry with past deadline served")
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestGetOrAdd(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+
+	// Absent keys are inserted with the given ttl
+	actual, loaded := tc.GetOrAdd("key", 1, 30*time.Second)
+	if loaded || actual != 1 {
+		t.Fatalf("absent path mismatch: have %v loaded=%v, want 1 loaded=false", actual, loaded)
+	}
+	// Present keys return the existing value
+	actual, loaded = tc.GetOrAdd("key", 2, 30*time.Second)
+	if !loaded || actual != 1 {
+		t.Fatalf("present path mismatch: have %v loaded=%v, want 1 loaded=true", actual, loaded)
+	}
+	// The per-entry ttl outlives the cache ttl
+	clock.time += 30
+	if value, ok := tc.Get("key"); !ok || value != 1 {
+		t.Fatalf("entry expired before its own ttl")
+	}
+	clock.time++
+	if actual, loaded := tc.GetOrAdd("key", 3, time.Second); loaded || actual != 3 {
+		t.Fatalf("expired key not replaced: have %v loaded=%v", actual, loaded)
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestBatchExpireNotify(t *testing.T) {
+	tc, clock, evicted := newTestCache(t, 10, 10)
+	var batches [][]interface{}
+	tc.WithBatchExpireNotify(func(keys []interface{}) {
+		batches = append(batches, keys)
+	})
+	for i := 0; i < 5; i++ {
+		tc.Add(i, i)
+		if i == 2 {
+			clock.time += 5
+		}
+	}
+	// A single sweep expiring several keys reports them in one batch
+	clock.time += 6
+	tc.Len()
+	if len(batches) != 1 {
+		t.Fatalf("batch count mismatch: have %d, want 1", len(batches))
+	}
+	if want := []interface{}{0, 1, 2}; len(batches[0]) != len(want) {
+		t.Fatalf("batched keys mismatch: have %v, want %v", batches[0], want)
+	}
+	// The per-entry callback still fires for each of them
+	if have := len(*evicted); have != 3 {
+		t.Fatalf("per-entry eviction count mismatch: have %d, want 3", have)
+	}
+	// Sweeps expiring nothing, and plain removals, do not notify
+	tc.Remove(3)
+	tc.Len()
+	if len(batches) != 1 {
+		t.Fatalf("empty sweep notified: have %d batches, want 1", len(batches))
+	}
+	clock.time += 5
+	tc.Len()
+	if len(batches) != 2 || fmt.Sprint(batches[1]) != fmt.Sprint([]interface{}{4}) {
+		t.Fatalf("second batch mismatch: have %v", batches)
+	}
+}
+
+func TestBatchExpireNotifyUnset(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+	var batches int
+	tc.WithBatchExpireNotify(func(keys []interface{}) { batches++ })
+	tc.Add(0, 0)
+	clock.time += 11
+
+	// Unsetting the callback after a sweep neither loses nor crashes its batch
+	tc.lock.Lock()
+	tc.removeExpired()
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	tc.WithBatchExpireNotify(nil)
+	tc.notifyEvicted(pending)
+	if batches != 1 {
+		t.Fatalf("batch count mismatch: have %d, want 1", batches)
+	}
+	tc.Add(1, 1)
+	clock.time += 11
+	tc.Len()
+	if batches != 1 {
+		t.Fatalf("unset callback notified: have %d batches, want 1", batches)
+	}
+}
+
+func TestWithUnderlying(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 10, 10)
+	for i := 0; i < 4; i++ {
+		tc.Add(i, i)
+	}
+	// Introspect and remove through the LRU, without mismatching the wrappers
+	var oldest interface{}
+	tc.WithUnderlying(func(cache *simplelru.LRU) {
+		oldest, _, _ = cache.GetOldest()
+		cache.Remove(oldest)
+	})
+	if oldest != 0 {
+		t.Fatalf("oldest key mismatch: have %v, want 0", oldest)
+	}
+	if fmt.Sprint(*evicted) != fmt.Sprint([]interface{}{0}) {
+		t.Fatalf("eviction callback mismatch: have %v, want [0]", *evicted)
+	}
+	if tc.Len() != 3 || tc.Contains(0) {
+		t.Fatalf("removal through the LRU not visible")
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestDeterministicEviction(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 4, 10)
+	tc.WithDeterministicEviction()
+	for i := 0; i < 4; i++ {
+		tc.Add(i, i)
+	}
+	// Lookups do not reorder the entries
+	tc.Get(0)
+	tc.Get(1)
+	tc.Contains(2)
+
+	// Capacity eviction follows insertion order, re-adds counting as inserts
+	tc.Add(0, 0)
+	tc.Add(4, 4)
+	tc.Add(5, 5)
+	if want := []interface{}{1, 2}; fmt.Sprint(*evicted) != fmt.Sprint(want) {
+		t.Fatalf("eviction order mismatch: have %v, want %v", *evicted, want)
+	}
+	if key, _, _ := tc.RemoveOldest(); key != 3 {
+		t.Fatalf("oldest key mismatch: have %v, want 3", key)
+	}
+	if want := []interface{}{0, 4, 5}; fmt.Sprint(tc.Keys()) != fmt.Sprint(want) {
+		t.Fatalf("remaining keys mismatch: have %v, want %v", tc.Keys(), want)
+	}
+}
+
+func TestExpiryTieOrder(t *testing.T) {
+	tc, clock, evicted := newTestCache(t, 64, 10)
+	for i := 0; i < 32; i++ {
+		tc.Add(i, i)
+	}
+	clock.time += 11
+	if tc.Len() != 0 || len(*evicted) != 32 {
+		t.Fatalf("expired count mismatch: have %d, want 32", len(*evicted))
+	}
+	for i, key := range *evicted {
+		if key != i {
+			t.Fatalf("entries expiring together out of insertion order: %v", *evicted)
+		}
+	}
+}
+
+func TestGetConsistent(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 60)
+	tc.Add("old", 1)
+	clock.time += 10
+	tc.Add("new", 2)
+	clock.time += 5
+
+	tests := []struct {
+		key          string
+		maxStaleness time.Duration
+		ok           bool
+	}{
+		{key: "new", maxStaleness: 10 * time.Second, ok: true},
+		{key: "new", maxStaleness: 5 * time.Second, ok: true}, // written exactly at the bound
+		{key: "new", maxStaleness: 4 * time.Second, ok: false},
+		{key: "old", maxStaleness: 20 * time.Second, ok: true},
+		{key: "old", maxStaleness: 10 * time.Second, ok: false},
+	}
+	for _, tt := range tests {
+		if _, ok := tc.GetConsistent(tt.key, tt.maxStaleness); ok != tt.ok {
+			t.Errorf("key %s, staleness %v: hit mismatch: have %v, want %v", tt.key, tt.maxStaleness, ok, tt.ok)
+		}
+	}
+	// Stale entries are still cached for laxer readers, until rewritten
+	if _, ok := tc.Get("old"); !ok {
+		t.Fatalf("stale entry removed")
+	}
+	tc.Add("old", 3)
+	if value, ok := tc.GetConsistent("old", time.Second); !ok || value != 3 {
+		t.Fatalf("rewritten entry mismatch: have %v ok=%v, want 3", value, ok)
+	}
+}
+
+func TestGetWithDeadline(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 60)
+	tc.Add("key", "value")
+
+	if value, ok, timedOut := tc.GetWithDeadline("key", time.Now().Add(time.Second)); !ok || timedOut || value != "value" {
+		t.Fatalf("uncontended lookup mismatch: have %v ok=%v timedOut=%v", value, ok, timedOut)
+	}
+	// A held write lock makes the lookup give up instead of blocking
+	tc.lock.Lock()
+	done := make(chan bool)
+	go func() {
+		_, ok, timedOut := tc.GetWithDeadline("key", time.Now().Add(20*time.Millisecond))
+		done <- !ok && timedOut
+	}()
+	select {
+	case timedOut := <-done:
+		if !timedOut {
+			t.Errorf("contended lookup did not time out")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("contended lookup blocked past its deadline")
+	}
+	tc.lock.Unlock()
+}
+
+func TestPurgeOlderThan(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 60)
+	reasons := make(map[interface{}]EvictReason)
+	tc.WithEvictReasonCallback(func(key, value interface{}, reason EvictReason) {
+		reasons[key] = reason
+	})
+	// Entries inserted 30, 20, 10 and 0 seconds ago
+	for i := 0; i < 4; i++ {
+		tc.Add(i, i)
+		clock.time += 10
+	}
+	clock.time -= 10
+	if have := tc.PurgeOlderThan(15 * time.Second); have != 2 {
+		t.Fatalf("purged count mismatch: have %d, want 2", have)
+	}
+	for i := 0; i < 4; i++ {
+		if have, want := tc.Contains(i), i >= 2; have != want {
+			t.Errorf("key %d: presence mismatch: have %v, want %v", i, have, want)
+		}
+	}
+	if want := map[interface{}]EvictReason{0: EvictManual, 1: EvictManual}; fmt.Sprint(reasons) != fmt.Sprint(want) {
+		t.Fatalf("eviction reasons mismatch: have %v, want %v", reasons, want)
+	}
+	checkExpirySync(t, tc)
+
+	// Other removals report their own reasons
+	tc.Resize(1)
+	clock.time += 61
+	tc.Len()
+	if reasons[2] != EvictCapacity || reasons[3] != EvictExpired {
+		t.Fatalf("eviction reasons mismatch: have %v", reasons)
+	}
+}
+
+func TestGetWithFallback(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+
+	tests := []struct {
+		primary, fallback bool
+		want              interface{}
+	}{
+		{false, false, nil},
+		{true, false, "primary"},
+		{false, true, "fallback"},
+		{true, true, "primary"},
+	}
+	for i, tt := range tests {
+		tc.Purge()
+		if tt.primary {
+			tc.Add("hash", "primary")
+		}
+		if tt.fallback {
+			tc.Add("alias", "fallback")
+		}
+		value, ok := tc.GetWithFallback("hash", "alias")
+		if ok != (tt.want != nil) || value != tt.want {
+			t.Errorf("test %d: have %v, %v, want %v", i, value, ok, tt.want)
+		}
+	}
+	// An expired primary falls back to the alias
+	tc.Purge()
+	tc.Add("hash", "primary")
+	clock.time += 5
+	tc.Add("alias", "fallback")
+	clock.time += 6
+	if value, ok := tc.GetWithFallback("hash", "alias"); !ok || value != "fallback" {
+		t.Fatalf("expired primary mismatch: have %v, %v, want %v", value, ok, "fallback")
+	}
+}
+
+func TestExpiryBoundary(t *testing.T) {
+	const start = int64(1000 * time.Second)
+
+	seconds, err := New(10, 1)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	duration, err := NewWithTTL(10, 1500*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	tests := []struct {
+		tc  *TimedCache
+		ttl time.Duration
+	}{
+		{seconds, time.Second},
+		{duration, 1500 * time.Millisecond},
+	}
+	for i, tt := range tests {
+		now := start
+		tt.tc.now = func() int64 { return now }
+		tt.tc.Add("key", "value")
+
+		// Entries are live up to and including their ttl, but not a nanosecond longer
+		now = start + int64(tt.ttl) - 1
+		if _, ok := tt.tc.Peek("key"); !ok {
+			t.Fatalf("test %d: entry expired just before its ttl", i)
+		}
+		now = start + int64(tt.ttl)
+		if _, ok := tt.tc.Peek("key"); !ok {
+			t.Fatalf("test %d: entry expired at its ttl", i)
+		}
+		now = start + int64(tt.ttl) + 1
+		if _, ok := tt.tc.Peek("key"); ok {
+			t.Fatalf("test %d: entry live just after its ttl", i)
+		}
+	}
+}
+
+func TestRejectWrappedValue(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.Add("key", "value")
+
+	// Entry wrappers leaked through the underlying LRU are not stored
+	var leaked interface{}
+	tc.WithUnderlying(func(lru *simplelru.LRU) {
+		leaked, _ = lru.Peek("key")
+	})
+	for _, value := range []interface{}{leaked, *leaked.(*timedEntry)} {
+		tc.Add("other", value)
+		if _, ok := tc.Peek("other"); ok {
+			t.Fatalf("wrapped value %T stored", value)
+		}
+		tc.Add("key", value)
+		if have, _ := tc.Peek("key"); have != "value" {
+			t.Fatalf("wrapped value %T replaced the live one: have %v", value, have)
+		}
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestClosedCache(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 10, 10)
+	tc.Add("a", 1)
+	tc.Add("b", 2)
+	tc.Close()
+
+	// Closing drops the contents, reporting them as removed
+	if len(*evicted) != 2 {
+		t.Fatalf("closing evictions mismatch: have %v, want 2 keys", *evicted)
+	}
+	// Insertions are rejected and lookups miss without panicking
+	if evicted := tc.Add("c", 3); evicted {
+		t.Fatalf("rejected insertion reported an eviction")
+	}
+	if ok, _ := tc.ContainsOrAdd("d", 4); ok {
+		t.Fatalf("closed cache reported containing a key")
+	}
+	tc.Transaction(func(tx *Txn) { tx.Set("e", 5) })
+	for _, key := range []string{"a", "c", "d", "e"} {
+		if _, ok := tc.Get(key); ok {
+			t.Fatalf("closed cache hit %q", key)
+		}
+		if _, ok := tc.Peek(key); ok {
+			t.Fatalf("closed cache peek hit %q", key)
+		}
+	}
+	if tc.Len() != 0 || tc.Remove("a") {
+		t.Fatalf("closed cache not empty: %d entries", tc.Len())
+	}
+	if err := tc.ReplayOpLog(strings.NewReader(`{"op":"add","key":"f","value":6,"expiresAt":9000000000000000000}`)); err != ErrClosed {
+		t.Fatalf("replay error mismatch: have %v, want %v", err, ErrClosed)
+	}
+	tc.Close() // closing twice is harmless
+}
+
+func TestCloseOnGC(t *testing.T) {
+	// Start a worker on a cache which is dropped without being closed
+	stream := func() <-chan Summary {
+		tc, err := New(10, 60)
+		if err != nil {
+			t.Fatalf("failed to create cache: %v", err)
+		}
+		return tc.WithCloseOnGC().WithSnapshotStream(time.Millisecond, 1)
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case _, ok := <-stream:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("worker of unreachable cache still running")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
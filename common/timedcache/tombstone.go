@@ -0,0 +1,45 @@
+package timedcache
+
+import "time"
+
+// SoftDelete removes a key from the cache, leaving a tombstone behind for
+// tombstoneTTL. While the tombstone is active, any write of the key is
+// suppressed, so late arriving stale writes cannot resurrect it. Returns
+// whether the key was present.
+func (tc *TimedCache) SoftDelete(key interface{}, tombstoneTTL time.Duration) (present bool) {
+	tc.lock.Lock()
+	tc.removeExpired()
+	present = tc.removeFor(key, EvictManual)
+
+	// Drop the tombstones which are over before adding a new one
+	now := tc.now()
+	for k, expiresAt := range tc.tombstones {
+		if expiresAt < now {
+			delete(tc.tombstones, k)
+		}
+	}
+	if tc.tombstones == nil {
+		tc.tombstones = make(map[interface{}]int64)
+	}
+	tc.tombstones[key] = tc.calcExpireTime(int64(tombstoneTTL))
+
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return
+}
+
+// tombstoned returns whether a key has an active tombstone, dropping it if
+// it is over. It must be called with the lock held.
+func (tc *timedCache) tombstoned(key interface{}) bool {
+	expiresAt, ok := tc.tombstones[key]
+	if !ok {
+		return false
+	}
+	if expiresAt < tc.now() {
+		delete(tc.tombstones, key)
+		return false
+	}
+	return true
+}
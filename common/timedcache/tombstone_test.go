@@ -0,0 +1,46 @@
+package timedcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 100)
+	tc.Add("key", "v1")
+
+	if !tc.SoftDelete("key", 10*time.Second) {
+		t.Fatalf("soft delete reported key absent")
+	}
+	if _, ok := tc.Get("key"); ok {
+		t.Fatalf("soft deleted key still served")
+	}
+	// Stale writes are suppressed during the tombstone window
+	tc.Add("key", "stale")
+	tc.ContainsOrAdd("key", "stale")
+	tc.PeekOrAdd("key", "stale")
+	if actual, loaded := tc.GetOrAdd("key", "stale", time.Minute); actual != nil || loaded {
+		t.Fatalf("tombstoned key added through GetOrAdd: %v", actual)
+	}
+	clock.time += 10
+	tc.Add("key", "stale")
+	if _, ok := tc.Get("key"); ok {
+		t.Fatalf("stale write resurrected a tombstoned key")
+	}
+	// Writes succeed again once the tombstone expires
+	clock.time++
+	tc.Add("key", "v2")
+	if value, ok := tc.Get("key"); !ok || value != "v2" {
+		t.Fatalf("write after tombstone expiry mismatch: have %v", value)
+	}
+	if len(tc.tombstones) != 0 {
+		t.Fatalf("expired tombstone retained")
+	}
+	// Other keys are unaffected by tombstones
+	tc.SoftDelete("key", 10*time.Second)
+	tc.Add("other", 1)
+	if !tc.Contains("other") {
+		t.Fatalf("unrelated key suppressed")
+	}
+	checkExpirySync(t, tc)
+}
@@ -0,0 +1,86 @@
+package timedcache
+
+// Txn is a set of changes to a cache, applied atomically by Transaction.
+type Txn struct {
+	tc *timedCache
+
+	ops    []txnOp                     // Changes in the order they were made
+	writes map[interface{}]interface{} // Latest pending value of each changed key
+	dels   map[interface{}]struct{}    // Keys pending deletion
+}
+
+// txnOp is a single change of a transaction, a deletion if del is set.
+type txnOp struct {
+	key, value interface{}
+	del        bool
+}
+
+// Get returns the live value of a key as the transaction sees it, including
+// its own pending changes, without updating its recent-ness.
+func (tx *Txn) Get(key interface{}) (value interface{}, ok bool) {
+	if _, deleted := tx.dels[key]; deleted {
+		return nil, false
+	}
+	if value, ok := tx.writes[key]; ok {
+		return value, true
+	}
+	entry, ok := tx.tc.peek(key)
+	if !ok {
+		return nil, false
+	}
+	found := lookupResult{value: entry.value, lazy: entry.lazy, codec: entry.codec, clone: tx.tc.cloner}
+	if value, err := found.resolve(); err == nil {
+		return value, true
+	}
+	return nil, false
+}
+
+// Set adds or replaces the value of a key on commit, with the cache's ttl.
+func (tx *Txn) Set(key, value interface{}) {
+	tx.ops = append(tx.ops, txnOp{key: key, value: value})
+	tx.writes[key] = value
+	delete(tx.dels, key)
+}
+
+// Delete removes a key on commit.
+func (tx *Txn) Delete(key interface{}) {
+	tx.ops = append(tx.ops, txnOp{key: key, del: true})
+	tx.dels[key] = struct{}{}
+	delete(tx.writes, key)
+}
+
+// Transaction runs fn and commits the changes it made through tx in one go,
+// under a single critical section, so that readers observe either all or none
+// of them. The cache lock is held while fn runs, so fn must only access the
+// cache through tx, and should be quick. Should fn panic, its changes are
+// discarded and the lock is released before the panic propagates.
+func (tc *TimedCache) Transaction(fn func(tx *Txn)) {
+	pending := tc.transact(fn)
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+}
+
+// transact runs fn and applies its changes under the lock, returning the
+// evictions to notify.
+func (tc *timedCache) transact(fn func(tx *Txn)) evictions {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	tc.removeExpired()
+
+	tx := &Txn{
+		tc:     tc,
+		writes: make(map[interface{}]interface{}),
+		dels:   make(map[interface{}]struct{}),
+	}
+	// The changes are only staged until fn returns, so a panic discards them
+	fn(tx)
+	for _, op := range tx.ops {
+		if op.del {
+			tc.removeFor(op.key, EvictManual)
+		} else {
+			tc.add(op.key, op.value)
+		}
+	}
+	return tc.takeEvicted()
+}
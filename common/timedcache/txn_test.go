@@ -0,0 +1,92 @@
+package timedcache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.Add("a", 1)
+	tc.Add("b", 1)
+
+	tc.Transaction(func(tx *Txn) {
+		tx.Set("a", 2)
+		if value, ok := tx.Get("a"); !ok || value != 2 {
+			t.Fatalf("pending write not visible in transaction: have %v, %v", value, ok)
+		}
+		tx.Delete("b")
+		if _, ok := tx.Get("b"); ok {
+			t.Fatalf("pending deletion not visible in transaction")
+		}
+		tx.Set("c", 3)
+
+		// Nothing is applied before the commit
+		val, _ := tc.cache.Peek("a")
+		if val.(*timedEntry).value != 1 {
+			t.Fatalf("write applied before commit")
+		}
+	})
+	if value, _ := tc.Peek("a"); value != 2 {
+		t.Fatalf("committed write mismatch: have %v, want 2", value)
+	}
+	if _, ok := tc.Peek("b"); ok {
+		t.Fatalf("committed deletion not applied")
+	}
+	if value, _ := tc.Peek("c"); value != 3 {
+		t.Fatalf("committed insertion mismatch: have %v, want 3", value)
+	}
+	checkExpirySync(t, tc)
+}
+
+func TestTransactionAtomicity(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.Add("x", 0)
+	tc.Add("y", 0)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 1000; i++ {
+			tc.Transaction(func(tx *Txn) {
+				tx.Set("x", i)
+				tx.Set("y", i)
+			})
+		}
+	}()
+	// Snapshots see both keys from the same transaction, never a partial update
+	for i := 0; i < 1000; i++ {
+		entries := tc.TopK(2)
+		if len(entries) != 2 || entries[0].Value != entries[1].Value {
+			t.Fatalf("partial transaction observed: %v", entries)
+		}
+	}
+	wg.Wait()
+}
+
+func TestTransactionPanic(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+	tc.Add("a", 1)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("panic not propagated")
+			}
+		}()
+		tc.Transaction(func(tx *Txn) {
+			tx.Set("a", 2)
+			tx.Set("b", 2)
+			panic("callback failure")
+		})
+	}()
+	// The lock is released and the staged changes are discarded
+	if value, _ := tc.Peek("a"); value != 1 {
+		t.Fatalf("value changed by a panicked transaction: have %v, want 1", value)
+	}
+	if _, ok := tc.Peek("b"); ok {
+		t.Fatalf("insertion applied by a panicked transaction")
+	}
+	checkExpirySync(t, tc)
+}
@@ -0,0 +1,31 @@
+package timedcache
+
+// GetValid is like Get, but only returns the value if valid approves it, for
+// values which may turn stale before their ttl, such as headers superseded by
+// a reorg. Rejected values are removed from the cache, reported to the
+// eviction callbacks as manual removals, and the lookup counts as a miss. The
+// validator runs outside of the critical section; should the entry be
+// replaced meanwhile, the replacement is kept.
+func (tc *TimedCache) GetValid(key interface{}, valid func(value interface{}) bool) (value interface{}, ok bool) {
+	found, ok := tc.get(key, "")
+	if !ok {
+		return nil, false
+	}
+	value, err := found.resolve()
+	if err == nil && valid(value) {
+		return value, true
+	}
+	tc.lock.Lock()
+	if entry, live := tc.peek(key); live && entry.seq == found.seq {
+		tc.removeFor(key, EvictManual)
+	}
+	// The lookup was accounted as a hit, revise it
+	tc.stats.Hits--
+	tc.stats.Misses++
+
+	pending := tc.takeEvicted()
+	tc.lock.Unlock()
+	// invoke callback outside of critical section
+	tc.notifyEvicted(pending)
+	return nil, false
+}
@@ -0,0 +1,52 @@
+package timedcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetValid(t *testing.T) {
+	tc, _, evicted := newTestCache(t, 10, 10)
+	tc.Add("head", 100)
+
+	// Values passing the validator are served as hits
+	atLeast := func(min int) func(value interface{}) bool {
+		return func(value interface{}) bool { return value.(int) >= min }
+	}
+	if have, ok := tc.GetValid("head", atLeast(100)); !ok || have != 100 {
+		t.Fatalf("valid value mismatch: have %v (found %v), want %v", have, ok, 100)
+	}
+	// Values failing it are removed and reported as misses
+	if have, ok := tc.GetValid("head", atLeast(101)); ok {
+		t.Fatalf("invalid value served: %v", have)
+	}
+	if _, ok := tc.Peek("head"); ok {
+		t.Fatalf("invalid value not removed")
+	}
+	if want := []interface{}{"head"}; !reflect.DeepEqual(*evicted, want) {
+		t.Fatalf("evicted keys mismatch: have %v, want %v", *evicted, want)
+	}
+	if have, want := tc.Stats(), (Stats{Hits: 1, Misses: 1}); have != want {
+		t.Fatalf("stats mismatch: have %+v, want %+v", have, want)
+	}
+	checkExpirySync(t, tc)
+
+	// Missing keys never reach the validator
+	if _, ok := tc.GetValid("missing", func(interface{}) bool {
+		t.Fatalf("validator called for a missing key")
+		return true
+	}); ok {
+		t.Fatalf("missing key hit")
+	}
+	// A value replaced while being validated survives the rejection
+	tc.Add("head", 100)
+	if _, ok := tc.GetValid("head", func(interface{}) bool {
+		tc.Add("head", 200)
+		return false
+	}); ok {
+		t.Fatalf("invalid value served")
+	}
+	if have, ok := tc.Peek("head"); !ok || have != 200 {
+		t.Fatalf("replacement mismatch: have %v (found %v), want %v", have, ok, 200)
+	}
+}
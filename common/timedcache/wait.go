@@ -0,0 +1,96 @@
+package timedcache
+
+import "context"
+
+// WaitFor returns the live value of a key, blocking until another goroutine
+// adds it if it is not present yet. It fails with the context error if ctx is
+// canceled first, and with ErrClosed if the cache is closed meanwhile. Should
+// the key expire before the waiter gets to read it, the wait goes on.
+func (tc *TimedCache) WaitFor(ctx context.Context, key interface{}) (value interface{}, err error) {
+	return tc.wait(ctx, key, false)
+}
+
+// WaitAndTake is like WaitFor, but atomically removes the key once it is
+// present, so that each value added is consumed by exactly one waiter, as for
+// a job handoff. The removal is reported to the eviction callbacks as a manual
+// one.
+func (tc *TimedCache) WaitAndTake(ctx context.Context, key interface{}) (value interface{}, err error) {
+	return tc.wait(ctx, key, true)
+}
+
+// wait blocks until a key holds a live entry, returning its value and
+// removing it if take is set.
+func (tc *timedCache) wait(ctx context.Context, key interface{}, take bool) (value interface{}, err error) {
+	for {
+		tc.lock.Lock()
+		if entry, ok := tc.peek(key); ok {
+			if !take {
+				found, ok := tc.getAndUnlock(key, "")
+				if !ok {
+					continue
+				}
+				return found.resolve()
+			}
+			found := lookupResult{value: entry.value, lazy: entry.lazy, codec: entry.codec, clone: tc.cloner}
+			tc.removeFor(key, EvictManual)
+
+			pending := tc.takeEvicted()
+			tc.lock.Unlock()
+			// invoke callback outside of critical section
+			tc.notifyEvicted(pending)
+			return found.resolve()
+		}
+		if tc.waiters == nil {
+			tc.waiters = make(map[interface{}]*waiter)
+		}
+		w, ok := tc.waiters[key]
+		if !ok {
+			w = &waiter{added: make(chan struct{})}
+			tc.waiters[key] = w
+		}
+		w.waiting++
+		pending := tc.takeEvicted()
+		tc.lock.Unlock()
+		// invoke callback outside of critical section
+		tc.notifyEvicted(pending)
+
+		select {
+		case <-w.added:
+		case <-ctx.Done():
+			tc.unwait(key, w)
+			return nil, ctx.Err()
+		case <-tc.quit:
+			tc.unwait(key, w)
+			return nil, ErrClosed
+		}
+	}
+}
+
+// waiter is released when the key awaited by a set of goroutines is added.
+type waiter struct {
+	added   chan struct{} // Channel closed on the insertion of the key
+	waiting int           // Number of goroutines waiting on the channel
+}
+
+// unwait deregisters a goroutine giving up on a waiter, dropping the waiter
+// once no goroutine waits on it anymore, lest abandoned waits leak.
+func (tc *timedCache) unwait(key interface{}, w *waiter) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	if tc.waiters[key] != w {
+		return // released meanwhile
+	}
+	if w.waiting--; w.waiting == 0 {
+		delete(tc.waiters, key)
+	}
+}
+
+// wake releases the goroutines waiting for a key to be added. It must be
+// called with the lock held.
+func (tc *timedCache) wake(key interface{}) {
+	if w, ok := tc.waiters[key]; ok {
+		close(w.added)
+		delete(tc.waiters, key)
+	}
+}
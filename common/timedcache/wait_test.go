@@ -0,0 +1,153 @@
+package timedcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	tc, clock, _ := newTestCache(t, 10, 10)
+
+	// Present keys are returned right away
+	tc.Add("present", 1)
+	if value, err := tc.WaitFor(context.Background(), "present"); err != nil || value != 1 {
+		t.Fatalf("present key mismatch: have %v, %v", value, err)
+	}
+	// Absent keys are returned once a producer adds them
+	result := make(chan interface{})
+	go func() {
+		value, err := tc.WaitFor(context.Background(), "later")
+		if err != nil {
+			t.Errorf("wait failed: %v", err)
+		}
+		result <- value
+	}()
+	time.Sleep(10 * time.Millisecond)
+	tc.Add("later", 2)
+	select {
+	case value := <-result:
+		if value != 2 {
+			t.Fatalf("waited value mismatch: have %v, want 2", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("waiter not released by the insertion")
+	}
+	// Expired keys are waited for anew
+	clock.time += 11
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if value, err := tc.WaitFor(ctx, "present"); err != context.DeadlineExceeded {
+		t.Fatalf("expired key mismatch: have %v, %v", value, err)
+	}
+	// Closing the cache releases the waiters
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tc.Close()
+	}()
+	if _, err := tc.WaitFor(context.Background(), "never"); err != ErrClosed {
+		t.Fatalf("close error mismatch: have %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestWaitAndTake(t *testing.T) {
+	tc, _, _ := newTestCache(t, 100, 100)
+
+	// Keys produced before any waiter arrives are taken right away
+	tc.Add("early", 1)
+	if value, err := tc.WaitAndTake(context.Background(), "early"); err != nil || value != 1 {
+		t.Fatalf("early key mismatch: have %v, %v", value, err)
+	}
+	if _, ok := tc.Peek("early"); ok {
+		t.Fatalf("taken key still present")
+	}
+	// Every produced item is consumed by exactly one of many waiters
+	const (
+		waiters = 8
+		items   = 50
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	consumed := make(chan interface{}, items)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			for {
+				value, err := tc.WaitAndTake(ctx, "job")
+				if err != nil {
+					return
+				}
+				consumed <- value
+			}
+		}()
+	}
+	seen := make(map[interface{}]int)
+	for i := 0; i < items; i++ {
+		tc.Add("job", i)
+		select {
+		case value := <-consumed:
+			seen[value]++
+		case <-time.After(time.Second):
+			t.Fatalf("item %d not consumed", i)
+		}
+	}
+	cancel()
+	for i := 0; i < items; i++ {
+		if seen[i] != 1 {
+			t.Errorf("item %d consumed %d times", i, seen[i])
+		}
+	}
+	select {
+	case value := <-consumed:
+		t.Fatalf("item %v consumed more than once", value)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestWaitCancelDeregisters(t *testing.T) {
+	tc, _, _ := newTestCache(t, 10, 10)
+
+	// Abandoned waits, taking or not, leave no waiter behind
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if _, err := tc.WaitFor(ctx, i); err != context.DeadlineExceeded {
+			t.Fatalf("wait %d error mismatch: have %v, want %v", i, err, context.DeadlineExceeded)
+		}
+		if _, err := tc.WaitAndTake(ctx, i); err != context.DeadlineExceeded {
+			t.Fatalf("take %d error mismatch: have %v, want %v", i, err, context.DeadlineExceeded)
+		}
+		cancel()
+	}
+	tc.lock.Lock()
+	leaked := len(tc.waiters)
+	tc.lock.Unlock()
+	if leaked != 0 {
+		t.Fatalf("waiters leaked: have %d, want 0", leaked)
+	}
+	// A canceled waiter does not strand the others waiting for the same key
+	canceled, cancel := context.WithCancel(context.Background())
+	result := make(chan interface{})
+	go func() {
+		value, _ := tc.WaitAndTake(context.Background(), "job")
+		result <- value
+	}()
+	go tc.WaitFor(canceled, "job")
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	tc.Add("job", 1)
+	select {
+	case value := <-result:
+		if value != 1 {
+			t.Fatalf("taken value mismatch: have %v, want 1", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("remaining waiter not released")
+	}
+	tc.lock.Lock()
+	leaked = len(tc.waiters)
+	tc.lock.Unlock()
+	if leaked != 0 {
+		t.Fatalf("waiters leaked: have %d, want 0", leaked)
+	}
+}
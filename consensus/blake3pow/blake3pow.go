@@ -9,6 +9,7 @@ import (
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/hexutil"
 	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/metrics"
 	"github.com/dominant-strategies/go-quai/rpc"
@@ -43,6 +44,12 @@ type Config struct {
 
 	DurationLimit *big.Int
 
+	// ContextTargetTimes optionally overrides the DurationLimit per chain
+	// context, in seconds. Zero entries keep the DurationLimit. Difficulty is
+	// only computed in zone context, so only the zone entry takes effect, the
+	// others merely bounding it.
+	ContextTargetTimes [common.HierarchyDepth]uint64
+
 	GasCeil uint64
 
 	MinDifficulty *big.Int
@@ -65,6 +72,8 @@ type Blake3pow struct {
 	hashrate metrics.Meter // Meter tracking the average hashrate
 	remote   *remoteSealer
 
+	difficultyCounters *misc.DifficultyCounters // Difficulty clamp counters, nil if metrics are disabled
+
 	// The fields below are hooks for testing
 	shared    *Blake3pow    // Shared PoW verifier to avoid cache regeneration
 	fakeFail  uint64        // Block number which fails PoW check even in fake mode
@@ -81,11 +90,18 @@ func New(config Config, notify []string, noverify bool) *Blake3pow {
 	if config.Log == nil {
 		config.Log = &log.Log
 	}
+	if err := misc.ValidateContextTargetTimes(config.ContextTargetTimes, config.DurationLimit); err != nil {
+		config.Log.Error("Ignoring invalid context target times", "times", config.ContextTargetTimes, "err", err)
+		config.ContextTargetTimes = [common.HierarchyDepth]uint64{}
+	}
 	blake3pow := &Blake3pow{
 		config:   config,
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeterForced(),
 	}
+	if metrics.Enabled {
+		blake3pow.difficultyCounters = new(misc.DifficultyCounters)
+	}
 	if config.PowMode == ModeShared {
 		blake3pow.shared = sharedBlake3pow
 	}
@@ -1,6 +1,7 @@
 package blake3pow
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -17,7 +18,6 @@ import (
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/trie"
-	"modernc.org/mathutil"
 )
 
 // Blake3pow proof-of-work protocol constants.
@@ -340,41 +340,37 @@ func (blake3pow *Blake3pow) CalcDifficulty(chain consensus.ChainHeaderReader, pa
 		return nil
 	}
 
-	///// Algorithm:
-	///// e = (DurationLimit - (parent.Time() - parentOfParent.Time())) * parent.Difficulty()
-	///// k = Floor(BinaryLog(parent.Difficulty()))/(DurationLimit*DifficultyAdjustmentFactor*AdjustmentPeriod)
-	///// Difficulty = Max(parent.Difficulty() + e * k, MinimumDifficulty)
-
-	if parent.Hash() == chain.Config().GenesisHash {
-		return parent.Difficulty()
+	if parent == nil {
+		log.Error("Cannot CalcDifficulty without a parent header")
+		return new(big.Int).Set(blake3pow.config.MinDifficulty)
 	}
-
-	parentOfParent := chain.GetHeaderByHash(parent.ParentHash())
-	if parentOfParent == nil || parentOfParent.Hash() == chain.Config().GenesisHash {
-		return parent.Difficulty()
+	var parentOfParent *types.Header
+	if parent.Hash() != chain.Config().GenesisHash {
+		parentOfParent = chain.GetHeaderByHash(parent.ParentHash())
 	}
+	return misc.PrepareDifficulty(blake3pow.difficultyConfig().ForContext(nodeCtx), parent, parentOfParent, chain.Config().GenesisHash)
+}
 
-	time := parent.Time()
-	bigTime := new(big.Int).SetUint64(time)
-	bigParentTime := new(big.Int).SetUint64(parentOfParent.Time())
+// difficultyConfig returns the difficulty adjustment parameters of the engine.
+func (blake3pow *Blake3pow) difficultyConfig() *misc.DifficultyConfig {
+	return &misc.DifficultyConfig{
+		DurationLimit:      blake3pow.config.DurationLimit,
+		MinDifficulty:      blake3pow.config.MinDifficulty,
+		ContextTargetTimes: blake3pow.config.ContextTargetTimes,
+		Counters:           blake3pow.difficultyCounters,
+	}
+}
 
-	// holds intermediate values to make the algo easier to read & audit
-	x := new(big.Int)
-	x.Sub(bigTime, bigParentTime)
-	x.Sub(blake3pow.config.DurationLimit, x)
-	x.Mul(x, parent.Difficulty())
-	k, _ := mathutil.BinaryLog(new(big.Int).Set(parent.Difficulty()), 64)
-	x.Mul(x, big.NewInt(int64(k)))
-	x.Div(x, blake3pow.config.DurationLimit)
-	x.Div(x, big.NewInt(params.DifficultyAdjustmentFactor))
-	x.Div(x, params.DifficultyAdjustmentPeriod)
-	x.Add(x, parent.Difficulty())
+// DifficultyParams returns the JSON encoding of the difficulty adjustment
+// parameters in effect, allowing operators to detect config drift across nodes.
+func (blake3pow *Blake3pow) DifficultyParams() ([]byte, error) {
+	return json.Marshal(blake3pow.difficultyConfig())
+}
 
-	// minimum difficulty can ever be (before exponential factor)
-	if x.Cmp(blake3pow.config.MinDifficulty) < 0 {
-		x.Set(blake3pow.config.MinDifficulty)
-	}
-	return x
+// DifficultyMetrics returns a snapshot of how often the difficulty adjustment
+// was clamped. The counters are only tracked when metrics are enabled.
+func (blake3pow *Blake3pow) DifficultyMetrics() misc.DifficultyMetrics {
+	return blake3pow.difficultyConfig().Metrics()
 }
 
 func (blake3pow *Blake3pow) IsDomCoincident(chain consensus.ChainHeaderReader, header *types.Header) bool {
@@ -417,7 +413,9 @@ func (blake3pow *Blake3pow) verifySeal(header *types.Header) error {
 // Prepare implements consensus.Engine, initializing the difficulty field of a
 // header to conform to the blake3pow protocol. The changes are done inline.
 func (blake3pow *Blake3pow) Prepare(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header) error {
-	header.SetDifficulty(blake3pow.CalcDifficulty(chain, parent))
+	difficulty := blake3pow.CalcDifficulty(chain, parent)
+	header.SetDifficulty(difficulty)
+	misc.LogDifficultyContext(blake3pow.config.Log, blake3pow.difficultyConfig().ForContext(common.NodeLocation.Context()), header.NumberU64(), difficulty)
 	return nil
 }
 
@@ -0,0 +1,129 @@
+package blake3pow
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/params"
+	"github.com/sirupsen/logrus"
+)
+
+// testChainReader serves the headers of a test chain by hash.
+type testChainReader struct {
+	config  *params.ChainConfig
+	headers map[common.Hash]*types.Header
+}
+
+func (r *testChainReader) Config() *params.ChainConfig            { return r.config }
+func (r *testChainReader) CurrentHeader() *types.Header           { return nil }
+func (r *testChainReader) GetHeaderByNumber(uint64) *types.Header { return nil }
+func (r *testChainReader) GetTerminiByHash(common.Hash) *types.Termini {
+	return nil
+}
+func (r *testChainReader) ProcessingState() bool { return false }
+func (r *testChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return r.headers[hash]
+}
+func (r *testChainReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	return r.headers[hash]
+}
+
+// Tests that the difficulty predicted by misc.PrepareDifficulty is the one the
+// engine prepares headers with, across the genesis boundary.
+func TestPrepareDifficulty(t *testing.T) {
+	defer func(location common.Location) { common.NodeLocation = location }(common.NodeLocation)
+	common.NodeLocation = common.Location{0, 0}
+
+	engine := &Blake3pow{config: Config{
+		PowMode:       ModeFake,
+		DurationLimit: big.NewInt(12),
+		MinDifficulty: big.NewInt(1000),
+		Log:           &log.Log,
+	}}
+	config := &misc.DifficultyConfig{DurationLimit: big.NewInt(12), MinDifficulty: big.NewInt(1000)}
+
+	genesis := types.EmptyHeader()
+	genesis.SetDifficulty(big.NewInt(1000000))
+	genesis.SetTime(1000)
+	chain := &testChainReader{
+		config:  &params.ChainConfig{GenesisHash: genesis.Hash()},
+		headers: map[common.Hash]*types.Header{genesis.Hash(): genesis},
+	}
+	var grandparent *types.Header
+	parent := genesis
+	for i, solvetime := range []uint64{10, 3, 25, 12, 1} {
+		header := types.EmptyHeader()
+		header.SetParentHash(parent.Hash())
+		header.SetNumber(big.NewInt(int64(i + 1)))
+		header.SetTime(parent.Time() + solvetime)
+		if err := engine.Prepare(chain, header, parent); err != nil {
+			t.Fatalf("block %d: failed to prepare header: %v", i+1, err)
+		}
+		want := misc.PrepareDifficulty(config, parent, grandparent, genesis.Hash())
+		if header.Difficulty().Cmp(want) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %v", i+1, header.Difficulty(), want)
+		}
+		// The first two blocks inherit the difficulty of the genesis
+		if i < 2 && header.Difficulty().Cmp(genesis.Difficulty()) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want genesis %v", i+1, header.Difficulty(), genesis.Difficulty())
+		}
+		chain.headers[header.Hash()] = header
+		grandparent, parent = parent, header
+	}
+}
+
+// Tests that the engine drops context target times which are out of order,
+// while accepting a zone only override.
+func TestNewContextTargetTimes(t *testing.T) {
+	engine := New(Config{PowMode: ModeFake, DurationLimit: big.NewInt(12), ContextTargetTimes: [common.HierarchyDepth]uint64{0, 0, 5}}, nil, false)
+	defer engine.Close()
+	if have, want := engine.config.ContextTargetTimes, [common.HierarchyDepth]uint64{0, 0, 5}; have != want {
+		t.Errorf("zone only override mismatch: have %v, want %v", have, want)
+	}
+	engine = New(Config{PowMode: ModeFake, DurationLimit: big.NewInt(12), ContextTargetTimes: [common.HierarchyDepth]uint64{0, 0, 20}}, nil, false)
+	defer engine.Close()
+	if have := engine.config.ContextTargetTimes; have != [common.HierarchyDepth]uint64{} {
+		t.Errorf("invalid override kept: %v", have)
+	}
+}
+
+// Tests that preparing a header logs the difficulty it was assigned at debug
+// level.
+func TestPrepareLogsDifficulty(t *testing.T) {
+	defer func(location common.Location) { common.NodeLocation = location }(common.NodeLocation)
+	common.NodeLocation = common.Location{0, 0}
+
+	var out bytes.Buffer
+	logger := log.Logger{Logger: logrus.New()}
+	logger.SetOutput(&out)
+	logger.SetLevel(logrus.DebugLevel)
+
+	engine := &Blake3pow{config: Config{
+		PowMode:       ModeFake,
+		DurationLimit: big.NewInt(12),
+		MinDifficulty: big.NewInt(1000),
+		Log:           &logger,
+	}}
+	genesis := types.EmptyHeader()
+	genesis.SetDifficulty(big.NewInt(1000000))
+	chain := &testChainReader{config: &params.ChainConfig{GenesisHash: genesis.Hash()}}
+
+	header := types.EmptyHeader()
+	header.SetParentHash(genesis.Hash())
+	header.SetNumber(big.NewInt(1))
+	if err := engine.Prepare(chain, header, genesis); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	line := out.String()
+	for _, field := range []string{"number=1", "difficulty=1000000", "hashrate=83333"} {
+		if !strings.Contains(line, field) {
+			t.Errorf("field %q missing from log line %q", field, line)
+		}
+	}
+}
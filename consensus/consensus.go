@@ -153,15 +153,110 @@ type Engine interface {
 	Close() error
 }
 
+// TargetSpaceBits is the bit length of the proof-of-work digests which the
+// difficulty targets are expressed against.
+const TargetSpaceBits = 256
+
+// targetSpace returns the size of a target space of the given bit length.
+func targetSpace(bits uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), bits)
+}
+
 func TargetToDifficulty(target *big.Int) *big.Int {
-	big2e256 := new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0)) // 2^256
-	return new(big.Int).Div(big2e256, target)
+	return TargetToDifficultyInSpace(target, TargetSpaceBits)
 }
 
 func DifficultyToTarget(difficulty *big.Int) *big.Int {
 	return TargetToDifficulty(difficulty)
 }
 
+// TargetToDifficultyInSpace converts a target into a difficulty within a
+// target space of the given bit length.
+func TargetToDifficultyInSpace(target *big.Int, bits uint) *big.Int {
+	return new(big.Int).Div(targetSpace(bits), target)
+}
+
+// DifficultyToTargetInSpace converts a difficulty into a target within a target
+// space of the given bit length, rejecting difficulties which are not positive
+// or exceed the size of the space, as no digest could ever meet them.
+func DifficultyToTargetInSpace(difficulty *big.Int, bits uint) (*big.Int, error) {
+	space := targetSpace(bits)
+	if difficulty.Sign() <= 0 || difficulty.Cmp(space) > 0 {
+		return nil, ErrDifficultyOutOfRange
+	}
+	return new(big.Int).Div(space, difficulty), nil
+}
+
+// DifficultyToBits encodes the target of a difficulty in the compact 4 byte
+// "bits" representation used by Bitcoin style tooling. The encoding keeps 23
+// bits of mantissa, so the round trip through BitsToDifficulty is lossy.
+// Non-positive difficulties encode to zero.
+func DifficultyToBits(difficulty *big.Int) uint32 {
+	if difficulty.Sign() <= 0 {
+		return 0
+	}
+	return bigToCompact(DifficultyToTarget(difficulty))
+}
+
+// BitsToDifficulty decodes a compact "bits" target into its difficulty. Bits
+// encoding a zero, negative or overflowing (above the target space) target are invalid and
+// decode to a zero difficulty.
+func BitsToDifficulty(bits uint32) *big.Int {
+	target := compactToBig(bits)
+	if target.Sign() <= 0 || target.Cmp(targetSpace(TargetSpaceBits)) > 0 {
+		return new(big.Int)
+	}
+	return TargetToDifficulty(target)
+}
+
+// bigToCompact converts a number into its compact representation: the most
+// significant byte holds the length of the number in bytes, followed by a sign
+// bit and 23 bits of mantissa.
+func bigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(new(big.Int).Abs(n).Uint64())
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		tn := new(big.Int).Abs(n)
+		mantissa = uint32(tn.Rsh(tn, 8*(exponent-3)).Uint64())
+	}
+	// The sign bit is part of the mantissa field, shift it out of the way
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// compactToBig converts a compact representation back into the number.
+func compactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	negative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var n *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		n = new(big.Int).SetUint64(uint64(mantissa))
+	} else {
+		n = new(big.Int).SetUint64(uint64(mantissa))
+		n.Lsh(n, 8*(exponent-3))
+	}
+	if negative {
+		n.Neg(n)
+	}
+	return n
+}
+
 // PoW is a consensus engine based on proof-of-work.
 type PoW interface {
 	Engine
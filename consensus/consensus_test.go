@@ -0,0 +1,105 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDifficultyBits(t *testing.T) {
+	tests := []struct {
+		difficulty *big.Int
+		bits       uint32
+	}{
+		{difficulty: big.NewInt(1), bits: 0x21010000},          // target of 2^256
+		{difficulty: big.NewInt(4295032833), bits: 0x1d00ffff}, // Bitcoin genesis target
+		{difficulty: big.NewInt(1 << 32), bits: 0x1d010000},
+		{difficulty: big.NewInt(0), bits: 0},
+		{difficulty: big.NewInt(-5), bits: 0},
+	}
+	for i, tt := range tests {
+		if have := DifficultyToBits(tt.difficulty); have != tt.bits {
+			t.Errorf("test %d: bits mismatch: have %#x, want %#x", i, have, tt.bits)
+		}
+	}
+	// Decoding known bits yields the exact difficulty of the encoded target
+	if have, want := BitsToDifficulty(0x1d00ffff), big.NewInt(4295032833); have.Cmp(want) != 0 {
+		t.Errorf("difficulty mismatch: have %v, want %v", have, want)
+	}
+	if have, want := BitsToDifficulty(0x21010000), big.NewInt(1); have.Cmp(want) != 0 {
+		t.Errorf("difficulty mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestBitsToDifficultyInvalid(t *testing.T) {
+	for _, bits := range []uint32{
+		0x00000000, // zero target
+		0x1d800000, // negative zero
+		0x1d80ffff, // negative target
+		0x21020000, // target above 2^256
+		0xff7fffff, // exponent overflow
+	} {
+		if have := BitsToDifficulty(bits); have.Sign() != 0 {
+			t.Errorf("bits %#x: expected zero difficulty, have %v", bits, have)
+		}
+	}
+}
+
+func TestDifficultyBitsRoundTrip(t *testing.T) {
+	// Normalized bits survive the round trip through the difficulty exactly
+	// when the target is a power of two
+	for shift := uint32(0); shift < 232; shift += 8 {
+		target := new(big.Int).Lsh(big.NewInt(0x8000), uint(shift))
+		bits := bigToCompact(target)
+		if have := compactToBig(bits); have.Cmp(target) != 0 {
+			t.Fatalf("target %v: compact round trip mismatch: have %v", target, have)
+		}
+		if have := DifficultyToBits(BitsToDifficulty(bits)); have != bits {
+			t.Errorf("bits %#x: round trip mismatch: have %#x", bits, have)
+		}
+	}
+	// Arbitrary difficulties are preserved to the precision of the mantissa
+	for _, d := range []int64{1000, 123456789, 987654321987, 1 << 62} {
+		difficulty := big.NewInt(d)
+		have := BitsToDifficulty(DifficultyToBits(difficulty))
+		diff := new(big.Int).Sub(have, difficulty)
+		diff.Abs(diff).Lsh(diff, 15)
+		if diff.Cmp(difficulty) > 0 {
+			t.Errorf("difficulty %d: round trip too lossy: have %v", d, have)
+		}
+	}
+}
+
+func TestTargetSpace(t *testing.T) {
+	difficulty := big.NewInt(1 << 20)
+
+	// The default space matches the 256 bit conversion helpers
+	target, err := DifficultyToTargetInSpace(difficulty, TargetSpaceBits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := new(big.Int).Lsh(big.NewInt(1), 236); target.Cmp(want) != 0 || DifficultyToTarget(difficulty).Cmp(want) != 0 {
+		t.Fatalf("256 bit target mismatch: have %v, want %v", target, want)
+	}
+	// A 512 bit space scales the target accordingly
+	target, err = DifficultyToTargetInSpace(difficulty, 512)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := new(big.Int).Lsh(big.NewInt(1), 492); target.Cmp(want) != 0 {
+		t.Fatalf("512 bit target mismatch: have %v, want %v", target, want)
+	}
+	if have := TargetToDifficultyInSpace(target, 512); have.Cmp(difficulty) != 0 {
+		t.Fatalf("512 bit difficulty mismatch: have %v, want %v", have, difficulty)
+	}
+	// Difficulties beyond the space are rejected
+	huge := new(big.Int).Lsh(big.NewInt(1), 300)
+	if _, err := DifficultyToTargetInSpace(huge, TargetSpaceBits); err != ErrDifficultyOutOfRange {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrDifficultyOutOfRange)
+	}
+	if _, err := DifficultyToTargetInSpace(huge, 512); err != nil {
+		t.Fatalf("unexpected error in 512 bit space: %v", err)
+	}
+	if _, err := DifficultyToTargetInSpace(big.NewInt(0), TargetSpaceBits); err != ErrDifficultyOutOfRange {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrDifficultyOutOfRange)
+	}
+}
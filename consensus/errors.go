@@ -34,4 +34,8 @@ var (
 	// ErrInvalidNumber is returned if a block's number doesn't equal its parent's
 	// plus one.
 	ErrInvalidNumber = errors.New("invalid block number")
+
+	// ErrDifficultyOutOfRange is returned if a difficulty is not positive or
+	// does not fit the target space of the proof-of-work digest.
+	ErrDifficultyOutOfRange = errors.New("difficulty out of target space range")
 )
@@ -0,0 +1,597 @@
+package misc
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+	"modernc.org/mathutil"
+)
+
+var (
+	// ErrNilParent is returned when a difficulty is requested without a parent
+	// header to derive it from.
+	ErrNilParent = errors.New("difficulty calculation requires a parent header")
+
+	// ErrContextTargetTimes is returned when the per-context target block times
+	// do not slow down from zone to region to prime.
+	ErrContextTargetTimes = errors.New("context target times must satisfy prime >= region >= zone")
+)
+
+// DifficultyConfig holds the engine parameters of the difficulty adjustment
+// algorithm shared by the proof-of-work engines.
+type DifficultyConfig struct {
+	DurationLimit *big.Int `json:"durationLimit"` // Target block time, in seconds
+	MinDifficulty *big.Int `json:"minDifficulty"` // Minimum difficulty the adjustment may ever yield
+
+	// MaxDifficulty optionally caps the difficulty, bomb term included, guarding
+	// against runaway difficulties. A nil maximum leaves it uncapped.
+	MaxDifficulty *big.Int `json:"maxDifficulty,omitempty"`
+
+	// BoundDivisor optionally caps the per-block adjustment to
+	// parent.Difficulty()/BoundDivisor. A nil divisor leaves it uncapped.
+	BoundDivisor *big.Int `json:"boundDivisor,omitempty"`
+
+	// Rounding selects how the adjustment step is rounded to an integer.
+	Rounding RoundingMode `json:"rounding,omitempty"`
+
+	// MinSolvetime floors solvetimes before adjusting, damping the spikes of
+	// lucky fast blocks. Zero disables the floor.
+	MinSolvetime uint64 `json:"minSolvetime,omitempty"`
+
+	// Bomb optionally adds an exponentially growing term to the difficulty. A
+	// nil bomb disables it.
+	Bomb *DifficultyBomb `json:"bomb,omitempty"`
+
+	// EmergencyMultiplier and EmergencyTrigger configure the emergency
+	// adjustment: once a solvetime exceeds EmergencyTrigger times the
+	// DurationLimit, the parent difficulty is divided by EmergencyMultiplier
+	// instead of being adjusted normally. Zero values disable it.
+	EmergencyMultiplier float64 `json:"emergencyMultiplier,omitempty"`
+	EmergencyTrigger    float64 `json:"emergencyTrigger,omitempty"`
+
+	// ContextTargetTimes optionally overrides the DurationLimit of each chain
+	// context, indexed by PRIME_CTX, REGION_CTX and ZONE_CTX. Zero entries keep
+	// the DurationLimit.
+	ContextTargetTimes [common.HierarchyDepth]uint64 `json:"contextTargetTimes"`
+
+	// Freeze optionally holds the difficulty at its parent's value for a range
+	// of blocks. A nil freeze disables it.
+	Freeze *DifficultyFreeze `json:"freeze,omitempty"`
+
+	// LaunchDecay optionally raises the minimum difficulty of the first blocks
+	// of the chain, for a fair launch. A nil decay disables it.
+	LaunchDecay *LaunchDecay `json:"launchDecay,omitempty"`
+
+	// Oracle optionally overrides the difficulty of individual blocks by their
+	// number. Blocks it declines fall back to the adjustment algorithm.
+	Oracle func(number uint64) (*big.Int, bool) `json:"-"`
+
+	// Counters optionally tracks how often the adjustment hits its bounds. A
+	// nil value disables tracking altogether.
+	Counters *DifficultyCounters `json:"-"`
+
+	// ClampSink optionally receives every individual clamp of the difficulty,
+	// for real time alerting. A nil sink disables reporting altogether.
+	ClampSink func(event ClampEvent) `json:"-"`
+
+	// SeriesSink optionally receives every difficulty computed from a parent
+	// header, for long term analytics. A nil sink disables it.
+	SeriesSink func(number, time uint64, diff *big.Int) `json:"-"`
+}
+
+// NewDevnetDifficultyConfig returns the difficulty config of local development
+// networks: the minimum difficulty of 1, the short local target block time and
+// no difficulty bomb, so that blocks seal instantly on commodity hardware.
+func NewDevnetDifficultyConfig() *DifficultyConfig {
+	return &DifficultyConfig{
+		DurationLimit: new(big.Int).Set(params.LocalDurationLimit),
+		MinDifficulty: big.NewInt(1),
+	}
+}
+
+// WithMinSolvetime returns a copy of the config flooring solvetimes to the given
+// number of seconds. The floor bounds the largest upward adjustment a single
+// block can cause; a floor at or above the DurationLimit prevents difficulty
+// from ever increasing.
+func (c *DifficultyConfig) WithMinSolvetime(seconds uint64) *DifficultyConfig {
+	cpy := *c
+	cpy.MinSolvetime = seconds
+	return &cpy
+}
+
+// WithMaxDifficulty returns a copy of the config capping the difficulty to max,
+// after the bomb term was added. The cap should not be below MinDifficulty, as
+// the minimum takes precedence. A nil max removes the cap.
+func (c *DifficultyConfig) WithMaxDifficulty(max *big.Int) *DifficultyConfig {
+	cpy := *c
+	cpy.MaxDifficulty = max
+	return &cpy
+}
+
+// WithContextTargetTimes returns a copy of the config retargeting each chain
+// context to its own block time, in seconds, zero keeping the DurationLimit.
+// Prime blocks must not come faster than region blocks, nor region blocks
+// faster than zone blocks.
+func (c *DifficultyConfig) WithContextTargetTimes(times [common.HierarchyDepth]uint64) (*DifficultyConfig, error) {
+	if err := ValidateContextTargetTimes(times, c.DurationLimit); err != nil {
+		return nil, err
+	}
+	cpy := *c
+	cpy.ContextTargetTimes = times
+	return &cpy, nil
+}
+
+// ValidateContextTargetTimes checks that the target block times in effect in
+// each chain context do not decrease from zone to prime, zero entries standing
+// for the durationLimit.
+func ValidateContextTargetTimes(times [common.HierarchyDepth]uint64, durationLimit *big.Int) error {
+	var effective [common.HierarchyDepth]uint64
+	for ctx, target := range times {
+		if effective[ctx] = target; target == 0 && durationLimit != nil {
+			effective[ctx] = durationLimit.Uint64()
+		}
+	}
+	if effective[common.PRIME_CTX] < effective[common.REGION_CTX] || effective[common.REGION_CTX] < effective[common.ZONE_CTX] {
+		return ErrContextTargetTimes
+	}
+	return nil
+}
+
+// ForContext returns the config in effect for the given chain context, with
+// the DurationLimit replaced by the context's target block time if set.
+func (c *DifficultyConfig) ForContext(ctx int) *DifficultyConfig {
+	if ctx < 0 || ctx >= len(c.ContextTargetTimes) || c.ContextTargetTimes[ctx] == 0 {
+		return c
+	}
+	cpy := *c
+	cpy.DurationLimit = new(big.Int).SetUint64(c.ContextTargetTimes[ctx])
+	return &cpy
+}
+
+// RoundingMode selects how the difficulty adjustment step is rounded.
+type RoundingMode uint8
+
+const (
+	// RoundingTruncate divides the adjustment step by each of its divisors in
+	// turn, discarding the remainders. It is the historic behavior, and biases
+	// the difficulty slightly downwards over time.
+	RoundingTruncate RoundingMode = iota
+
+	// RoundingNearest divides the adjustment step once by the product of its
+	// divisors, rounding to the nearest integer, halves away from zero.
+	RoundingNearest
+)
+
+// WithRoundingMode returns a copy of the config rounding the adjustment step
+// with the given mode.
+func (c *DifficultyConfig) WithRoundingMode(mode RoundingMode) *DifficultyConfig {
+	cpy := *c
+	cpy.Rounding = mode
+	return &cpy
+}
+
+// WithEmergencyAdjust returns a copy of the config which divides the parent
+// difficulty by multiplier, clamped to the minimum, whenever a block took more
+// than triggerFactor times the DurationLimit to be mined, letting a chain
+// deserted by its hashrate recover.
+func (c *DifficultyConfig) WithEmergencyAdjust(multiplier float64, triggerFactor float64) *DifficultyConfig {
+	cpy := *c
+	cpy.EmergencyMultiplier = multiplier
+	cpy.EmergencyTrigger = triggerFactor
+	return &cpy
+}
+
+// emergencyTriggered returns whether a solvetime calls for the emergency
+// adjustment.
+func (c *DifficultyConfig) emergencyTriggered(solvetime uint64) bool {
+	if c.EmergencyMultiplier <= 1 || c.EmergencyTrigger <= 0 {
+		return false
+	}
+	trigger := new(big.Float).Mul(big.NewFloat(c.EmergencyTrigger), new(big.Float).SetInt(c.DurationLimit))
+	return new(big.Float).SetUint64(solvetime).Cmp(trigger) > 0
+}
+
+// emergencyDifficulty returns the parent difficulty divided by the emergency
+// multiplier.
+func (c *DifficultyConfig) emergencyDifficulty(parentDiff *big.Int) *big.Int {
+	x, _ := new(big.Float).Quo(new(big.Float).SetInt(parentDiff), big.NewFloat(c.EmergencyMultiplier)).Int(nil)
+	return x
+}
+
+// DifficultyFreeze is an inclusive range of block numbers whose difficulty is
+// frozen.
+type DifficultyFreeze struct {
+	From uint64 `json:"from"` // First frozen block
+	To   uint64 `json:"to"`   // Last frozen block
+}
+
+// WithDifficultyFreeze returns a copy of the config freezing the difficulty of
+// the blocks numbered fromBlock through toBlock at their parent's difficulty,
+// skipping both the adjustment and the bomb, to smooth coordinated upgrades.
+func (c *DifficultyConfig) WithDifficultyFreeze(fromBlock, toBlock uint64) *DifficultyConfig {
+	cpy := *c
+	cpy.Freeze = &DifficultyFreeze{From: fromBlock, To: toBlock}
+	return &cpy
+}
+
+// frozen returns whether the difficulty of a block is frozen.
+func (c *DifficultyConfig) frozen(number uint64) bool {
+	return c.Freeze != nil && number >= c.Freeze.From && number <= c.Freeze.To
+}
+
+// LaunchDecay is a minimum difficulty decaying linearly from Start at genesis
+// down to the configured minimum at block Blocks.
+type LaunchDecay struct {
+	Start  *big.Int `json:"start"`  // Minimum difficulty at genesis
+	Blocks uint64   `json:"blocks"` // Number of blocks the decay lasts
+}
+
+// WithLaunchDecay returns a copy of the config whose minimum difficulty starts
+// out at startDiff and decays linearly to MinDifficulty over the first
+// decayBlocks blocks, after which the normal minimum applies. Starting the
+// chain at a high difficulty keeps the first miners from claiming the early
+// blocks cheaply while the network hashrate is still unknown.
+func (c *DifficultyConfig) WithLaunchDecay(startDiff *big.Int, decayBlocks uint64) *DifficultyConfig {
+	cpy := *c
+	cpy.LaunchDecay = &LaunchDecay{Start: new(big.Int).Set(startDiff), Blocks: decayBlocks}
+	return &cpy
+}
+
+// minDifficulty returns the minimum difficulty in effect for a block.
+func (c *DifficultyConfig) minDifficulty(number uint64) *big.Int {
+	decay := c.LaunchDecay
+	if decay == nil || number >= decay.Blocks || decay.Start.Cmp(c.MinDifficulty) <= 0 {
+		return c.MinDifficulty
+	}
+	// start - (start - min) * number / blocks
+	x := new(big.Int).Sub(decay.Start, c.MinDifficulty)
+	x.Mul(x, new(big.Int).SetUint64(number))
+	x.Div(x, new(big.Int).SetUint64(decay.Blocks))
+	return x.Sub(decay.Start, x)
+}
+
+// WithDifficultyOracle returns a copy of the config whose difficulty is taken
+// from the oracle for every block it returns a value for, raised to the minimum
+// difficulty if need be. Scripted difficulty scenarios are meant for testnets
+// and integration tests only.
+func (c *DifficultyConfig) WithDifficultyOracle(oracle func(number uint64) (*big.Int, bool)) *DifficultyConfig {
+	cpy := *c
+	cpy.Oracle = oracle
+	return &cpy
+}
+
+// DifficultyCounters accumulates the number of times the difficulty adjustment
+// was clamped. It is safe for concurrent use.
+type DifficultyCounters struct {
+	minClampHits  uint64 // Number of times the minimum difficulty was enforced
+	adjustCapHits uint64 // Number of times the adjustment cap was enforced
+}
+
+// DifficultyMetrics is a point in time snapshot of the difficulty counters.
+type DifficultyMetrics struct {
+	MinClampHits  uint64
+	AdjustCapHits uint64
+}
+
+// Metrics returns a snapshot of the difficulty counters, or zero values if
+// tracking is disabled.
+func (c *DifficultyConfig) Metrics() DifficultyMetrics {
+	if c.Counters == nil {
+		return DifficultyMetrics{}
+	}
+	return DifficultyMetrics{
+		MinClampHits:  atomic.LoadUint64(&c.Counters.minClampHits),
+		AdjustCapHits: atomic.LoadUint64(&c.Counters.adjustCapHits),
+	}
+}
+
+// WithDifficultySeriesSink returns a copy of the config pushing every difficulty
+// computed by CalcDifficulty to sink, along with the number of the block it is
+// for and the timestamp of its parent, from which the difficulty is in effect.
+// The sink is called synchronously and is handed a copy of the difficulty.
+func (c *DifficultyConfig) WithDifficultySeriesSink(sink func(number, time uint64, diff *big.Int)) *DifficultyConfig {
+	cpy := *c
+	cpy.SeriesSink = sink
+	return &cpy
+}
+
+// ClampKind identifies the bound a difficulty calculation was clamped to.
+type ClampKind uint8
+
+const (
+	// ClampMinimum is the raise of the difficulty to the minimum difficulty.
+	ClampMinimum ClampKind = iota
+
+	// ClampAdjustment is the cap of the adjustment to the bound divisor.
+	ClampAdjustment
+
+	// ClampMaximum is the cap of the difficulty to the maximum difficulty.
+	ClampMaximum
+)
+
+// String implements the fmt.Stringer interface.
+func (k ClampKind) String() string {
+	switch k {
+	case ClampMinimum:
+		return "minimum"
+	case ClampAdjustment:
+		return "adjustment"
+	case ClampMaximum:
+		return "maximum"
+	default:
+		return "unknown"
+	}
+}
+
+// ClampEvent describes a single clamp of the difficulty calculation. For the
+// minimum and maximum clamps Before and After are difficulties, for the
+// adjustment clamp they are adjustments.
+type ClampEvent struct {
+	Number uint64    // Number of the block whose difficulty was clamped
+	Kind   ClampKind // Bound the calculation was clamped to
+	Before *big.Int  // Value before the clamp
+	After  *big.Int  // Value after the clamp
+}
+
+// WithClampSink returns a copy of the config reporting every clamp of the
+// difficulty to sink. The sink is called synchronously from the calculation,
+// so it must be fast and must not retain the event values beyond its return.
+func (c *DifficultyConfig) WithClampSink(sink func(event ClampEvent)) *DifficultyConfig {
+	cpy := *c
+	cpy.ClampSink = sink
+	return &cpy
+}
+
+// clamped accounts for a clamp of the calculation of block number, reporting
+// it to the clamp sink if one is set. before is only read when a sink is set.
+func (c *DifficultyConfig) clamped(number uint64, kind ClampKind, before, after *big.Int) {
+	if c.Counters != nil {
+		switch kind {
+		case ClampMinimum:
+			atomic.AddUint64(&c.Counters.minClampHits, 1)
+		case ClampAdjustment:
+			atomic.AddUint64(&c.Counters.adjustCapHits, 1)
+		}
+	}
+	if c.ClampSink != nil {
+		c.ClampSink(ClampEvent{
+			Number: number,
+			Kind:   kind,
+			Before: new(big.Int).Set(before),
+			After:  new(big.Int).Set(after),
+		})
+	}
+}
+
+const (
+	// DifficultyAlgoLog2 names the logarithmic difficulty adjustment algorithm
+	// implemented by CalcDifficulty.
+	DifficultyAlgoLog2 = "log2"
+
+	// DifficultyAlgoOracle names difficulties injected by a difficulty oracle.
+	DifficultyAlgoOracle = "oracle"
+
+	// DifficultyAlgoFreeze names difficulties held by a difficulty freeze.
+	DifficultyAlgoFreeze = "freeze"
+)
+
+// DifficultyResult bundles a computed difficulty with the metadata of how it
+// was derived.
+type DifficultyResult struct {
+	Difficulty   *big.Int // Final difficulty, after all clamps were applied
+	Algorithm    string   // Name of the adjustment algorithm which ran
+	Adjustment   *big.Int // Raw adjustment to the parent difficulty, before clamping
+	AdjustCapped bool     // Whether the adjustment was capped by the bound divisor
+	MinClamped   bool     // Whether the difficulty was raised to the minimum
+	MaxClamped   bool     // Whether the difficulty was capped to the maximum
+	BombTerm     *big.Int // Exponential difficulty bomb term, nil if not active
+	Emergency    bool     // Whether the emergency adjustment replaced the normal step
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the
+// difficulty a block built on top of parent should have. The time argument is
+// the timestamp of the parent's own parent, so that parent.Time()-time is the
+// solve time of the parent block.
+func CalcDifficulty(config *DifficultyConfig, time uint64, parent *types.Header) *big.Int {
+	return CalcDifficultyResult(config, time, parent).Difficulty
+}
+
+// CalcDifficultyResult is like CalcDifficulty, but additionally reports the
+// algorithm, the raw adjustment and which clamps fired.
+func CalcDifficultyResult(config *DifficultyConfig, time uint64, parent *types.Header) DifficultyResult {
+	// Verified headers are never older than their parent, saturate otherwise
+	var solvetime uint64
+	if parent.Time() > time {
+		solvetime = parent.Time() - time
+	}
+	result := calcDifficultyFromSolvetime(config, parent.Difficulty(), parent.NumberU64(), solvetime)
+	if config.SeriesSink != nil {
+		config.SeriesSink(parent.NumberU64()+1, parent.Time(), new(big.Int).Set(result.Difficulty))
+	}
+	return result
+}
+
+// CalcDifficultyFromSolvetime computes the difficulty of the block following a
+// parent of the given difficulty and number, which took solvetime seconds to be
+// mined. It is the header independent core of CalcDifficulty. The adjustment
+// has no uncle term, so unlike the Byzantium formula it takes no uncled flag.
+func CalcDifficultyFromSolvetime(config *DifficultyConfig, parentDiff *big.Int, parentNumber, solvetime uint64) *big.Int {
+	return calcDifficultyFromSolvetime(config, parentDiff, parentNumber, solvetime).Difficulty
+}
+
+// DifficultyEvaluator returns the difficulty adjustment of the config as a pure
+// function of primitive inputs, to drive the formula from outside of go-quai,
+// such as scripting or simulation environments, with the config marshalled to
+// JSON as its description. The adjustment does not account for uncles, so the
+// uncled flag is accepted for the inputs to match those of other algorithms,
+// but ignored.
+func DifficultyEvaluator(config *DifficultyConfig) func(parentDiff, parentNumber, solvetime uint64, uncled bool) *big.Int {
+	return func(parentDiff, parentNumber, solvetime uint64, uncled bool) *big.Int {
+		return CalcDifficultyFromSolvetime(config, new(big.Int).SetUint64(parentDiff), parentNumber, solvetime)
+	}
+}
+
+// calcDifficultyFromSolvetime implements the difficulty adjustment algorithm.
+func calcDifficultyFromSolvetime(config *DifficultyConfig, parentDiff *big.Int, parentNumber, solvetime uint64) DifficultyResult {
+	///// Algorithm:
+	///// e = (DurationLimit - (parent.Time() - parentOfParent.Time())) * parent.Difficulty()
+	///// k = Floor(BinaryLog(parent.Difficulty()))/(DurationLimit*DifficultyAdjustmentFactor*AdjustmentPeriod)
+	///// Difficulty = Max(parent.Difficulty() + e * k, MinimumDifficulty)
+
+	if config.frozen(parentNumber + 1) {
+		return DifficultyResult{
+			Difficulty: assertDifficultyInvariants(new(big.Int).Set(parentDiff), config.MinDifficulty, parentDiff, nil),
+			Algorithm:  DifficultyAlgoFreeze,
+			Adjustment: new(big.Int),
+		}
+	}
+	if config.Oracle != nil {
+		if difficulty, ok := config.Oracle(parentNumber + 1); ok {
+			return oracleDifficulty(config, parentDiff, parentNumber+1, difficulty)
+		}
+	}
+	var (
+		x       *big.Int
+		result  = DifficultyResult{Algorithm: DifficultyAlgoLog2}
+		minimum = config.minDifficulty(parentNumber + 1)
+	)
+	if config.emergencyTriggered(solvetime) {
+		// drop the difficulty sharply, regardless of the normal step
+		x = config.emergencyDifficulty(parentDiff)
+		result.Adjustment = new(big.Int).Sub(x, parentDiff)
+		result.Emergency = true
+	} else {
+		if solvetime < config.MinSolvetime {
+			solvetime = config.MinSolvetime
+		}
+		// holds intermediate values to make the algo easier to read & audit
+		x = new(big.Int).SetUint64(solvetime)
+		x.Sub(config.DurationLimit, x)
+		x.Mul(x, parentDiff)
+		k, _ := mathutil.BinaryLog(new(big.Int).Set(parentDiff), 64)
+		x.Mul(x, big.NewInt(int64(k)))
+		if config.Rounding == RoundingNearest {
+			divisor := new(big.Int).Mul(config.DurationLimit, big.NewInt(params.DifficultyAdjustmentFactor))
+			divisor.Mul(divisor, params.DifficultyAdjustmentPeriod)
+			x = quoNearest(x, divisor)
+		} else {
+			x.Div(x, config.DurationLimit)
+			x.Div(x, big.NewInt(params.DifficultyAdjustmentFactor))
+			x.Div(x, params.DifficultyAdjustmentPeriod)
+		}
+
+		result.Adjustment = new(big.Int).Set(x)
+
+		// cap the adjustment to the configured fraction of the parent difficulty
+		if config.BoundDivisor != nil {
+			bound := new(big.Int).Div(parentDiff, config.BoundDivisor)
+			if x.CmpAbs(bound) > 0 {
+				if x.Sign() < 0 {
+					bound.Neg(bound)
+				}
+				config.clamped(parentNumber+1, ClampAdjustment, x, bound)
+				x.Set(bound)
+				result.AdjustCapped = true
+			}
+		}
+		x.Add(x, parentDiff)
+	}
+	// minimum difficulty can ever be (before exponential factor)
+	if x.Cmp(minimum) < 0 {
+		config.clamped(parentNumber+1, ClampMinimum, x, minimum)
+		x.Set(minimum)
+		result.MinClamped = true
+	}
+	// add the exponential factor, if a difficulty bomb is configured
+	if config.Bomb != nil {
+		if result.BombTerm = config.Bomb.term(parentNumber + 1); result.BombTerm != nil {
+			x.Add(x, result.BombTerm)
+		}
+	}
+	// maximum difficulty can ever be (after exponential factor)
+	bombTerm := result.BombTerm
+	if config.MaxDifficulty != nil && x.Cmp(config.MaxDifficulty) > 0 {
+		config.clamped(parentNumber+1, ClampMaximum, x, config.MaxDifficulty)
+		x.Set(config.MaxDifficulty)
+		result.MaxClamped = true
+		bombTerm = nil // the cap may have swallowed the bomb term
+	}
+	result.Difficulty = assertDifficultyInvariants(x, minimum, parentDiff, bombTerm)
+	return result
+}
+
+// quoNearest returns x/y for a positive y, rounded to the nearest integer with
+// halves rounded away from zero.
+func quoNearest(x, y *big.Int) *big.Int {
+	q := new(big.Int).Abs(x)
+	q.Lsh(q, 1).Add(q, y)
+	q.Quo(q, new(big.Int).Lsh(y, 1))
+	if x.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q
+}
+
+// oracleDifficulty wraps a difficulty injected by the oracle into a result.
+func oracleDifficulty(config *DifficultyConfig, parentDiff *big.Int, number uint64, difficulty *big.Int) DifficultyResult {
+	result := DifficultyResult{
+		Difficulty: new(big.Int).Set(difficulty),
+		Algorithm:  DifficultyAlgoOracle,
+		Adjustment: new(big.Int).Sub(difficulty, parentDiff),
+	}
+	minimum := config.minDifficulty(number)
+	if result.Difficulty.Cmp(minimum) < 0 {
+		config.clamped(number, ClampMinimum, result.Difficulty, minimum)
+		result.Difficulty.Set(minimum)
+		result.MinClamped = true
+	}
+	if config.MaxDifficulty != nil && result.Difficulty.Cmp(config.MaxDifficulty) > 0 {
+		config.clamped(number, ClampMaximum, result.Difficulty, config.MaxDifficulty)
+		result.Difficulty.Set(config.MaxDifficulty)
+		result.MaxClamped = true
+	}
+	// scripted difficulties are exempt from the plausibility check
+	result.Difficulty = assertDifficultyInvariants(result.Difficulty, minimum, nil, nil)
+	return result
+}
+
+// CalcDifficultyChecked is like CalcDifficulty, but returns ErrNilParent
+// instead of panicking when no parent header is supplied.
+func CalcDifficultyChecked(config *DifficultyConfig, time uint64, parent *types.Header) (*big.Int, error) {
+	if parent == nil {
+		return nil, ErrNilParent
+	}
+	return CalcDifficulty(config, time, parent), nil
+}
+
+// PrepareDifficulty returns the difficulty the proof-of-work engines assign to
+// the header of a block built on top of parent when preparing it, for tooling
+// to predict it without running an engine. The adjustment is keyed to the
+// solvetime of the parent, so grandparent, nil if unknown, is required rather
+// than the timestamp of the new block. The blocks following the genesis,
+// identified by its hash, and those whose grandparent is unknown inherit the
+// difficulty of their parent, as the solvetime of the parent is not defined.
+func PrepareDifficulty(config *DifficultyConfig, parent, grandparent *types.Header, genesis common.Hash) *big.Int {
+	if parent == nil {
+		return new(big.Int).Set(config.MinDifficulty)
+	}
+	if parent.Hash() == genesis || grandparent == nil || grandparent.Hash() == genesis {
+		return new(big.Int).Set(parent.Difficulty())
+	}
+	return CalcDifficulty(config, grandparent.Time(), parent)
+}
+
+// chainDifficulty returns the difficulty PrepareDifficulty assigns to the child
+// of parent within a bare chain segment, where the genesis hash is not at hand
+// and the genesis is identified by its block number instead.
+func chainDifficulty(config *DifficultyConfig, grandparent, parent *types.Header) *big.Int {
+	var genesis common.Hash
+	if grandparent.NumberU64() == 0 {
+		genesis = grandparent.Hash()
+	}
+	return PrepareDifficulty(config, parent, grandparent, genesis)
+}
@@ -0,0 +1,119 @@
+package misc
+
+import (
+	"errors"
+	"math/big"
+)
+
+// DifficultyAlgoASERT names the absolutely scheduled exponentially rising
+// targets algorithm implemented by CalcASERTDifficulty.
+const DifficultyAlgoASERT = "asert"
+
+var (
+	// ErrASERTAnchorAhead is returned when the ASERT anchor is not before the
+	// block whose difficulty is being computed.
+	ErrASERTAnchorAhead = errors.New("asert anchor is ahead of the parent block")
+
+	// ErrASERTHalfLife is returned when the ASERT half life is zero, leaving the
+	// drift of the schedule without a scale.
+	ErrASERTHalfLife = errors.New("asert half life must be positive")
+
+	// asertRadix is the fixed point precision of the ASERT exponent, 2^16.
+	asertRadix = big.NewInt(1 << 16)
+)
+
+// ASERTAnchor is the reference block ASERT schedules difficulty relative to.
+type ASERTAnchor struct {
+	Number     uint64   `json:"number"`     // Block number of the anchor
+	Time       uint64   `json:"time"`       // Timestamp of the anchor
+	Difficulty *big.Int `json:"difficulty"` // Difficulty of the block following an on schedule anchor
+}
+
+// ASERTConfig holds the parameters of the ASERT difficulty algorithm. Rather
+// than reacting to the last solvetime, ASERT derives difficulty from how far
+// the parent is ahead of or behind the ideal schedule since the anchor: every
+// HalfLife seconds of drift doubles or halves the anchor difficulty.
+type ASERTConfig struct {
+	TargetSpacing uint64      `json:"targetSpacing"`           // Ideal block time, in seconds
+	HalfLife      uint64      `json:"halfLife"`                // Drift in seconds which doubles or halves the difficulty
+	MinDifficulty *big.Int    `json:"minDifficulty"`           // Minimum difficulty the algorithm may ever yield
+	MaxDifficulty *big.Int    `json:"maxDifficulty,omitempty"` // Maximum difficulty the algorithm may ever yield, if set
+	Anchor        ASERTAnchor `json:"anchor"`                  // Reference block of the schedule
+}
+
+// WithMaxDifficulty returns a copy of the config capping the difficulty to max.
+// The cap should not be below MinDifficulty, as the minimum takes precedence. A
+// nil max removes the cap.
+func (c *ASERTConfig) WithMaxDifficulty(max *big.Int) *ASERTConfig {
+	cpy := *c
+	cpy.MaxDifficulty = max
+	return &cpy
+}
+
+// WithASERTCheckpoint returns a copy of the config anchored at a checkpoint
+// rather than the original anchor, so difficulty can be computed without the
+// history back to it. Anchoring at a checkpoint whose difficulty is the one
+// the original anchor schedules for it yields identical difficulties.
+func (c *ASERTConfig) WithASERTCheckpoint(number uint64, timestamp uint64, difficulty *big.Int) *ASERTConfig {
+	cpy := *c
+	cpy.Anchor = ASERTAnchor{
+		Number:     number,
+		Time:       timestamp,
+		Difficulty: new(big.Int).Set(difficulty),
+	}
+	return &cpy
+}
+
+// CalcASERTDifficulty computes the difficulty of the block following the
+// parent with the given number and timestamp. The anchor must not be after
+// the parent, and the half life must be positive.
+func CalcASERTDifficulty(config *ASERTConfig, parentNumber uint64, parentTime uint64) (*big.Int, error) {
+	if config.HalfLife == 0 {
+		return nil, ErrASERTHalfLife
+	}
+	anchor := config.Anchor
+	if parentNumber < anchor.Number {
+		return nil, ErrASERTAnchorAhead
+	}
+	///// Algorithm (aserti3-2d, expressed on difficulty instead of target):
+	///// exponent = (TargetSpacing * (parentNumber - anchor.Number) - (parentTime - anchor.Time)) / HalfLife
+	///// Difficulty = Min(Max(anchor.Difficulty * 2^exponent, MinDifficulty), MaxDifficulty)
+
+	// holds intermediate values to make the algo easier to read & audit
+	ideal := new(big.Int).SetUint64(config.TargetSpacing)
+	ideal.Mul(ideal, new(big.Int).SetUint64(parentNumber-anchor.Number))
+	actual := new(big.Int).SetUint64(parentTime)
+	actual.Sub(actual, new(big.Int).SetUint64(anchor.Time))
+
+	exponent := new(big.Int).Sub(ideal, actual)
+	exponent.Mul(exponent, asertRadix)
+	exponent.Div(exponent, new(big.Int).SetUint64(config.HalfLife)) // floors, being euclidean
+
+	// Split the fixed point exponent into whole shifts and a fractional part
+	shifts := new(big.Int).Rsh(exponent, 16)
+	frac := new(big.Int).Sub(exponent, new(big.Int).Lsh(shifts, 16)).Uint64()
+
+	// 2^frac is approximated by a cubic polynomial, in 16 bit fixed point
+	factor := new(big.Int).Mul(big.NewInt(195766423245049), new(big.Int).SetUint64(frac))
+	factor.Add(factor, new(big.Int).Mul(big.NewInt(971821376), new(big.Int).SetUint64(frac*frac)))
+	factor.Add(factor, new(big.Int).Mul(big.NewInt(5127), new(big.Int).SetUint64(frac*frac*frac)))
+	factor.Add(factor, new(big.Int).Lsh(big.NewInt(1), 47))
+	factor.Rsh(factor, 48)
+	factor.Add(factor, asertRadix)
+
+	x := new(big.Int).Mul(anchor.Difficulty, factor)
+	if shift := shifts.Int64() - 16; shift >= 0 {
+		x.Lsh(x, uint(shift))
+	} else {
+		x.Rsh(x, uint(-shift))
+	}
+	// minimum difficulty can ever be
+	if x.Cmp(config.MinDifficulty) < 0 {
+		x.Set(config.MinDifficulty)
+	}
+	// maximum difficulty can ever be
+	if config.MaxDifficulty != nil && x.Cmp(config.MaxDifficulty) > 0 {
+		x.Set(config.MaxDifficulty)
+	}
+	return assertDifficultyInvariants(x, config.MinDifficulty, nil, nil), nil
+}
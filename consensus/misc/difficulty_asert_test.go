@@ -0,0 +1,113 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+)
+
+func testASERTConfig() *ASERTConfig {
+	return &ASERTConfig{
+		TargetSpacing: 12,
+		HalfLife:      3600,
+		MinDifficulty: big.NewInt(1000),
+		Anchor: ASERTAnchor{
+			Number:     0,
+			Time:       1000,
+			Difficulty: big.NewInt(1000000),
+		},
+	}
+}
+
+func TestCalcASERTDifficulty(t *testing.T) {
+	config := testASERTConfig()
+	tests := []struct {
+		number uint64
+		time   uint64
+		want   int64
+	}{
+		{number: 0, time: 1000, want: 1000000},          // on schedule
+		{number: 300, time: 1000 + 3600, want: 1000000}, // on schedule
+		{number: 300, time: 1000, want: 2000000},        // a half life ahead
+		{number: 300, time: 1000 + 7200, want: 500000},  // a half life behind
+		{number: 0, time: 1000 + 1000*3600, want: 1000}, // far behind, clamped
+	}
+	for i, tt := range tests {
+		have, err := CalcASERTDifficulty(config, tt.number, tt.time)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("test %d: difficulty mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Fractional drifts interpolate between the doublings
+	have, _ := CalcASERTDifficulty(config, 150, 1000)
+	if have.Cmp(big.NewInt(1400000)) < 0 || have.Cmp(big.NewInt(1430000)) > 0 {
+		t.Errorf("half shift difficulty out of range: have %v, want ~1414213", have)
+	}
+	// A zero half life is rejected rather than divided by
+	config.HalfLife = 0
+	if _, err := CalcASERTDifficulty(config, 300, 1000); err != ErrASERTHalfLife {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrASERTHalfLife)
+	}
+}
+
+func TestASERTCheckpoint(t *testing.T) {
+	config := testASERTConfig()
+
+	// Checkpoints on the real schedule: one exactly on time, and one a full
+	// half life ahead of it, carrying the difficulty the anchor schedules
+	checkpoints := []*ASERTConfig{
+		config.WithASERTCheckpoint(500, 1000+500*12, big.NewInt(1000000)),
+		config.WithASERTCheckpoint(800, 1000+800*12-3600, big.NewInt(2000000)),
+	}
+	for i, checkpoint := range checkpoints {
+		for _, block := range []struct{ number, time uint64 }{
+			{1000, 1000 + 1000*12},
+			{1000, 1000 + 1000*12 - 1234},
+			{1200, 1000 + 1200*12 + 5000},
+			{5000, 1000 + 4000*12},
+		} {
+			want, err := CalcASERTDifficulty(config, block.number, block.time)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			have, err := CalcASERTDifficulty(checkpoint, block.number, block.time)
+			if err != nil {
+				t.Fatalf("checkpoint %d: unexpected error: %v", i, err)
+			}
+			if have.Cmp(want) != 0 {
+				t.Errorf("checkpoint %d, block %d: difficulty mismatch: have %v, want %v", i, block.number, have, want)
+			}
+		}
+	}
+	// Blocks before the checkpoint are rejected
+	if _, err := CalcASERTDifficulty(checkpoints[0], 499, 1000); err != ErrASERTAnchorAhead {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrASERTAnchorAhead)
+	}
+	if config.Anchor.Number != 0 {
+		t.Fatalf("original config mutated")
+	}
+}
+
+func TestASERTMaxDifficulty(t *testing.T) {
+	// A half life ahead of schedule doubles the anchor difficulty
+	config := testASERTConfig()
+	tests := []struct {
+		max  *big.Int
+		want int64
+	}{
+		{max: nil, want: 2000000},
+		{max: big.NewInt(3000000), want: 2000000},
+		{max: big.NewInt(1500000), want: 1500000},
+	}
+	for i, tt := range tests {
+		have, err := CalcASERTDifficulty(config.WithMaxDifficulty(tt.max), 300, 1000)
+		if err != nil {
+			t.Fatalf("test %d: failed to compute difficulty: %v", i, err)
+		}
+		if have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("test %d: difficulty mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package misc
+
+import (
+	"math/big"
+	"sort"
+)
+
+// BombDelay postpones the difficulty bomb by Delay blocks from the block
+// number Activation onwards.
+type BombDelay struct {
+	Activation uint64 `json:"activation"`
+	Delay      uint64 `json:"delay"`
+}
+
+// DifficultyBomb configures an exponentially growing difficulty term, which
+// doubles every Period blocks. Like Ethereum's ice age, the bomb is evaluated
+// against a fake block number lagging behind the real one by the delay active
+// at the block, letting forks defuse it by scheduling further delays.
+type DifficultyBomb struct {
+	Period uint64      `json:"period"`           // Number of blocks between doublings
+	Delays []BombDelay `json:"delays,omitempty"` // Delay schedule, in any order
+}
+
+// DelayAt returns the bomb delay in effect at the given block number, that is
+// the delay of the latest activation not after it.
+func (b *DifficultyBomb) DelayAt(number uint64) uint64 {
+	var (
+		delay      uint64
+		activation uint64
+		found      bool
+	)
+	for _, d := range b.Delays {
+		if d.Activation <= number && (!found || d.Activation >= activation) {
+			delay, activation, found = d.Delay, d.Activation, true
+		}
+	}
+	return delay
+}
+
+// FakeBlockNumber returns the block number the bomb is evaluated against at
+// the given real block number.
+func (b *DifficultyBomb) FakeBlockNumber(number uint64) uint64 {
+	if delay := b.DelayAt(number); number > delay {
+		return number - delay
+	}
+	return 0
+}
+
+// Activations returns the delay activation blocks in ascending order.
+func (b *DifficultyBomb) Activations() []uint64 {
+	activations := make([]uint64, 0, len(b.Delays))
+	for _, d := range b.Delays {
+		activations = append(activations, d.Activation)
+	}
+	sort.Slice(activations, func(i, j int) bool { return activations[i] < activations[j] })
+	return activations
+}
+
+// term returns the bomb's contribution to the difficulty of the block with the
+// given number, 2^(periodCount-2), or nil before the second period has passed.
+func (b *DifficultyBomb) term(number uint64) *big.Int {
+	if b.Period == 0 {
+		return nil
+	}
+	periodCount := b.FakeBlockNumber(number) / b.Period
+	if periodCount <= 1 {
+		return nil
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(periodCount-2))
+}
+
+// IsBombActive returns whether the difficulty bomb of the config contributes to
+// the difficulty of the block with the given number, taking the delay schedule
+// into account. Blocks held by a difficulty freeze carry no bomb term.
+func IsBombActive(config *DifficultyConfig, number uint64) bool {
+	if config.Bomb == nil || config.frozen(number) {
+		return false
+	}
+	return config.Bomb.term(number) != nil
+}
+
+// BlocksUntilNextBombIncrease returns how many blocks after the block with the
+// given number the bomb term of the config grows next, that is doubles, or
+// appears if it is not active yet. The countdown follows the delay schedule, so
+// a delay scheduled ahead postpones the increase. Difficulty freezes are not
+// accounted for. Zero is returned if the config has no bomb.
+func BlocksUntilNextBombIncrease(config *DifficultyConfig, number uint64) uint64 {
+	bomb := config.Bomb
+	if bomb == nil || bomb.Period == 0 {
+		return 0
+	}
+	// The term grows once the fake block number crosses the next period
+	// boundary, and appears no sooner than at the second one
+	target := bomb.FakeBlockNumber(number)/bomb.Period + 1
+	if target < 2 {
+		target = 2
+	}
+	boundary := target * bomb.Period
+
+	// Walk the delay segments ahead, each shifting the fake block number
+	from := number
+	for _, activation := range bomb.Activations() {
+		if activation <= from {
+			continue
+		}
+		if at := boundary + bomb.DelayAt(from); at < activation {
+			return bombIncreaseAfter(at, from, number)
+		}
+		from = activation
+	}
+	return bombIncreaseAfter(boundary+bomb.DelayAt(from), from, number)
+}
+
+// bombIncreaseAfter returns the distance from number to the block at which the
+// fake block number of a delay segment starting at from reaches the boundary.
+// A segment whose smaller delay lands past the boundary right away increases
+// the term at its start.
+func bombIncreaseAfter(at, from, number uint64) uint64 {
+	if at < from {
+		at = from
+	}
+	return at - number
+}
@@ -0,0 +1,156 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+)
+
+func testBomb() *DifficultyBomb {
+	return &DifficultyBomb{
+		Period: 100,
+		Delays: []BombDelay{
+			{Activation: 2000, Delay: 1500},
+			{Activation: 1000, Delay: 500},
+		},
+	}
+}
+
+func TestBombDelaySchedule(t *testing.T) {
+	bomb := testBomb()
+	tests := []struct {
+		number uint64
+		delay  uint64
+		fake   uint64
+	}{
+		{number: 0, delay: 0, fake: 0},
+		{number: 999, delay: 0, fake: 999},
+		{number: 1000, delay: 500, fake: 500},
+		{number: 1999, delay: 500, fake: 1499},
+		{number: 2000, delay: 1500, fake: 500},
+		{number: 5000, delay: 1500, fake: 3500},
+	}
+	for _, tt := range tests {
+		if have := bomb.DelayAt(tt.number); have != tt.delay {
+			t.Errorf("block %d: delay mismatch: have %d, want %d", tt.number, have, tt.delay)
+		}
+		if have := bomb.FakeBlockNumber(tt.number); have != tt.fake {
+			t.Errorf("block %d: fake block number mismatch: have %d, want %d", tt.number, have, tt.fake)
+		}
+	}
+	if have := bomb.Activations(); len(have) != 2 || have[0] != 1000 || have[1] != 2000 {
+		t.Errorf("activations mismatch: have %v", have)
+	}
+}
+
+func TestBombDefuse(t *testing.T) {
+	config := testDifficultyConfig()
+	config.Bomb = testBomb()
+	parentDiff := big.NewInt(1000000)
+	base := CalcDifficultyFromSolvetime(testDifficultyConfig(), parentDiff, 0, 12)
+
+	tests := []struct {
+		parentNumber uint64 // the bomb is evaluated at the child, parentNumber+1
+		term         int64
+	}{
+		{parentNumber: 198, term: 0},      // fake block 199, first period
+		{parentNumber: 199, term: 1},      // fake block 200, 2^0
+		{parentNumber: 998, term: 1 << 7}, // fake block 999, 2^7
+		{parentNumber: 999, term: 1 << 3}, // defused to fake block 500, 2^3
+		{parentNumber: 1998, term: 1 << 12},
+		{parentNumber: 1999, term: 1 << 3}, // defused again to fake block 500
+	}
+	for _, tt := range tests {
+		result := calcDifficultyFromSolvetime(config, parentDiff, tt.parentNumber, 12)
+		want := new(big.Int).Add(base, big.NewInt(tt.term))
+		if result.Difficulty.Cmp(want) != 0 {
+			t.Errorf("parent %d: difficulty mismatch: have %v, want %v", tt.parentNumber, result.Difficulty, want)
+		}
+		if (result.BombTerm != nil) != (tt.term != 0) {
+			t.Errorf("parent %d: bomb term presence mismatch: have %v", tt.parentNumber, result.BombTerm)
+		}
+	}
+}
+
+func TestIsBombActive(t *testing.T) {
+	config := testDifficultyConfig()
+	if IsBombActive(config, 10000) {
+		t.Fatalf("bomb reported active without a bomb")
+	}
+	config.Bomb = &DifficultyBomb{
+		Period: 100,
+		Delays: []BombDelay{{Activation: 1000, Delay: 900}},
+	}
+	tests := []struct {
+		number uint64
+		active bool
+	}{
+		{number: 150, active: false}, // first period
+		{number: 199, active: false},
+		{number: 200, active: true}, // 2^0 from the second period on
+		{number: 999, active: true},
+		{number: 1000, active: false}, // defused to fake block 100
+		{number: 1099, active: false},
+		{number: 1100, active: true}, // fake block 200
+	}
+	for _, tt := range tests {
+		if have := IsBombActive(config, tt.number); have != tt.active {
+			t.Errorf("block %d: bomb activity mismatch: have %v, want %v", tt.number, have, tt.active)
+		}
+	}
+	// Frozen blocks carry no bomb term
+	if IsBombActive(config.WithDifficultyFreeze(900, 999), 950) {
+		t.Fatalf("bomb reported active on a frozen block")
+	}
+}
+
+func TestBlocksUntilNextBombIncrease(t *testing.T) {
+	config := testDifficultyConfig()
+	if have := BlocksUntilNextBombIncrease(config, 1000); have != 0 {
+		t.Fatalf("countdown without a bomb: have %d, want 0", have)
+	}
+	config.Bomb = testBomb()
+	tests := []struct {
+		number uint64
+		want   uint64
+	}{
+		{number: 0, want: 200},    // appears at the second period
+		{number: 150, want: 50},   // still to appear
+		{number: 250, want: 50},   // doubles at the next period boundary
+		{number: 299, want: 1},    // right before the boundary
+		{number: 300, want: 100},  // right on the boundary
+		{number: 950, want: 550},  // the upcoming delay postpones the boundary
+		{number: 1000, want: 100}, // period boundaries shifted by the delay
+		{number: 1950, want: 1050},
+		{number: 2500, want: 100},
+	}
+	for _, tt := range tests {
+		if have := BlocksUntilNextBombIncrease(config, tt.number); have != tt.want {
+			t.Errorf("block %d: countdown mismatch: have %d, want %d", tt.number, have, tt.want)
+		}
+	}
+	// Cross check the countdown against the bomb terms block by block, also
+	// for a schedule shortening the delay
+	shortened := testDifficultyConfig()
+	shortened.Bomb = &DifficultyBomb{
+		Period: 100,
+		Delays: []BombDelay{{Activation: 0, Delay: 1000}, {Activation: 500, Delay: 0}},
+	}
+	termAt := func(config *DifficultyConfig, number uint64) *big.Int {
+		if term := config.Bomb.term(number); term != nil {
+			return term
+		}
+		return new(big.Int)
+	}
+	for _, config := range []*DifficultyConfig{config, shortened} {
+		for number := uint64(0); number < 2500; number += 7 {
+			current := termAt(config, number)
+			next := number + 1
+			for termAt(config, next).Cmp(current) <= 0 {
+				next++
+			}
+			if have, want := BlocksUntilNextBombIncrease(config, number), next-number; have != want {
+				t.Fatalf("block %d: countdown mismatch: have %d, want %d", number, have, want)
+			}
+		}
+	}
+}
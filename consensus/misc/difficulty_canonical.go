@@ -0,0 +1,90 @@
+package misc
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// CanonicalBytes returns a deterministic encoding of the consensus relevant
+// parameters of the config, so that nodes can verify they run identical
+// difficulty rules by comparing its hash, e.g. against one committed in the
+// genesis. Fields are encoded in a fixed order, integers as big-endian,
+// floats by their IEEE 754 bits, and optional fields are prefixed by whether
+// they are set. Bomb delays are sorted by activation, as their order carries
+// no meaning. Hooks such as the oracle and the sinks are not encoded.
+func (c *DifficultyConfig) CanonicalBytes() []byte {
+	var enc canonicalEncoder
+	enc.bigInt(c.DurationLimit)
+	enc.bigInt(c.MinDifficulty)
+	enc.bigInt(c.MaxDifficulty)
+	enc.bigInt(c.BoundDivisor)
+	enc.uint64(uint64(c.Rounding))
+	enc.uint64(c.MinSolvetime)
+
+	enc.flag(c.Bomb != nil)
+	if c.Bomb != nil {
+		delays := append([]BombDelay(nil), c.Bomb.Delays...)
+		sort.Slice(delays, func(i, j int) bool {
+			if delays[i].Activation != delays[j].Activation {
+				return delays[i].Activation < delays[j].Activation
+			}
+			return delays[i].Delay < delays[j].Delay
+		})
+		enc.uint64(c.Bomb.Period)
+		enc.uint64(uint64(len(delays)))
+		for _, delay := range delays {
+			enc.uint64(delay.Activation)
+			enc.uint64(delay.Delay)
+		}
+	}
+	enc.uint64(math.Float64bits(c.EmergencyMultiplier))
+	enc.uint64(math.Float64bits(c.EmergencyTrigger))
+	for _, target := range c.ContextTargetTimes {
+		enc.uint64(target)
+	}
+	enc.flag(c.Freeze != nil)
+	if c.Freeze != nil {
+		enc.uint64(c.Freeze.From)
+		enc.uint64(c.Freeze.To)
+	}
+	enc.flag(c.LaunchDecay != nil)
+	if c.LaunchDecay != nil {
+		enc.bigInt(c.LaunchDecay.Start)
+		enc.uint64(c.LaunchDecay.Blocks)
+	}
+	return enc.buf
+}
+
+// canonicalEncoder appends the fields of a canonical encoding to a buffer.
+type canonicalEncoder struct {
+	buf []byte
+}
+
+// flag encodes a boolean as a single byte.
+func (e *canonicalEncoder) flag(set bool) {
+	if set {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// uint64 encodes an integer as 8 big-endian bytes.
+func (e *canonicalEncoder) uint64(x uint64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, x)
+}
+
+// bigInt encodes an optional big integer as its presence, sign, and length
+// prefixed big-endian magnitude.
+func (e *canonicalEncoder) bigInt(x *big.Int) {
+	e.flag(x != nil)
+	if x == nil {
+		return
+	}
+	e.flag(x.Sign() < 0)
+	magnitude := x.Bytes()
+	e.uint64(uint64(len(magnitude)))
+	e.buf = append(e.buf, magnitude...)
+}
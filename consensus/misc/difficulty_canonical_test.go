@@ -0,0 +1,74 @@
+package misc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+)
+
+func TestCanonicalBytesStable(t *testing.T) {
+	config := testDifficultyConfig()
+
+	// The encoding is pinned, so that it cannot drift between releases
+	want := "01" + "00" + "0000000000000001" + "0c" + // durationLimit
+		"01" + "00" + "0000000000000002" + "03e8" + // minDifficulty
+		"00" + "00" + // maxDifficulty, boundDivisor
+		"0000000000000000" + "0000000000000000" + // rounding, minSolvetime
+		"00" + // bomb
+		"0000000000000000" + "0000000000000000" + // emergency multiplier, trigger
+		"000000000000000000000000000000000000000000000000" + // context target times
+		"00" + "00" // freeze, launch decay
+	if have := hex.EncodeToString(config.CanonicalBytes()); have != want {
+		t.Fatalf("encoding mismatch:\nhave %s\nwant %s", have, want)
+	}
+	// Hooks are not part of the rules, nor is the order of the bomb delays
+	hooked := config.WithClampSink(func(ClampEvent) {}).WithDifficultyOracle(func(uint64) (*big.Int, bool) { return nil, false })
+	if !bytes.Equal(hooked.CanonicalBytes(), config.CanonicalBytes()) {
+		t.Fatalf("hooks altered the encoding")
+	}
+	bombed, reordered := *config, *config
+	bombed.Bomb = testBomb()
+	reordered.Bomb = testBomb()
+	reordered.Bomb.Delays[0], reordered.Bomb.Delays[1] = reordered.Bomb.Delays[1], reordered.Bomb.Delays[0]
+	if !bytes.Equal(bombed.CanonicalBytes(), reordered.CanonicalBytes()) {
+		t.Fatalf("bomb delay order altered the encoding")
+	}
+}
+
+func TestCanonicalBytesSensitivity(t *testing.T) {
+	base := testDifficultyConfig()
+	base.Bomb = testBomb()
+
+	mutations := map[string]func(c *DifficultyConfig){
+		"durationLimit": func(c *DifficultyConfig) { c.DurationLimit = big.NewInt(13) },
+		"minDifficulty": func(c *DifficultyConfig) { c.MinDifficulty = big.NewInt(1001) },
+		"maxDifficulty": func(c *DifficultyConfig) { c.MaxDifficulty = big.NewInt(1000000) },
+		"boundDivisor":  func(c *DifficultyConfig) { c.BoundDivisor = big.NewInt(2048) },
+		"rounding":      func(c *DifficultyConfig) { c.Rounding = RoundingNearest },
+		"minSolvetime":  func(c *DifficultyConfig) { c.MinSolvetime = 1 },
+		"bomb":          func(c *DifficultyConfig) { c.Bomb = nil },
+		"bombPeriod":    func(c *DifficultyConfig) { c.Bomb = &DifficultyBomb{Period: 101, Delays: testBomb().Delays} },
+		"bombDelay": func(c *DifficultyConfig) {
+			c.Bomb = &DifficultyBomb{Period: 100, Delays: []BombDelay{{Activation: 1000, Delay: 501}}}
+		},
+		"emergencyMult":  func(c *DifficultyConfig) { c.EmergencyMultiplier = 2 },
+		"emergencyTrig":  func(c *DifficultyConfig) { c.EmergencyTrigger = 3 },
+		"contextTargets": func(c *DifficultyConfig) { c.ContextTargetTimes = [common.HierarchyDepth]uint64{0, 0, 5} },
+		"freeze":         func(c *DifficultyConfig) { c.Freeze = &DifficultyFreeze{From: 1, To: 2} },
+		"launchDecay":    func(c *DifficultyConfig) { c.LaunchDecay = &LaunchDecay{Start: big.NewInt(5000), Blocks: 10} },
+		"negative":       func(c *DifficultyConfig) { c.MinDifficulty = big.NewInt(-1000) },
+	}
+	seen := map[string]string{string(base.CanonicalBytes()): "base"}
+	for name, mutate := range mutations {
+		cpy := *base
+		mutate(&cpy)
+		encoded := string(cpy.CanonicalBytes())
+		if other, ok := seen[encoded]; ok {
+			t.Errorf("%s: encoding collides with %s", name, other)
+		}
+		seen[encoded] = name
+	}
+}
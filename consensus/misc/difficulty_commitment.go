@@ -0,0 +1,59 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// DifficultyCommitmentLength is the size of a difficulty commitment, stored as
+// the trailing bytes of a header's extra data. As it equals the maximum extra
+// data size, committing headers carry no other extra data.
+const DifficultyCommitmentLength = common.HashLength
+
+var (
+	// ErrMissingDifficultyCommitment is returned when a header's extra data is
+	// too short to hold a difficulty commitment.
+	ErrMissingDifficultyCommitment = errors.New("missing difficulty commitment")
+
+	// ErrDifficultyCommitment is returned when a committed difficulty differs
+	// from the computed one.
+	ErrDifficultyCommitment = errors.New("invalid difficulty commitment")
+)
+
+// CommitDifficulty returns the extra data with the difficulty commitment of
+// diff appended, as the big endian difficulty padded to 32 bytes.
+func CommitDifficulty(extra []byte, diff *big.Int) []byte {
+	return append(common.CopyBytes(extra), common.LeftPadBytes(diff.Bytes(), DifficultyCommitmentLength)...)
+}
+
+// DifficultyCommitment reads the difficulty committed to by a header, from the
+// trailing 32 bytes of its extra data.
+func DifficultyCommitment(header *types.Header) (*big.Int, error) {
+	extra := header.Extra()
+	if len(extra) < DifficultyCommitmentLength {
+		return nil, fmt.Errorf("%w: extra data of %d bytes", ErrMissingDifficultyCommitment, len(extra))
+	}
+	return new(big.Int).SetBytes(extra[len(extra)-DifficultyCommitmentLength:]), nil
+}
+
+// VerifyDifficultyCommitment checks that the difficulty committed to in the
+// extra data of header, for the benefit of light clients, matches the one
+// computed from its parent. As in CalcDifficulty, the time argument is the
+// timestamp of the parent's own parent.
+func VerifyDifficultyCommitment(config *DifficultyConfig, time uint64, parent, header *types.Header) error {
+	if parent == nil {
+		return ErrNilParent
+	}
+	committed, err := DifficultyCommitment(header)
+	if err != nil {
+		return err
+	}
+	if expected := CalcDifficulty(config, time, parent); committed.Cmp(expected) != 0 {
+		return fmt.Errorf("%w: have %v, want %v", ErrDifficultyCommitment, committed, expected)
+	}
+	return nil
+}
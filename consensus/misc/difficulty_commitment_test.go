@@ -0,0 +1,33 @@
+package misc
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestVerifyDifficultyCommitment(t *testing.T) {
+	config := testDifficultyConfig()
+	parent := testParent(1000000, 1002)
+	expected := CalcDifficulty(config, 1000, parent)
+
+	// A matching commitment is accepted
+	header := testParent(expected.Int64(), 1010)
+	header.SetExtra(CommitDifficulty(nil, expected))
+	if err := VerifyDifficultyCommitment(config, 1000, parent, header); err != nil {
+		t.Fatalf("matching commitment rejected: %v", err)
+	}
+	if committed, err := DifficultyCommitment(header); err != nil || committed.Cmp(expected) != 0 {
+		t.Fatalf("commitment mismatch: have %v, %v, want %v", committed, err, expected)
+	}
+	// A forged commitment is rejected
+	header.SetExtra(CommitDifficulty(nil, new(big.Int).Add(expected, big.NewInt(1))))
+	if err := VerifyDifficultyCommitment(config, 1000, parent, header); !errors.Is(err, ErrDifficultyCommitment) {
+		t.Fatalf("forged commitment error mismatch: have %v, want %v", err, ErrDifficultyCommitment)
+	}
+	// A missing commitment is rejected
+	header.SetExtra([]byte("quai"))
+	if err := VerifyDifficultyCommitment(config, 1000, parent, header); !errors.Is(err, ErrMissingDifficultyCommitment) {
+		t.Fatalf("missing commitment error mismatch: have %v, want %v", err, ErrMissingDifficultyCommitment)
+	}
+}
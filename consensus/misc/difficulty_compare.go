@@ -0,0 +1,97 @@
+package misc
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// DifficultyCalculator computes the difficulty of the block following parent,
+// whose own parent is grandparent.
+type DifficultyCalculator func(grandparent, parent *types.Header) (*big.Int, error)
+
+// Calculator returns the logarithmic adjustment algorithm of the config as a
+// DifficultyCalculator. Like the engines, it lets the grandchild of the genesis
+// inherit the difficulty of its parent.
+func (c *DifficultyConfig) Calculator() DifficultyCalculator {
+	return func(grandparent, parent *types.Header) (*big.Int, error) {
+		return chainDifficulty(c, grandparent, parent), nil
+	}
+}
+
+// Calculator returns the ASERT algorithm of the config as a
+// DifficultyCalculator.
+func (c *ASERTConfig) Calculator() DifficultyCalculator {
+	return func(grandparent, parent *types.Header) (*big.Int, error) {
+		return CalcASERTDifficulty(c, parent.NumberU64(), parent.Time())
+	}
+}
+
+// ComparisonReport quantifies how two difficulty algorithms diverge over the
+// same chain segment.
+type ComparisonReport struct {
+	Blocks      int        // Number of blocks both algorithms computed a difficulty for
+	Differences []*big.Int // Per block difficulty of b minus that of a, nil where not computed
+
+	CumulativeDivergence *big.Int // Sum of the per block differences
+	MaxDivergence        *big.Int // Largest absolute per block difference
+
+	OscillationsA int // Number of times the difficulty of a changed direction
+	OscillationsB int // Number of times the difficulty of b changed direction
+
+	Errors []error // Blocks either algorithm failed to compute
+}
+
+// CompareAlgorithms runs two difficulty algorithms over a contiguous chain
+// segment, ordered from oldest to newest, and reports how they diverge. As in
+// ReplayChainDifficulty, the first two headers only anchor the computation, so
+// the differences are aligned with the headers but nil for the anchors.
+func CompareAlgorithms(a, b DifficultyCalculator, headers []*types.Header) ComparisonReport {
+	report := ComparisonReport{
+		Differences:          make([]*big.Int, len(headers)),
+		CumulativeDivergence: new(big.Int),
+		MaxDivergence:        new(big.Int),
+	}
+	var trendA, trendB oscillation
+	for i := 2; i < len(headers); i++ {
+		diffA, errA := a(headers[i-2], headers[i-1])
+		diffB, errB := b(headers[i-2], headers[i-1])
+		if errA != nil || errB != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("header %d (#%d): a: %v, b: %v", i, headers[i].NumberU64(), errA, errB))
+			continue
+		}
+		report.Blocks++
+		report.OscillationsA += trendA.next(diffA)
+		report.OscillationsB += trendB.next(diffB)
+
+		difference := new(big.Int).Sub(diffB, diffA)
+		report.Differences[i] = difference
+		report.CumulativeDivergence.Add(report.CumulativeDivergence, difference)
+		if difference.CmpAbs(report.MaxDivergence) > 0 {
+			report.MaxDivergence.Abs(difference)
+		}
+	}
+	return report
+}
+
+// oscillation tracks the direction changes of a difficulty series.
+type oscillation struct {
+	last      *big.Int // Previous difficulty of the series
+	direction int      // Sign of the last non-zero change
+}
+
+// next feeds the following difficulty of the series, returning 1 if it reverses
+// the direction of the series.
+func (o *oscillation) next(difficulty *big.Int) (reversed int) {
+	if o.last != nil {
+		if direction := difficulty.Cmp(o.last); direction != 0 {
+			if o.direction != 0 && direction != o.direction {
+				reversed = 1
+			}
+			o.direction = direction
+		}
+	}
+	o.last = difficulty
+	return reversed
+}
@@ -0,0 +1,69 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+func TestCompareAlgorithms(t *testing.T) {
+	config := testDifficultyConfig()
+	headers := testChain(config, []uint64{10, 3, 25, 12, 1, 40, 7})
+
+	// An algorithm compared to itself does not diverge
+	report := CompareAlgorithms(config.Calculator(), config.Calculator(), headers)
+	if report.Blocks != len(headers)-2 || report.CumulativeDivergence.Sign() != 0 || report.MaxDivergence.Sign() != 0 {
+		t.Fatalf("self comparison diverged: %+v", report)
+	}
+	if report.OscillationsA != report.OscillationsB || report.OscillationsA == 0 {
+		t.Fatalf("oscillation mismatch: have %d and %d, want equal and non-zero", report.OscillationsA, report.OscillationsB)
+	}
+	// A candidate offset by a known amount diverges by exactly that much
+	offset := func(grandparent, parent *types.Header) (*big.Int, error) {
+		difficulty, _ := config.Calculator()(grandparent, parent)
+		return difficulty.Add(difficulty, big.NewInt(int64(parent.NumberU64()))), nil
+	}
+	report = CompareAlgorithms(config.Calculator(), offset, headers)
+	if want := big.NewInt(1 + 2 + 3 + 4 + 5 + 6); report.CumulativeDivergence.Cmp(want) != 0 {
+		t.Fatalf("cumulative divergence mismatch: have %v, want %v", report.CumulativeDivergence, want)
+	}
+	if report.MaxDivergence.Cmp(big.NewInt(6)) != 0 {
+		t.Fatalf("max divergence mismatch: have %v, want 6", report.MaxDivergence)
+	}
+	for i, difference := range report.Differences {
+		if i < 2 {
+			if difference != nil {
+				t.Errorf("anchor %d has a difference: %v", i, difference)
+			}
+		} else if difference.Cmp(big.NewInt(int64(i-1))) != 0 {
+			t.Errorf("header %d: difference mismatch: have %v, want %d", i, difference, i-1)
+		}
+	}
+	// A constant candidate never oscillates
+	constant := func(grandparent, parent *types.Header) (*big.Int, error) {
+		return big.NewInt(1000000), nil
+	}
+	if report = CompareAlgorithms(config.Calculator(), constant, headers); report.OscillationsB != 0 {
+		t.Fatalf("constant algorithm oscillated %d times", report.OscillationsB)
+	}
+}
+
+func TestCalculatorGenesis(t *testing.T) {
+	config := testDifficultyConfig()
+	headers := testChain(config, []uint64{10, 3, 25})
+
+	// The grandchild of the genesis inherits its parent's difficulty, whatever
+	// the parameters, so perturbed configs do not diverge on it
+	perturbed := *config
+	perturbed.DurationLimit = big.NewInt(24)
+	for _, calc := range []DifficultyCalculator{config.Calculator(), perturbed.Calculator()} {
+		if have, _ := calc(headers[0], headers[1]); have.Cmp(headers[1].Difficulty()) != 0 {
+			t.Fatalf("genesis grandchild difficulty mismatch: have %v, want %v", have, headers[1].Difficulty())
+		}
+	}
+	report := CompareAlgorithms(config.Calculator(), perturbed.Calculator(), headers)
+	if report.Differences[2].Sign() != 0 || report.Differences[3].Sign() == 0 {
+		t.Fatalf("divergence mismatch: have %v, want zero on the genesis grandchild only", report.Differences)
+	}
+}
@@ -0,0 +1,27 @@
+package misc
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+// CalcDifficultyDeterministic returns a DifficultyCalculator deriving the
+// difficulty of each block from a hash of seed and the block number alone,
+// ignoring timestamps, so that fuzzed chains get reproducible difficulties.
+// Difficulties range from the minimum difficulty to just below twice that. It is
+// meant for differential fuzzing only and must never be used for consensus.
+func CalcDifficultyDeterministic(seed uint64) DifficultyCalculator {
+	return func(grandparent, parent *types.Header) (*big.Int, error) {
+		var input [16]byte
+		binary.BigEndian.PutUint64(input[:8], seed)
+		binary.BigEndian.PutUint64(input[8:], parent.NumberU64()+1)
+
+		difficulty := new(big.Int).SetBytes(crypto.Keccak256(input[:]))
+		difficulty.Mod(difficulty, params.MinimumDifficulty)
+		return difficulty.Add(difficulty, params.MinimumDifficulty), nil
+	}
+}
@@ -0,0 +1,42 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+func TestCalcDifficultyDeterministic(t *testing.T) {
+	// Timestamps and difficulties of the ancestors do not matter
+	grandparent, parent := testParent(1000, 100), testParent(5000000, 5000)
+	other := testParent(1000, 101)
+
+	for number := int64(0); number < 16; number++ {
+		parent.SetNumber(big.NewInt(number))
+		other.SetNumber(big.NewInt(number))
+
+		a, _ := CalcDifficultyDeterministic(1)(grandparent, parent)
+		b, _ := CalcDifficultyDeterministic(1)(types.EmptyHeader(), other)
+		if a.Cmp(b) != 0 {
+			t.Errorf("block %d: difficulty mismatch for the same seed: %v != %v", number+1, a, b)
+		}
+		if a.Cmp(params.MinimumDifficulty) < 0 {
+			t.Errorf("block %d: difficulty below minimum: %v", number+1, a)
+		}
+	}
+	// Different seeds yield different difficulty sequences
+	var same int
+	for number := int64(0); number < 16; number++ {
+		parent.SetNumber(big.NewInt(number))
+		a, _ := CalcDifficultyDeterministic(1)(grandparent, parent)
+		b, _ := CalcDifficultyDeterministic(2)(grandparent, parent)
+		if a.Cmp(b) == 0 {
+			same++
+		}
+	}
+	if same == 16 {
+		t.Fatalf("different seeds yield identical difficulties")
+	}
+}
@@ -0,0 +1,92 @@
+package misc
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// emaHistory is the number of most recent blocks whose smoothed solvetime an
+// EMADifficulty retains. Reorgs deeper than this restart the smoothing.
+const emaHistory = 1024
+
+// StatefulDifficultyCalculator is implemented by difficulty algorithms which
+// carry state from block to block, and so must be rewound when the chain
+// reorganizes to avoid computing the new branch from the state of the old one.
+//
+// Of the algorithms in this package, only EMADifficulty is stateful. The
+// logarithmic adjustment of CalcDifficulty only depends on the parent and
+// grandparent headers, and ASERT only on the parent and its configured anchor.
+type StatefulDifficultyCalculator interface {
+	// CalcDifficulty computes the difficulty of the block following parent,
+	// whose own parent is grandparent, advancing the state of the algorithm.
+	CalcDifficulty(grandparent, parent *types.Header) (*big.Int, error)
+
+	// OnReorg rewinds the state of the algorithm to the common ancestor of the
+	// old and new branches, forgetting anything derived from blocks past it.
+	OnReorg(commonAncestor *types.Header)
+}
+
+// EMADifficulty is a difficulty algorithm feeding an exponential moving average
+// of the solvetimes, rather than the last solvetime alone, into the logarithmic
+// adjustment, damping the effect of individual lucky or unlucky blocks. It is
+// safe for concurrent use.
+type EMADifficulty struct {
+	config *DifficultyConfig
+	window int64 // Number of blocks over which solvetimes are averaged
+
+	history map[uint64]int64 // Smoothed solvetime up to each recent block number
+	lock    sync.Mutex
+}
+
+// NewEMADifficulty creates a difficulty algorithm averaging solvetimes over the
+// given number of blocks.
+func NewEMADifficulty(config *DifficultyConfig, window uint64) *EMADifficulty {
+	if window == 0 {
+		window = 1
+	}
+	return &EMADifficulty{
+		config:  config,
+		window:  int64(window),
+		history: make(map[uint64]int64),
+	}
+}
+
+// CalcDifficulty implements StatefulDifficultyCalculator. The average is seeded
+// by the solvetime of the first block processed.
+func (c *EMADifficulty) CalcDifficulty(grandparent, parent *types.Header) (*big.Int, error) {
+	if grandparent == nil || parent == nil {
+		return nil, ErrNilParent
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	number := parent.NumberU64()
+	ema, ok := c.history[number]
+	if !ok {
+		var solvetime int64
+		if parent.Time() > grandparent.Time() {
+			solvetime = int64(parent.Time() - grandparent.Time())
+		}
+		ema = solvetime
+		if prev, ok := c.history[number-1]; ok {
+			ema = prev + (solvetime-prev)/c.window
+		}
+		c.history[number] = ema
+		delete(c.history, number-emaHistory)
+	}
+	return CalcDifficultyFromSolvetime(c.config, parent.Difficulty(), number, uint64(ema)), nil
+}
+
+// OnReorg implements StatefulDifficultyCalculator.
+func (c *EMADifficulty) OnReorg(commonAncestor *types.Header) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for number := range c.history {
+		if number > commonAncestor.NumberU64() {
+			delete(c.history, number)
+		}
+	}
+}
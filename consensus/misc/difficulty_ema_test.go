@@ -0,0 +1,53 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// emaSeries runs a calculator over a chain, returning the difficulty computed
+// on top of each header past the first.
+func emaSeries(t *testing.T, calc StatefulDifficultyCalculator, headers []*types.Header) []*big.Int {
+	t.Helper()
+	series := make([]*big.Int, 0, len(headers)-1)
+	for i := 1; i < len(headers); i++ {
+		difficulty, err := calc.CalcDifficulty(headers[i-1], headers[i])
+		if err != nil {
+			t.Fatalf("failed to compute difficulty on top of header %d: %v", i, err)
+		}
+		series = append(series, difficulty)
+	}
+	return series
+}
+
+func TestEMADifficultyReorg(t *testing.T) {
+	config := testDifficultyConfig()
+
+	// Two branches sharing their first four headers
+	old := testChain(config, []uint64{10, 3, 25, 1, 1, 1, 1})
+	branch := testChain(config, []uint64{10, 3, 25, 40, 40, 40, 40})
+	ancestor := old[3]
+	if ancestor.Hash() != branch[3].Hash() {
+		t.Fatalf("branches do not share their ancestor")
+	}
+	calc := NewEMADifficulty(config, 4)
+	emaSeries(t, calc, old)
+
+	// Without rewinding, the new branch is computed from stale state
+	stale := emaSeries(t, calc, branch)
+
+	// Rewinding to the ancestor matches computing the branch afresh
+	calc.OnReorg(ancestor)
+	rewound := emaSeries(t, calc, branch)
+	fresh := emaSeries(t, NewEMADifficulty(config, 4), branch)
+	for i := range fresh {
+		if rewound[i].Cmp(fresh[i]) != 0 {
+			t.Errorf("block %d: rewound difficulty mismatch: have %v, want %v", i+2, rewound[i], fresh[i])
+		}
+	}
+	if stale[len(stale)-1].Cmp(fresh[len(fresh)-1]) == 0 {
+		t.Errorf("stale state did not affect the new branch")
+	}
+}
@@ -0,0 +1,110 @@
+package misc
+
+import (
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+	"modernc.org/mathutil"
+)
+
+// BlockTimeEstimate estimates how long a block of the given difficulty takes to
+// be mined by a network of the given hashrate, in hashes per second. Solve
+// times are exponentially distributed, so next to the mean of diff/hashrate it
+// returns the 10th and 90th percentiles, between which eight out of ten blocks
+// are found. A non-positive hashrate yields zero durations.
+func BlockTimeEstimate(diff, hashrate *big.Int) (mean time.Duration, p10, p90 time.Duration) {
+	if hashrate == nil || hashrate.Sign() <= 0 {
+		return 0, 0, 0
+	}
+	seconds, _ := new(big.Float).Quo(new(big.Float).SetInt(diff), new(big.Float).SetInt(hashrate)).Float64()
+
+	// The q-th quantile of an exponential distribution is -mean*ln(1-q)
+	quantile := func(q float64) time.Duration {
+		return time.Duration(-seconds * math.Log1p(-q) * float64(time.Second))
+	}
+	return time.Duration(seconds * float64(time.Second)), quantile(0.1), quantile(0.9)
+}
+
+// InferSolvetime inverts the adjustment step of CalcDifficulty, recovering the
+// approximate solvetime which led from parentDiff to childDiff, for forensic
+// analysis of suspicious chains. The child difficulty must exclude any bomb
+// term. Returns false if the change is outside of what the step formula can
+// yield, or if a clamp or the emergency adjustment may have hidden the step.
+func InferSolvetime(config *DifficultyConfig, parentDiff, childDiff *big.Int) (uint64, bool) {
+	if parentDiff.Sign() <= 0 || childDiff.Cmp(config.MinDifficulty) <= 0 {
+		return 0, false
+	}
+	adjustment := new(big.Int).Sub(childDiff, parentDiff)
+	if config.BoundDivisor != nil {
+		if bound := new(big.Int).Div(parentDiff, config.BoundDivisor); adjustment.CmpAbs(bound) >= 0 {
+			return 0, false
+		}
+	}
+	k, _ := mathutil.BinaryLog(new(big.Int).Set(parentDiff), 64)
+	if k == 0 {
+		return 0, false
+	}
+	///// solvetime = DurationLimit - adjustment*DurationLimit*DifficultyAdjustmentFactor*AdjustmentPeriod/(parent.Difficulty()*k)
+	x := new(big.Int).Mul(adjustment, config.DurationLimit)
+	x.Mul(x, big.NewInt(params.DifficultyAdjustmentFactor))
+	x.Mul(x, params.DifficultyAdjustmentPeriod)
+	x = quoNearest(x, new(big.Int).Mul(parentDiff, big.NewInt(int64(k))))
+	x.Sub(config.DurationLimit, x)
+
+	if x.Sign() < 0 || !x.IsUint64() || x.Uint64() < config.MinSolvetime {
+		return 0, false
+	}
+	solvetime := x.Uint64()
+	if config.emergencyTriggered(solvetime) {
+		return 0, false
+	}
+	return solvetime, true
+}
+
+// hashrateWindow is the number of blocks ReconstructHashrate smooths over.
+const hashrateWindow = 8
+
+// HashratePoint is the network hashrate implied by the chain at a block.
+type HashratePoint struct {
+	Number   uint64   // Number of the block
+	Time     uint64   // Timestamp of the block
+	Hashrate *big.Int // Implied hashrate, in hashes per second
+}
+
+// ReconstructHashrate reconstructs the network hashrate over a chain segment,
+// ordered from oldest to newest, for historical charts. The hashrate at each
+// block is the work of the up to eight blocks ending at it over the time they
+// took to be mined, smoothing out the luck of individual solvetimes. The first
+// header only anchors the solvetime of the second, so it has no point of its
+// own. Windows of zero solvetimes are counted as taking a second.
+func ReconstructHashrate(headers []*types.Header) []HashratePoint {
+	if len(headers) < 2 {
+		return nil
+	}
+	points := make([]HashratePoint, 0, len(headers)-1)
+	for i := 1; i < len(headers); i++ {
+		var (
+			work    = new(big.Int)
+			elapsed uint64
+		)
+		for j := i; j > 0 && j > i-hashrateWindow; j-- {
+			work.Add(work, headers[j].Difficulty())
+			// Verified headers are never older than their parent, saturate otherwise
+			if headers[j].Time() > headers[j-1].Time() {
+				elapsed += headers[j].Time() - headers[j-1].Time()
+			}
+		}
+		if elapsed == 0 {
+			elapsed = 1
+		}
+		points = append(points, HashratePoint{
+			Number:   headers[i].NumberU64(),
+			Time:     headers[i].Time(),
+			Hashrate: work.Div(work, new(big.Int).SetUint64(elapsed)),
+		})
+	}
+	return points
+}
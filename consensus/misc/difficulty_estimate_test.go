@@ -0,0 +1,115 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+func TestBlockTimeEstimate(t *testing.T) {
+	tests := []struct {
+		diff, hashrate int64
+		mean           time.Duration
+	}{
+		{diff: 12000000, hashrate: 1000000, mean: 12 * time.Second},
+		{diff: 1000, hashrate: 4000, mean: 250 * time.Millisecond},
+		{diff: 1 << 40, hashrate: 1 << 30, mean: 1024 * time.Second},
+	}
+	for _, tt := range tests {
+		mean, p10, p90 := BlockTimeEstimate(big.NewInt(tt.diff), big.NewInt(tt.hashrate))
+		if mean != tt.mean {
+			t.Errorf("diff %d hashrate %d: mean mismatch: have %v, want %v", tt.diff, tt.hashrate, mean, tt.mean)
+		}
+		if p10 >= mean || p90 <= mean {
+			t.Errorf("diff %d hashrate %d: percentiles %v-%v do not bracket mean %v", tt.diff, tt.hashrate, p10, p90, mean)
+		}
+		// ln(1/0.9) and ln(10) of the mean respectively
+		if want := time.Duration(0.10536 * float64(mean)); (p10-want).Seconds() > 0.001*mean.Seconds() || (want-p10).Seconds() > 0.001*mean.Seconds() {
+			t.Errorf("diff %d hashrate %d: p10 mismatch: have %v, want ~%v", tt.diff, tt.hashrate, p10, want)
+		}
+		if want := time.Duration(2.302585 * float64(mean)); (p90-want).Seconds() > 0.001*mean.Seconds() || (want-p90).Seconds() > 0.001*mean.Seconds() {
+			t.Errorf("diff %d hashrate %d: p90 mismatch: have %v, want ~%v", tt.diff, tt.hashrate, p90, want)
+		}
+	}
+	if mean, p10, p90 := BlockTimeEstimate(big.NewInt(1000), new(big.Int)); mean != 0 || p10 != 0 || p90 != 0 {
+		t.Fatalf("zero hashrate estimate mismatch: have %v %v %v, want zeros", mean, p10, p90)
+	}
+}
+
+func TestInferSolvetime(t *testing.T) {
+	config := testDifficultyConfig()
+	parentDiff := big.NewInt(1000000)
+
+	// Solvetimes are recovered from the difficulties they yield
+	for solvetime := uint64(0); solvetime <= 60; solvetime++ {
+		childDiff := CalcDifficultyFromSolvetime(config, parentDiff, 0, solvetime)
+		inferred, ok := InferSolvetime(config, parentDiff, childDiff)
+		if !ok {
+			t.Fatalf("solvetime %d: inference failed for difficulty %v", solvetime, childDiff)
+		}
+		if inferred+1 < solvetime || inferred > solvetime+1 {
+			t.Errorf("solvetime %d: inferred %d", solvetime, inferred)
+		}
+	}
+	// Increases beyond the zero solvetime step are impossible
+	if _, ok := InferSolvetime(config, parentDiff, big.NewInt(2000000)); ok {
+		t.Fatalf("impossible difficulty increase inferred")
+	}
+	// Capped and clamped steps hide the solvetime
+	capped := *config
+	capped.BoundDivisor = big.NewInt(2048)
+	if _, ok := InferSolvetime(&capped, parentDiff, CalcDifficultyFromSolvetime(&capped, parentDiff, 0, 1)); ok {
+		t.Fatalf("capped step inferred")
+	}
+	if _, ok := InferSolvetime(config, parentDiff, config.MinDifficulty); ok {
+		t.Fatalf("clamped step inferred")
+	}
+}
+
+func TestReconstructHashrate(t *testing.T) {
+	// Craft a chain mined by a known hashrate which quadruples midway, with
+	// difficulties varying from block to block
+	hashrate := func(number int) int64 {
+		if number <= 20 {
+			return 100000
+		}
+		return 400000
+	}
+	headers := []*types.Header{testParent(1200000, 1000)}
+	for i := 1; i <= 40; i++ {
+		diff := int64(1200000 * (1 + i%3))
+		header := testParent(diff, headers[i-1].Time()+uint64(diff/hashrate(i)))
+		header.SetNumber(big.NewInt(int64(i)))
+		headers = append(headers, header)
+	}
+	points := ReconstructHashrate(headers)
+	if len(points) != 40 {
+		t.Fatalf("point count mismatch: have %d, want %d", len(points), 40)
+	}
+	for i, point := range points {
+		number := i + 1
+		if point.Number != uint64(number) || point.Time != headers[number].Time() {
+			t.Fatalf("point %d: block mismatch: have #%d at %d", i, point.Number, point.Time)
+		}
+		// Once the window only spans blocks of one hashrate, it is tracked exactly
+		if number <= 20 || number > 20+hashrateWindow-1 {
+			if want := hashrate(number); point.Hashrate.Int64() != want {
+				t.Errorf("block %d: hashrate mismatch: have %v, want %v", number, point.Hashrate, want)
+			}
+		} else if have := point.Hashrate.Int64(); have <= hashrate(20) || have >= hashrate(21) {
+			t.Errorf("block %d: transition hashrate %v out of range", number, have)
+		}
+	}
+	// Zero solvetimes do not divide by zero
+	burst := []*types.Header{testParent(1000, 1000), testParent(1000, 1000), testParent(1000, 1000)}
+	for i, point := range ReconstructHashrate(burst) {
+		if want := int64(1000 * (i + 1)); point.Hashrate.Int64() != want {
+			t.Errorf("burst point %d: hashrate mismatch: have %v, want %v", i, point.Hashrate, want)
+		}
+	}
+	if points := ReconstructHashrate(headers[:1]); points != nil {
+		t.Fatalf("single header yielded points: %v", points)
+	}
+}
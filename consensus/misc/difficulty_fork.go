@@ -0,0 +1,146 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+var (
+	// ErrForkDifficultyJump is returned if the difficulty changes by more than
+	// the allowed ratio across a fork transition.
+	ErrForkDifficultyJump = errors.New("difficulty discontinuity across fork")
+)
+
+// VerifyForkDifficultyContinuity checks that the difficulty of the first block
+// after a fork, which may change the adjustment algorithm, is within maxRatio
+// of the difficulty of the last block before it, in either direction. This
+// guards against a misconfigured fork rendering the chain unmineable.
+func VerifyForkDifficultyContinuity(preForkTip, postForkFirst *types.Header, maxRatio float64) error {
+	if preForkTip == nil || postForkFirst == nil {
+		return ErrNilParent
+	}
+	if maxRatio < 1 {
+		return fmt.Errorf("invalid maximum difficulty ratio %v, must be at least 1", maxRatio)
+	}
+	if postForkFirst.ParentHash() != preForkTip.Hash() {
+		return fmt.Errorf("post-fork block %x does not extend pre-fork tip %x", postForkFirst.Hash(), preForkTip.Hash())
+	}
+	pre, post := preForkTip.Difficulty(), postForkFirst.Difficulty()
+	if pre.Sign() <= 0 || post.Sign() <= 0 {
+		return fmt.Errorf("%w: non-positive difficulty, pre-fork %v, post-fork %v", ErrForkDifficultyJump, pre, post)
+	}
+	low, high := pre, post
+	if low.Cmp(high) > 0 {
+		low, high = high, low
+	}
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(high), new(big.Float).SetInt(low)).Float64()
+	if ratio > maxRatio {
+		return fmt.Errorf("%w: pre-fork %v, post-fork %v, ratio %.4f exceeds %.4f", ErrForkDifficultyJump, pre, post, ratio, maxRatio)
+	}
+	return nil
+}
+
+// WithAlgorithmRamp returns a DifficultyCalculator smoothing a fork from the
+// algorithm c to next over a window of blocks. Up to startBlock the difficulty
+// is the one of c, from endBlock onwards the one of next, and in between the
+// two are blended linearly by the position of the block in the window, so that
+// the fork introduces no step in the difficulty.
+func (c DifficultyCalculator) WithAlgorithmRamp(next DifficultyCalculator, startBlock, endBlock uint64) DifficultyCalculator {
+	return func(grandparent, parent *types.Header) (*big.Int, error) {
+		number := parent.NumberU64() + 1
+		switch {
+		case number <= startBlock:
+			return c(grandparent, parent)
+		case number >= endBlock:
+			return next(grandparent, parent)
+		}
+		pre, err := c(grandparent, parent)
+		if err != nil {
+			return nil, err
+		}
+		post, err := next(grandparent, parent)
+		if err != nil {
+			return nil, err
+		}
+		// pre*(endBlock-number) + post*(number-startBlock), over the window length
+		blend := new(big.Int).Mul(pre, new(big.Int).SetUint64(endBlock-number))
+		blend.Add(blend, new(big.Int).Mul(post, new(big.Int).SetUint64(number-startBlock)))
+		return blend.Div(blend, new(big.Int).SetUint64(endBlock-startBlock)), nil
+	}
+}
+
+var (
+	// ErrInvalidDifficultyConfig is returned if a difficulty config has
+	// parameters the adjustment cannot operate with.
+	ErrInvalidDifficultyConfig = errors.New("invalid difficulty config")
+
+	// migrationSolvetimes are the solvetimes, in multiples of the old target
+	// block time, at which ValidateParamMigration compares the old and new
+	// parameters: a lucky block, an on target one and a slow one.
+	migrationSolvetimes = []float64{0.25, 1, 4}
+)
+
+// ValidateParamMigration is a pre-flight check of a hard fork replacing the
+// difficulty parameters oldCfg with newCfg from forkBlock on. It verifies that
+// both sets of parameters are sane, and that for a parent like sampleParent,
+// whose number is taken to be the one before forkBlock, the difficulty of the
+// fork block differs by no more than maxRatio between the old and the new
+// parameters, in either direction, whether the parent was solved fast, on
+// target or slowly.
+func ValidateParamMigration(oldCfg, newCfg *DifficultyConfig, forkBlock uint64, sampleParent *types.Header, maxRatio float64) error {
+	if sampleParent == nil {
+		return ErrNilParent
+	}
+	if forkBlock == 0 {
+		return errors.New("difficulty parameters cannot be migrated at genesis")
+	}
+	if maxRatio < 1 {
+		return fmt.Errorf("invalid maximum difficulty ratio %v, must be at least 1", maxRatio)
+	}
+	if err := validateDifficultyConfig(oldCfg); err != nil {
+		return fmt.Errorf("old config: %w", err)
+	}
+	if err := validateDifficultyConfig(newCfg); err != nil {
+		return fmt.Errorf("new config: %w", err)
+	}
+	target := float64(oldCfg.DurationLimit.Uint64())
+	for _, scale := range migrationSolvetimes {
+		solvetime := uint64(scale * target)
+
+		pre := CalcDifficultyFromSolvetime(oldCfg, sampleParent.Difficulty(), forkBlock-1, solvetime)
+		post := CalcDifficultyFromSolvetime(newCfg, sampleParent.Difficulty(), forkBlock-1, solvetime)
+		low, high := pre, post
+		if low.Cmp(high) > 0 {
+			low, high = high, low
+		}
+		ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(high), new(big.Float).SetInt(low)).Float64()
+		if ratio > maxRatio {
+			return fmt.Errorf("%w: block %d after a %ds solvetime, old %v, new %v, ratio %.4f exceeds %.4f", ErrForkDifficultyJump, forkBlock, solvetime, pre, post, ratio, maxRatio)
+		}
+	}
+	return nil
+}
+
+// validateDifficultyConfig checks that the parameters of a difficulty config
+// are operable.
+func validateDifficultyConfig(c *DifficultyConfig) error {
+	switch {
+	case c == nil:
+		return fmt.Errorf("%w: missing config", ErrInvalidDifficultyConfig)
+	case c.DurationLimit == nil || c.DurationLimit.Sign() <= 0:
+		return fmt.Errorf("%w: non-positive duration limit %v", ErrInvalidDifficultyConfig, c.DurationLimit)
+	case c.MinDifficulty == nil || c.MinDifficulty.Sign() <= 0:
+		return fmt.Errorf("%w: non-positive minimum difficulty %v", ErrInvalidDifficultyConfig, c.MinDifficulty)
+	case c.MaxDifficulty != nil && c.MaxDifficulty.Cmp(c.MinDifficulty) < 0:
+		return fmt.Errorf("%w: maximum difficulty %v below minimum %v", ErrInvalidDifficultyConfig, c.MaxDifficulty, c.MinDifficulty)
+	case c.BoundDivisor != nil && c.BoundDivisor.Sign() <= 0:
+		return fmt.Errorf("%w: non-positive bound divisor %v", ErrInvalidDifficultyConfig, c.BoundDivisor)
+	}
+	if err := ValidateContextTargetTimes(c.ContextTargetTimes, c.DurationLimit); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDifficultyConfig, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package misc
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+func TestVerifyForkDifficultyContinuity(t *testing.T) {
+	tests := []struct {
+		pre, post int64
+		ok        bool
+	}{
+		{pre: 1000000, post: 1000000, ok: true},
+		{pre: 1000000, post: 1900000, ok: true},
+		{pre: 1000000, post: 510000, ok: true},
+		{pre: 1000000, post: 2000001, ok: false},
+		{pre: 1000000, post: 400000, ok: false},
+	}
+	for i, tt := range tests {
+		tip := testParent(tt.pre, 1000)
+		first := testParent(tt.post, 1012)
+		first.SetParentHash(tip.Hash())
+
+		err := VerifyForkDifficultyContinuity(tip, first, 2)
+		if tt.ok && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrForkDifficultyJump) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, ErrForkDifficultyJump)
+		}
+	}
+}
+
+func TestVerifyForkDifficultyContinuityInvalid(t *testing.T) {
+	tip := testParent(1000000, 1000)
+	first := testParent(1000000, 1012)
+	if err := VerifyForkDifficultyContinuity(tip, first, 2); err == nil {
+		t.Errorf("unlinked blocks accepted")
+	}
+	first.SetParentHash(tip.Hash())
+	if err := VerifyForkDifficultyContinuity(tip, first, 0.5); err == nil {
+		t.Errorf("ratio below one accepted")
+	}
+	if err := VerifyForkDifficultyContinuity(nil, first, 2); err != ErrNilParent {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNilParent)
+	}
+}
+
+func TestAlgorithmRamp(t *testing.T) {
+	pre := func(grandparent, parent *types.Header) (*big.Int, error) { return big.NewInt(1000000), nil }
+	post := func(grandparent, parent *types.Header) (*big.Int, error) { return big.NewInt(2000000), nil }
+	ramp := DifficultyCalculator(pre).WithAlgorithmRamp(post, 100, 200)
+
+	tests := []struct {
+		number uint64 // number of the computed block, parent+1
+		want   int64
+	}{
+		{number: 50, want: 1000000},
+		{number: 100, want: 1000000}, // ramp start, all old
+		{number: 125, want: 1250000},
+		{number: 150, want: 1500000}, // midpoint, half and half
+		{number: 200, want: 2000000}, // ramp end, all new
+		{number: 300, want: 2000000},
+	}
+	for _, tt := range tests {
+		parent := testParent(1000000, 1000)
+		parent.SetNumber(new(big.Int).SetUint64(tt.number - 1))
+		have, err := ramp(testParent(1000000, 988), parent)
+		if err != nil {
+			t.Fatalf("block %d: ramp failed: %v", tt.number, err)
+		}
+		if have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %v", tt.number, have, tt.want)
+		}
+	}
+}
+
+func TestValidateParamMigration(t *testing.T) {
+	oldCfg := testDifficultyConfig()
+	parent := testParent(1000000, 1000)
+
+	// Retuning the adjustment moderately is safe
+	safe := *oldCfg
+	safe.DurationLimit = big.NewInt(13)
+	safe.BoundDivisor = big.NewInt(2048)
+	if err := ValidateParamMigration(oldCfg, &safe, 5000, parent, 2); err != nil {
+		t.Fatalf("safe migration rejected: %v", err)
+	}
+	// Raising the minimum far above the chain's difficulty is a discontinuity
+	jump := *oldCfg
+	jump.MinDifficulty = big.NewInt(5000000)
+	err := ValidateParamMigration(oldCfg, &jump, 5000, parent, 2)
+	if !errors.Is(err, ErrForkDifficultyJump) {
+		t.Fatalf("discontinuous migration error mismatch: have %v, want %v", err, ErrForkDifficultyJump)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "block 5000") || !strings.Contains(msg, "new 5000000") {
+		t.Fatalf("discontinuity error lacks detail: %v", err)
+	}
+	// Insane parameters are rejected upfront
+	tests := map[string]func(c *DifficultyConfig){
+		"zero target":       func(c *DifficultyConfig) { c.DurationLimit = new(big.Int) },
+		"nil minimum":       func(c *DifficultyConfig) { c.MinDifficulty = nil },
+		"maximum below":     func(c *DifficultyConfig) { c.MaxDifficulty = big.NewInt(999) },
+		"negative divisor":  func(c *DifficultyConfig) { c.BoundDivisor = big.NewInt(-1) },
+		"inverted contexts": func(c *DifficultyConfig) { c.ContextTargetTimes = [3]uint64{1, 2, 3} },
+	}
+	for name, mutate := range tests {
+		cpy := *oldCfg
+		mutate(&cpy)
+		if err := ValidateParamMigration(oldCfg, &cpy, 5000, parent, 2); !errors.Is(err, ErrInvalidDifficultyConfig) {
+			t.Errorf("%s: error mismatch: have %v, want %v", name, err, ErrInvalidDifficultyConfig)
+		}
+	}
+	// Partial or missing old parameters are rejected too, rather than used
+	partial := *oldCfg
+	partial.DurationLimit = nil
+	for name, cfg := range map[string]*DifficultyConfig{"partial": &partial, "missing": nil} {
+		if err := ValidateParamMigration(cfg, &safe, 5000, parent, 2); !errors.Is(err, ErrInvalidDifficultyConfig) {
+			t.Errorf("%s old config: error mismatch: have %v, want %v", name, err, ErrInvalidDifficultyConfig)
+		}
+	}
+	if err := ValidateParamMigration(oldCfg, nil, 5000, parent, 2); !errors.Is(err, ErrInvalidDifficultyConfig) {
+		t.Errorf("missing new config: error mismatch: have %v, want %v", err, ErrInvalidDifficultyConfig)
+	}
+	// The allowed change is up to the caller
+	if err := ValidateParamMigration(oldCfg, &jump, 5000, parent, 10); err != nil {
+		t.Errorf("migration within a loose bound rejected: %v", err)
+	}
+	if err := ValidateParamMigration(oldCfg, &safe, 5000, parent, 0.5); err == nil {
+		t.Errorf("ratio below one accepted")
+	}
+	if err := ValidateParamMigration(oldCfg, &safe, 5000, nil, 2); err != ErrNilParent {
+		t.Fatalf("missing parent error mismatch: have %v, want %v", err, ErrNilParent)
+	}
+}
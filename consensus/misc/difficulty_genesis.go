@@ -0,0 +1,37 @@
+package misc
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+// GenesisDifficulty returns the difficulty of the genesis block, which is not
+// derived by the adjustment algorithm but configured in the chain config. It is
+// raised to the protocol's minimum difficulty if need be, which is also used if
+// none is configured. It only applies to genesis specs setting no difficulty of
+// their own, which are used as is.
+func GenesisDifficulty(config *params.ChainConfig) *big.Int {
+	if config == nil || config.GenesisDifficulty == nil || config.GenesisDifficulty.Cmp(params.MinimumDifficulty) < 0 {
+		return new(big.Int).Set(params.MinimumDifficulty)
+	}
+	return new(big.Int).Set(config.GenesisDifficulty)
+}
+
+// GenesisDifficultyForTarget returns the genesis difficulty under which a
+// network of the given hashrate, in hashes per second, takes targetBlockTime
+// on average to mine the first block, the inverse of BlockTimeEstimate. It is
+// raised to the protocol's minimum difficulty if need be, which is also
+// returned for a non-positive hashrate.
+func GenesisDifficultyForTarget(hashrate *big.Int, targetBlockTime time.Duration) *big.Int {
+	if hashrate == nil || hashrate.Sign() <= 0 || targetBlockTime <= 0 {
+		return new(big.Int).Set(params.MinimumDifficulty)
+	}
+	diff := new(big.Int).Mul(hashrate, big.NewInt(int64(targetBlockTime)))
+	diff.Div(diff, big.NewInt(int64(time.Second)))
+	if diff.Cmp(params.MinimumDifficulty) < 0 {
+		return diff.Set(params.MinimumDifficulty)
+	}
+	return diff
+}
@@ -0,0 +1,58 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+func TestGenesisDifficulty(t *testing.T) {
+	tests := []struct {
+		config *params.ChainConfig
+		want   *big.Int
+	}{
+		{config: &params.ChainConfig{GenesisDifficulty: big.NewInt(4000000)}, want: big.NewInt(4000000)},
+		{config: &params.ChainConfig{GenesisDifficulty: big.NewInt(1)}, want: params.MinimumDifficulty},
+		{config: &params.ChainConfig{}, want: params.MinimumDifficulty},
+		{config: nil, want: params.MinimumDifficulty},
+	}
+	for i, tt := range tests {
+		have := GenesisDifficulty(tt.config)
+		if have.Cmp(tt.want) != 0 {
+			t.Errorf("test %d: genesis difficulty mismatch: have %v, want %v", i, have, tt.want)
+		}
+		if have == params.MinimumDifficulty || (tt.config != nil && have == tt.config.GenesisDifficulty) {
+			t.Errorf("test %d: genesis difficulty aliases the config", i)
+		}
+	}
+}
+
+func TestGenesisDifficultyForTarget(t *testing.T) {
+	tests := []struct {
+		hashrate int64
+		target   time.Duration
+		want     int64
+	}{
+		{hashrate: 1000000, target: 12 * time.Second, want: 12000000},
+		{hashrate: 4000, target: 250 * time.Millisecond, want: 1000},
+		{hashrate: 3000000, target: 1500 * time.Millisecond, want: 4500000},
+	}
+	for i, tt := range tests {
+		have := GenesisDifficultyForTarget(big.NewInt(tt.hashrate), tt.target)
+		if have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Fatalf("test %d: genesis difficulty mismatch: have %v, want %v", i, have, tt.want)
+		}
+		// The difficulty reproduces the target block time
+		if mean, _, _ := BlockTimeEstimate(have, big.NewInt(tt.hashrate)); mean != tt.target {
+			t.Errorf("test %d: block time mismatch: have %v, want %v", i, mean, tt.target)
+		}
+	}
+	// Difficulties too low for the protocol are raised to its minimum
+	for _, hashrate := range []*big.Int{big.NewInt(10), big.NewInt(0), nil} {
+		if have := GenesisDifficultyForTarget(hashrate, time.Second); have.Cmp(params.MinimumDifficulty) != 0 || have == params.MinimumDifficulty {
+			t.Errorf("hashrate %v: genesis difficulty mismatch: have %v, want a copy of %v", hashrate, have, params.MinimumDifficulty)
+		}
+	}
+}
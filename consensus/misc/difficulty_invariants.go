@@ -0,0 +1,51 @@
+package misc
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+var (
+	// maxDifficulty is the largest difficulty representable in 256 bits.
+	maxDifficulty = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+	// plausibleDifficultyRatio bounds the factor by which a difficulty may
+	// plausibly differ from its parent's, checked in debug builds only.
+	plausibleDifficultyRatio = big.NewInt(64)
+)
+
+// assertDifficultyInvariants checks the cheap invariants every computed
+// difficulty must hold before reaching consensus: at least minDiff, and fitting
+// in 256 bits. Debug builds additionally check that, bar the bomb term, it is
+// within a plausible ratio of parentDiff, unless nil, and panic on any
+// violation, as it points to a formula bug. Other builds log the violation and
+// return the difficulty clamped to the violated bound.
+func assertDifficultyInvariants(diff, minDiff, parentDiff, bombTerm *big.Int) *big.Int {
+	var violation string
+	switch {
+	case diff.Cmp(minDiff) < 0:
+		violation = fmt.Sprintf("difficulty %v below minimum %v", diff, minDiff)
+		diff = new(big.Int).Set(minDiff)
+	case diff.Cmp(maxDifficulty) > 0:
+		violation = fmt.Sprintf("difficulty of %d bits exceeds 256 bits", diff.BitLen())
+		diff = new(big.Int).Set(maxDifficulty)
+	case debugDifficulty && parentDiff != nil && parentDiff.Sign() > 0:
+		step := diff
+		if bombTerm != nil {
+			step = new(big.Int).Sub(diff, bombTerm)
+		}
+		if new(big.Int).Mul(parentDiff, plausibleDifficultyRatio).Cmp(step) < 0 ||
+			new(big.Int).Mul(step, plausibleDifficultyRatio).Cmp(parentDiff) < 0 {
+			violation = fmt.Sprintf("difficulty %v implausible for parent difficulty %v", diff, parentDiff)
+		}
+	}
+	if violation != "" {
+		if debugDifficulty {
+			panic("difficulty invariant violated: " + violation)
+		}
+		log.Error("Difficulty invariant violated, clamping", "violation", violation)
+	}
+	return diff
+}
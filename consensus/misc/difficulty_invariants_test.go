@@ -0,0 +1,69 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+)
+
+// withDebugDifficulty runs fn as in a debug build or not, returning whether it
+// panicked.
+func withDebugDifficulty(debug bool, fn func()) (panicked bool) {
+	defer func(restore bool) {
+		debugDifficulty = restore
+		panicked = recover() != nil
+	}(debugDifficulty)
+	debugDifficulty = debug
+	fn()
+	return false
+}
+
+func TestDifficultyInvariants(t *testing.T) {
+	minDiff := big.NewInt(1000)
+	tests := []struct {
+		diff, parent *big.Int
+		want         *big.Int // nil if only implausible
+	}{
+		{big.NewInt(999), big.NewInt(1000), minDiff},
+		{new(big.Int).Lsh(big.NewInt(1), 256), nil, maxDifficulty},
+		{big.NewInt(1000000), big.NewInt(1000), nil},
+	}
+	for i, tt := range tests {
+		// Production builds clamp to the violated bound
+		if tt.want != nil {
+			var have *big.Int
+			if withDebugDifficulty(false, func() { have = assertDifficultyInvariants(tt.diff, minDiff, tt.parent, nil) }) {
+				t.Errorf("test %d: violated invariant panicked in production builds", i)
+			} else if have.Cmp(tt.want) != 0 {
+				t.Errorf("test %d: clamped difficulty mismatch: have %v, want %v", i, have, tt.want)
+			}
+		}
+		// Debug builds panic
+		if !withDebugDifficulty(true, func() { assertDifficultyInvariants(tt.diff, minDiff, tt.parent, nil) }) {
+			t.Errorf("test %d: violated invariant did not panic in debug builds", i)
+		}
+	}
+	if withDebugDifficulty(true, func() { assertDifficultyInvariants(big.NewInt(1100), minDiff, big.NewInt(1000), nil) }) {
+		t.Fatalf("valid difficulty panicked")
+	}
+}
+
+func TestDifficultyInvariantsFaultyStep(t *testing.T) {
+	// An oracle injecting an overflowing difficulty is clamped to 256 bits
+	overflow := new(big.Int).Lsh(big.NewInt(1), 300)
+	config := testDifficultyConfig().WithDifficultyOracle(func(number uint64) (*big.Int, bool) {
+		return overflow, true
+	})
+	var have *big.Int
+	withDebugDifficulty(false, func() { have = CalcDifficulty(config, 1000, testParent(1000000, 1012)) })
+	if have == nil || have.Cmp(maxDifficulty) != 0 {
+		t.Fatalf("overflowing difficulty not clamped: have %v", have)
+	}
+	// An emergency step dropping the difficulty a thousandfold is implausible
+	config = testDifficultyConfig().WithEmergencyAdjust(1000, 2)
+	if withDebugDifficulty(false, func() { CalcDifficulty(config, 1000, testParent(100000000, 1100)) }) {
+		t.Fatalf("implausible step panicked in production builds")
+	}
+	if !withDebugDifficulty(true, func() { CalcDifficulty(config, 1000, testParent(100000000, 1100)) }) {
+		t.Fatalf("implausible step did not panic in debug builds")
+	}
+}
@@ -0,0 +1,27 @@
+package misc
+
+import (
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/sirupsen/logrus"
+)
+
+// LogDifficultyContext logs the difficulty a block was prepared with, along
+// with its target and the network hashrate it implies, so operators can sanity
+// check the adjustment when preparing a block. The difficulty is logged as
+// computed by the caller, config only supplying the target block time the
+// hashrate is derived from. Nothing is computed unless the logger is at debug
+// level.
+func LogDifficultyContext(logger *log.Logger, config *DifficultyConfig, number uint64, difficulty *big.Int) {
+	if logger == nil || difficulty == nil || !logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+	// A block is expected every DurationLimit seconds, so the network has to
+	// perform difficulty hashes in that time
+	hashrate := new(big.Int).Div(difficulty, config.DurationLimit)
+
+	logger.Debug("Next block difficulty", "number", number, "difficulty", difficulty,
+		"target", consensus.DifficultyToTarget(difficulty), "hashrate", hashrate)
+}
@@ -0,0 +1,41 @@
+package misc
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogDifficultyContext(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.Logger{Logger: logrus.New()}
+	logger.SetOutput(&out)
+
+	config := testDifficultyConfig()
+	config.Counters = new(DifficultyCounters)
+	difficulty := big.NewInt(1001099)
+
+	// Nothing is logged above debug level
+	logger.SetLevel(logrus.InfoLevel)
+	LogDifficultyContext(&logger, config, 1, difficulty)
+	if out.Len() != 0 {
+		t.Fatalf("logged at info level: %q", out.String())
+	}
+	// All the fields are logged at debug level
+	logger.SetLevel(logrus.DebugLevel)
+	LogDifficultyContext(&logger, config, 1, difficulty)
+	line := out.String()
+	for _, field := range []string{"number=1", "difficulty=1001099", "target=", "hashrate=83424"} {
+		if !strings.Contains(line, field) {
+			t.Errorf("field %q missing from log line %q", field, line)
+		}
+	}
+	// Logging does not rerun the adjustment, so the metrics are left untouched
+	if metrics := config.Metrics(); metrics != (DifficultyMetrics{}) {
+		t.Errorf("metrics changed by logging: %+v", metrics)
+	}
+}
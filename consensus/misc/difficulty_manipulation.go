@@ -0,0 +1,48 @@
+package misc
+
+import "github.com/dominant-strategies/go-quai/core/types"
+
+// manipulationWindow is the number of consecutive solvetimes which must
+// oscillate for DetectTimestampManipulation to flag a block.
+const manipulationWindow = 6
+
+// DetectTimestampManipulation scans a chain segment, ordered from oldest to
+// newest, for the timestamp oscillation a miner may use to hold difficulty
+// down: solvetimes alternating between far below and far above the target.
+// A block is flagged once it ends a run of six solvetimes which alternate
+// around the DurationLimit, each missing it by at least half. Honest chains
+// have exponentially distributed solvetimes, which hardly ever line up like
+// that, so the flags are meant for forensics rather than consensus. Returns
+// the numbers of the flagged blocks, in chain order.
+func DetectTimestampManipulation(headers []*types.Header, config *DifficultyConfig) []uint64 {
+	var (
+		target  = int64(config.DurationLimit.Uint64())
+		flagged []uint64
+		run     int   // Number of consecutive alternating, far off solvetimes
+		prev    int64 // Deviation of the previous solvetime from the target
+	)
+	for i := 1; i < len(headers); i++ {
+		deviation := int64(headers[i].Time()) - int64(headers[i-1].Time()) - target
+		switch {
+		case 2*abs64(deviation) < target:
+			run = 0
+		case run > 0 && (deviation < 0) == (prev < 0):
+			run = 1
+		default:
+			run++
+		}
+		prev = deviation
+		if run >= manipulationWindow {
+			flagged = append(flagged, headers[i].NumberU64())
+		}
+	}
+	return flagged
+}
+
+// abs64 returns the absolute value of x.
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
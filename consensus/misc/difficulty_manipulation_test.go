@@ -0,0 +1,27 @@
+package misc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectTimestampManipulation(t *testing.T) {
+	config := testDifficultyConfig()
+
+	// A chain of naturally noisy solvetimes raises no flags
+	clean := testChain(config, []uint64{10, 3, 25, 12, 1, 40, 7, 14, 2, 19, 11, 30, 5, 12})
+	if flagged := DetectTimestampManipulation(clean, config); len(flagged) != 0 {
+		t.Fatalf("clean chain flagged: %v", flagged)
+	}
+	// Oscillating solvetimes are flagged from the sixth one of the run on,
+	// until a solvetime near the target breaks the pattern
+	oscillating := testChain(config, []uint64{12, 1, 30, 1, 30, 1, 30, 1, 30, 12, 1, 30})
+	if have, want := DetectTimestampManipulation(oscillating, config), []uint64{7, 8, 9}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("oscillating chain flags mismatch: have %v, want %v", have, want)
+	}
+	// Far off solvetimes in the same direction do not alternate
+	slow := testChain(config, []uint64{1, 30, 30, 1, 30, 1, 30, 1, 1})
+	if have, want := DetectTimestampManipulation(slow, config), []uint64{8}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("broken oscillation flags mismatch: have %v, want %v", have, want)
+	}
+}
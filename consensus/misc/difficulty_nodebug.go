@@ -0,0 +1,8 @@
+//go:build !debug
+// +build !debug
+
+package misc
+
+// debugDifficulty makes violated difficulty invariants panic, see
+// assertDifficultyInvariants.
+var debugDifficulty = false
@@ -0,0 +1,77 @@
+package misc
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// ReplayChainDifficulty re-derives the difficulty series of a contiguous chain
+// segment, ordered from oldest to newest. Each header's difficulty is computed
+// from its parent and grandparent, so the first two headers only anchor the
+// replay and their recorded difficulties are returned as is. As when preparing
+// a block, the grandchild of the genesis inherits the difficulty of its parent.
+//
+// The returned series is aligned with the headers. Any header whose recorded
+// difficulty differs from the computed one, or which does not link to its
+// predecessor, is reported in the returned errors.
+func ReplayChainDifficulty(headers []*types.Header, config *DifficultyConfig) ([]*big.Int, []error) {
+	var (
+		series = make([]*big.Int, len(headers))
+		errs   []error
+	)
+	for i, header := range headers {
+		if i > 0 && header.ParentHash() != headers[i-1].Hash() {
+			errs = append(errs, fmt.Errorf("header %d (#%d) does not link to its predecessor: parent %x, want %x",
+				i, header.NumberU64(), header.ParentHash(), headers[i-1].Hash()))
+		}
+		if i < 2 {
+			series[i] = new(big.Int).Set(header.Difficulty())
+			continue
+		}
+		series[i] = chainDifficulty(config, headers[i-2], headers[i-1])
+		if series[i].Cmp(header.Difficulty()) != 0 {
+			errs = append(errs, fmt.Errorf("header %d (#%d) difficulty mismatch: have %v, want %v",
+				i, header.NumberU64(), header.Difficulty(), series[i]))
+		}
+	}
+	return series, errs
+}
+
+// AuditReport summarizes the difficulty consistency of an imported chain.
+type AuditReport struct {
+	Checked    int // Number of headers whose difficulty was recomputed
+	Mismatches int // Number of headers whose recorded difficulty is wrong
+
+	TotalDiscrepancy *big.Int      // Sum of the absolute recorded minus computed differences
+	FirstMismatch    *types.Header // Oldest header with a wrong difficulty, nil if none
+}
+
+// Consistent returns whether every recomputed difficulty matched its header.
+func (r *AuditReport) Consistent() bool {
+	return r.Mismatches == 0
+}
+
+// AuditImportedChain recomputes the difficulty of every header of a chain
+// segment imported from elsewhere, ordered from oldest to newest, from its
+// recorded ancestors, and summarizes the discrepancies. As the chain is audited
+// as recorded, a fabricated difficulty usually also flags the descendant
+// computed from it. The first two headers only anchor the audit, and the
+// grandchild of the genesis is expected to inherit the difficulty of its parent.
+func AuditImportedChain(headers []*types.Header, config *DifficultyConfig) AuditReport {
+	report := AuditReport{TotalDiscrepancy: new(big.Int)}
+	for i := 2; i < len(headers); i++ {
+		expected := chainDifficulty(config, headers[i-2], headers[i-1])
+		report.Checked++
+
+		if diff := new(big.Int).Sub(headers[i].Difficulty(), expected); diff.Sign() != 0 {
+			report.Mismatches++
+			report.TotalDiscrepancy.Add(report.TotalDiscrepancy, diff.Abs(diff))
+			if report.FirstMismatch == nil {
+				report.FirstMismatch = headers[i]
+			}
+		}
+	}
+	return report
+}
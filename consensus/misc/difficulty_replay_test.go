@@ -0,0 +1,112 @@
+package misc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// testChain generates a chain of headers rooted at a genesis with the given
+// solvetimes, each carrying the difficulty the engines prepare it with.
+func testChain(config *DifficultyConfig, solvetimes []uint64) []*types.Header {
+	headers := []*types.Header{testParent(1000000, 1000)}
+	genesis := headers[0].Hash()
+	for i, solvetime := range solvetimes {
+		parent := headers[len(headers)-1]
+		header := testParent(0, parent.Time()+solvetime)
+		header.SetParentHash(parent.Hash())
+		header.SetNumber(big.NewInt(int64(i + 1)))
+
+		var grandparent *types.Header
+		if i > 0 {
+			grandparent = headers[len(headers)-2]
+		}
+		header.SetDifficulty(PrepareDifficulty(config, parent, grandparent, genesis))
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+func TestReplayChainDifficulty(t *testing.T) {
+	config := testDifficultyConfig()
+	headers := testChain(config, []uint64{10, 3, 25, 12, 1, 40, 7})
+
+	series, errs := ReplayChainDifficulty(headers, config)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected mismatches in consistent chain: %v", errs)
+	}
+	if len(series) != len(headers) {
+		t.Fatalf("series length mismatch: have %d, want %d", len(series), len(headers))
+	}
+	for i, header := range headers {
+		if series[i].Cmp(header.Difficulty()) != 0 {
+			t.Errorf("header %d: difficulty mismatch: have %v, want %v", i, series[i], header.Difficulty())
+		}
+	}
+}
+
+func TestReplayChainDifficultyMismatch(t *testing.T) {
+	config := testDifficultyConfig()
+	headers := testChain(config, []uint64{10, 3, 25, 12, 1, 40, 7})
+
+	// Inject a discrepancy on the tip, so the linkage remains intact
+	tip := headers[len(headers)-1]
+	want := new(big.Int).Set(tip.Difficulty())
+	tip.SetDifficulty(new(big.Int).Add(want, big.NewInt(1)))
+
+	series, errs := ReplayChainDifficulty(headers, config)
+	if len(errs) != 1 {
+		t.Fatalf("mismatch count: have %d, want 1: %v", len(errs), errs)
+	}
+	if series[len(series)-1].Cmp(want) != 0 {
+		t.Fatalf("replayed difficulty mismatch: have %v, want %v", series[len(series)-1], want)
+	}
+	// Tampering a header in the middle also breaks the link to its child
+	headers[3].SetDifficulty(big.NewInt(5000))
+	if _, errs := ReplayChainDifficulty(headers, config); len(errs) < 2 {
+		t.Fatalf("expected mismatch and linkage errors, have %v", errs)
+	}
+}
+
+func TestAuditImportedChain(t *testing.T) {
+	config := testDifficultyConfig()
+	headers := testChain(config, []uint64{10, 3, 25, 12, 1, 40, 7})
+
+	report := AuditImportedChain(headers, config)
+	if !report.Consistent() || report.Checked != len(headers)-2 || report.TotalDiscrepancy.Sign() != 0 || report.FirstMismatch != nil {
+		t.Fatalf("clean import audit mismatch: have %+v", report)
+	}
+	// Fabricate a difficulty in the middle of the chain
+	fake := headers[4]
+	fake.SetDifficulty(new(big.Int).Add(fake.Difficulty(), big.NewInt(500)))
+
+	report = AuditImportedChain(headers, config)
+	if report.Consistent() || report.FirstMismatch != fake {
+		t.Fatalf("first mismatch not the fabricated header: have %+v", report)
+	}
+	if report.TotalDiscrepancy.Cmp(big.NewInt(500)) <= 0 {
+		t.Fatalf("discrepancy mismatch: have %v, want above %v", report.TotalDiscrepancy, 500)
+	}
+}
+
+func TestReplayChainDifficultyGenesis(t *testing.T) {
+	config := testDifficultyConfig()
+	headers := testChain(config, []uint64{10, 3, 25})
+
+	// The grandchild of the genesis inherits the difficulty of its parent
+	if headers[2].Difficulty().Cmp(headers[1].Difficulty()) != 0 {
+		t.Fatalf("genesis grandchild difficulty mismatch: have %v, want %v", headers[2].Difficulty(), headers[1].Difficulty())
+	}
+	if _, errs := ReplayChainDifficulty(headers, config); len(errs) != 0 {
+		t.Fatalf("unexpected mismatches in genesis rooted chain: %v", errs)
+	}
+	if report := AuditImportedChain(headers, config); !report.Consistent() {
+		t.Fatalf("genesis rooted chain audit mismatch: have %+v", report)
+	}
+	// Adjusting the grandchild of the genesis diverges from the engines
+	headers[2].SetDifficulty(CalcDifficulty(config, headers[0].Time(), headers[1]))
+	if report := AuditImportedChain(headers, config); report.FirstMismatch != headers[2] {
+		t.Fatalf("adjusted genesis grandchild not flagged: have %+v", report)
+	}
+}
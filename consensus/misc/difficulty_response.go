@@ -0,0 +1,57 @@
+package misc
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// RetargetResponse returns the difficulty trajectory of a chain starting at
+// startDiff whose blocks take the given solvetimes to be mined, for charting how
+// the adjustment responds to hypothetical hashrate changes. The trajectory
+// starts with startDiff, followed by the difficulty after each solvetime.
+func RetargetResponse(startDiff *big.Int, solvetimes []uint64, config *DifficultyConfig) []*big.Int {
+	trajectory := make([]*big.Int, 0, len(solvetimes)+1)
+	trajectory = append(trajectory, new(big.Int).Set(startDiff))
+	for i, solvetime := range solvetimes {
+		trajectory = append(trajectory, CalcDifficultyFromSolvetime(config, trajectory[i], uint64(i), solvetime))
+	}
+	return trajectory
+}
+
+// ProjectDifficulty projects the difficulty of the nBlocks blocks following
+// parent, assuming every one of them is mined exactly on target. The projection
+// isolates the drift of the difficulty bomb, which keeps ramping difficulty up
+// even at perfect spacing.
+func ProjectDifficulty(config *DifficultyConfig, parent *types.Header, nBlocks int) []*big.Int {
+	if nBlocks <= 0 {
+		return nil
+	}
+	var (
+		projection = make([]*big.Int, 0, nBlocks)
+		difficulty = parent.Difficulty()
+		solvetime  = config.DurationLimit.Uint64()
+	)
+	for i := 0; i < nBlocks; i++ {
+		difficulty = CalcDifficultyFromSolvetime(config, difficulty, parent.NumberU64()+uint64(i), solvetime)
+		projection = append(projection, difficulty)
+	}
+	return projection
+}
+
+// RetargetError returns the root mean square deviation of the block spacing of
+// a chain segment, ordered from oldest to newest, from targetBlockTime, as a
+// single quality score of a difficulty algorithm: the lower, the steadier the
+// chain. Segments of fewer than two headers score zero.
+func RetargetError(headers []*types.Header, targetBlockTime uint64) float64 {
+	if len(headers) < 2 {
+		return 0
+	}
+	var sum float64
+	for i := 1; i < len(headers); i++ {
+		deviation := float64(headers[i].Time()) - float64(headers[i-1].Time()) - float64(targetBlockTime)
+		sum += deviation * deviation
+	}
+	return math.Sqrt(sum / float64(len(headers)-1))
+}
@@ -0,0 +1,92 @@
+package misc
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestRetargetResponse(t *testing.T) {
+	// On target blocks, then a hashrate jump halving the solvetime for a while,
+	// until the difficulty caught up and blocks are back on target
+	var solvetimes []uint64
+	for i := 0; i < 30; i++ {
+		switch {
+		case i < 10:
+			solvetimes = append(solvetimes, 12)
+		case i < 20:
+			solvetimes = append(solvetimes, 6)
+		default:
+			solvetimes = append(solvetimes, 12)
+		}
+	}
+	start := big.NewInt(1000000)
+	trajectory := RetargetResponse(start, solvetimes, testDifficultyConfig())
+	if len(trajectory) != len(solvetimes)+1 {
+		t.Fatalf("trajectory length mismatch: have %d, want %d", len(trajectory), len(solvetimes)+1)
+	}
+	if trajectory[0].Cmp(start) != 0 || trajectory[0] == start {
+		t.Fatalf("trajectory does not start with a copy of the start difficulty")
+	}
+	for i := 1; i < len(trajectory); i++ {
+		switch cmp := trajectory[i].Cmp(trajectory[i-1]); {
+		case i <= 10 && cmp != 0:
+			t.Errorf("block %d: difficulty moved on target: %v -> %v", i, trajectory[i-1], trajectory[i])
+		case i > 10 && i <= 20 && cmp <= 0:
+			t.Errorf("block %d: difficulty did not rise: %v -> %v", i, trajectory[i-1], trajectory[i])
+		case i > 20 && cmp != 0:
+			t.Errorf("block %d: difficulty did not stabilize: %v -> %v", i, trajectory[i-1], trajectory[i])
+		}
+	}
+}
+
+func TestProjectDifficulty(t *testing.T) {
+	config := testDifficultyConfig()
+	config.Bomb = &DifficultyBomb{Period: 100}
+
+	// Early on the bomb term is negligible, so on target blocks keep the
+	// difficulty flat
+	parent := testParent(1000000, 1000)
+	parent.SetNumber(big.NewInt(10))
+	projection := ProjectDifficulty(config, parent, 50)
+	if len(projection) != 50 {
+		t.Fatalf("projection length mismatch: have %d, want 50", len(projection))
+	}
+	for i, difficulty := range projection {
+		if difficulty.Cmp(parent.Difficulty()) != 0 {
+			t.Errorf("early block %d: difficulty moved: have %v, want %v", i+11, difficulty, parent.Difficulty())
+		}
+	}
+	// Late in the chain the bomb dominates, ramping difficulty at every block
+	parent.SetNumber(big.NewInt(3000))
+	projection = ProjectDifficulty(config, parent, 50)
+	prev := parent.Difficulty()
+	for i, difficulty := range projection {
+		if difficulty.Cmp(prev) <= 0 {
+			t.Errorf("late block %d: difficulty did not rise: %v -> %v", i+3001, prev, difficulty)
+		}
+		prev = difficulty
+	}
+	// Each of the blocks adds a bomb term of at least 2^28
+	if growth := new(big.Int).Sub(prev, parent.Difficulty()); growth.Cmp(big.NewInt(50<<28)) < 0 {
+		t.Errorf("late projection growth mismatch: have %v, want at least %v", growth, 50<<28)
+	}
+}
+
+func TestRetargetError(t *testing.T) {
+	config := testDifficultyConfig()
+
+	// A perfectly spaced chain has no error
+	steady := testChain(config, []uint64{12, 12, 12, 12, 12})
+	if have := RetargetError(steady, 12); have != 0 {
+		t.Fatalf("steady chain error mismatch: have %v, want 0", have)
+	}
+	// A noisy chain scores the RMS deviation, sqrt((36+36+64+64)/4)
+	noisy := testChain(config, []uint64{6, 18, 4, 20})
+	if have, want := RetargetError(noisy, 12), math.Sqrt(50); math.Abs(have-want) > 1e-9 {
+		t.Fatalf("noisy chain error mismatch: have %v, want %v", have, want)
+	}
+	if have := RetargetError(noisy[:1], 12); have != 0 {
+		t.Fatalf("single header error mismatch: have %v, want 0", have)
+	}
+}
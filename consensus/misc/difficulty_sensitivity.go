@@ -0,0 +1,87 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// ErrUnknownDifficultyParam is returned when asked to perturb a difficulty
+// parameter the sensitivity analysis does not support.
+var ErrUnknownDifficultyParam = errors.New("unknown difficulty parameter")
+
+// SensitivityAnalysis quantifies the impact of a difficulty parameter over a
+// chain segment, ordered from oldest to newest. For each delta, the parameter
+// named by its json key, one of durationLimit, minDifficulty, boundDivisor or
+// minSolvetime, is scaled by 1+delta and the perturbed algorithm is compared to
+// the base one with CompareAlgorithms. The returned metric of each delta is the
+// summed absolute divergence relative to the summed base difficulties, so zero
+// means no impact at all. A parameter unset in base cannot be perturbed.
+func SensitivityAnalysis(base *DifficultyConfig, param string, deltas []float64, headers []*types.Header) (map[float64]float64, error) {
+	// Sum the base difficulties the divergences are relative to
+	total := new(big.Int)
+	for i := 2; i < len(headers); i++ {
+		if expected, err := base.Calculator()(headers[i-2], headers[i-1]); err == nil {
+			total.Add(total, expected)
+		}
+	}
+	divergences := make(map[float64]float64, len(deltas))
+	for _, delta := range deltas {
+		perturbed, err := perturb(base, param, delta)
+		if err != nil {
+			return nil, err
+		}
+		if total.Sign() == 0 {
+			divergences[delta] = 0
+			continue
+		}
+		report := CompareAlgorithms(base.Calculator(), perturbed.Calculator(), headers)
+
+		divergence := new(big.Int)
+		for _, difference := range report.Differences {
+			if difference != nil {
+				divergence.Add(divergence, new(big.Int).Abs(difference))
+			}
+		}
+		divergences[delta], _ = new(big.Float).Quo(new(big.Float).SetInt(divergence), new(big.Float).SetInt(total)).Float64()
+	}
+	return divergences, nil
+}
+
+// perturb returns a copy of the config with the named parameter scaled by
+// 1+delta, rounded down to an integer.
+func perturb(base *DifficultyConfig, param string, delta float64) (*DifficultyConfig, error) {
+	cpy := *base
+	scale := func(value *big.Int) (*big.Int, error) {
+		if value == nil {
+			return nil, fmt.Errorf("%w: %s is not set", ErrUnknownDifficultyParam, param)
+		}
+		scaled, _ := new(big.Float).Mul(new(big.Float).SetInt(value), big.NewFloat(1+delta)).Int(nil)
+		if scaled.Sign() <= 0 {
+			return nil, fmt.Errorf("delta %v makes %s non-positive", delta, param)
+		}
+		return scaled, nil
+	}
+	var err error
+	switch param {
+	case "durationLimit":
+		cpy.DurationLimit, err = scale(base.DurationLimit)
+	case "minDifficulty":
+		cpy.MinDifficulty, err = scale(base.MinDifficulty)
+	case "boundDivisor":
+		cpy.BoundDivisor, err = scale(base.BoundDivisor)
+	case "minSolvetime":
+		var scaled *big.Int
+		if scaled, err = scale(new(big.Int).SetUint64(base.MinSolvetime)); err == nil {
+			cpy.MinSolvetime = scaled.Uint64()
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDifficultyParam, param)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cpy, nil
+}
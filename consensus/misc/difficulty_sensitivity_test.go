@@ -0,0 +1,31 @@
+package misc
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestSensitivityAnalysis(t *testing.T) {
+	config := testDifficultyConfig()
+	config.BoundDivisor = big.NewInt(2048)
+	headers := testChain(config, []uint64{1, 1, 40, 1, 30, 2, 1, 50, 1})
+
+	deltas := []float64{0, 0.1, 0.5, 1}
+	divergences, err := SensitivityAnalysis(config, "boundDivisor", deltas, headers)
+	if err != nil {
+		t.Fatalf("analysis failed: %v", err)
+	}
+	if divergences[0] != 0 {
+		t.Fatalf("unperturbed divergence mismatch: have %v, want 0", divergences[0])
+	}
+	for i := 1; i < len(deltas); i++ {
+		if divergences[deltas[i]] <= divergences[deltas[i-1]] {
+			t.Errorf("divergence not growing with the perturbation: delta %v has %v, delta %v has %v",
+				deltas[i], divergences[deltas[i]], deltas[i-1], divergences[deltas[i-1]])
+		}
+	}
+	if _, err := SensitivityAnalysis(config, "unknown", deltas, headers); !errors.Is(err, ErrUnknownDifficultyParam) {
+		t.Fatalf("unknown parameter error mismatch: have %v, want %v", err, ErrUnknownDifficultyParam)
+	}
+}
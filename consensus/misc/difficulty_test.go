@@ -0,0 +1,550 @@
+package misc
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// testDifficultyConfig returns a difficulty configuration targeting 12 second
+// blocks with a minimum difficulty of 1000.
+func testDifficultyConfig() *DifficultyConfig {
+	return &DifficultyConfig{
+		DurationLimit: big.NewInt(12),
+		MinDifficulty: big.NewInt(1000),
+	}
+}
+
+// testParent creates a header with the given difficulty and timestamp.
+func testParent(difficulty int64, time uint64) *types.Header {
+	header := types.EmptyHeader()
+	header.SetDifficulty(big.NewInt(difficulty))
+	header.SetTime(time)
+	return header
+}
+
+func TestCalcDifficulty(t *testing.T) {
+	config := testDifficultyConfig()
+	tests := []struct {
+		difficulty int64
+		solveTime  uint64
+		want       int64
+	}{
+		{difficulty: 1000000, solveTime: 12, want: 1000000}, // on target
+		{difficulty: 1000000, solveTime: 2, want: 1001099},  // faster than target
+		{difficulty: 1000000, solveTime: 22, want: 998900},  // slower than target
+		{difficulty: 1000, solveTime: 1000, want: 1000},     // clamped to the minimum
+	}
+	for i, tt := range tests {
+		parent := testParent(tt.difficulty, 1000+tt.solveTime)
+		have := CalcDifficulty(config, 1000, parent)
+		if have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("test %d: difficulty mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestCalcDifficultyCheckedNilParent(t *testing.T) {
+	difficulty, err := CalcDifficultyChecked(testDifficultyConfig(), 0, nil)
+	if err != ErrNilParent {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrNilParent)
+	}
+	if difficulty != nil {
+		t.Fatalf("unexpected difficulty for nil parent: %v", difficulty)
+	}
+	difficulty, err = CalcDifficultyChecked(testDifficultyConfig(), 1000, testParent(1000000, 1012))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if difficulty.Cmp(big.NewInt(1000000)) != 0 {
+		t.Fatalf("difficulty mismatch: have %v, want %v", difficulty, 1000000)
+	}
+}
+
+func TestDifficultyClampCounters(t *testing.T) {
+	config := testDifficultyConfig()
+	config.BoundDivisor = big.NewInt(2048)
+	config.Counters = new(DifficultyCounters)
+
+	// An adjustment beyond parent/2048 hits the cap
+	if have, want := CalcDifficulty(config, 1000, testParent(1000000, 1002)), big.NewInt(1000488); have.Cmp(want) != 0 {
+		t.Fatalf("capped difficulty mismatch: have %v, want %v", have, want)
+	}
+	if have := config.Metrics(); have != (DifficultyMetrics{AdjustCapHits: 1}) {
+		t.Fatalf("metrics mismatch after cap: have %+v", have)
+	}
+	// A slow block at the minimum difficulty hits the minimum clamp
+	config.BoundDivisor = nil
+	if have := CalcDifficulty(config, 1000, testParent(1000, 2000)); have.Cmp(config.MinDifficulty) != 0 {
+		t.Fatalf("clamped difficulty mismatch: have %v, want %v", have, config.MinDifficulty)
+	}
+	if have := config.Metrics(); have != (DifficultyMetrics{MinClampHits: 1, AdjustCapHits: 1}) {
+		t.Fatalf("metrics mismatch after clamp: have %+v", have)
+	}
+	// An on target block hits neither
+	CalcDifficulty(config, 1000, testParent(1000000, 1012))
+	if have := config.Metrics(); have != (DifficultyMetrics{MinClampHits: 1, AdjustCapHits: 1}) {
+		t.Fatalf("metrics mismatch after unclamped block: have %+v", have)
+	}
+	// Disabled tracking always reports zero
+	config.Counters = nil
+	CalcDifficulty(config, 1000, testParent(1000, 2000))
+	if have := config.Metrics(); have != (DifficultyMetrics{}) {
+		t.Fatalf("metrics reported while disabled: have %+v", have)
+	}
+}
+
+func TestDifficultyClampSink(t *testing.T) {
+	var events []ClampEvent
+	config := testDifficultyConfig().WithClampSink(func(event ClampEvent) {
+		events = append(events, event)
+	})
+	// A slow block at the minimum difficulty hits the minimum clamp
+	result := calcDifficultyFromSolvetime(config, big.NewInt(1000), 41, 1000)
+	if !result.MinClamped {
+		t.Fatalf("difficulty not clamped: have %v", result.Difficulty)
+	}
+	if len(events) != 1 {
+		t.Fatalf("clamp events mismatch: have %d, want 1", len(events))
+	}
+	event := events[0]
+	if event.Number != 42 || event.Kind != ClampMinimum {
+		t.Fatalf("clamp event mismatch: have block %d, kind %v", event.Number, event.Kind)
+	}
+	if event.Before.Cmp(config.MinDifficulty) >= 0 || event.After.Cmp(config.MinDifficulty) != 0 {
+		t.Fatalf("clamp event values mismatch: have %v -> %v, want below %v -> %v", event.Before, event.After, config.MinDifficulty, config.MinDifficulty)
+	}
+	// An on target block reports nothing
+	calcDifficultyFromSolvetime(config, big.NewInt(1000000), 42, 12)
+	if len(events) != 1 {
+		t.Fatalf("clamp reported for unclamped block: have %d events", len(events))
+	}
+	// Clamps without a sink are not reported
+	if result := calcDifficultyFromSolvetime(testDifficultyConfig(), big.NewInt(1000), 41, 1000); !result.MinClamped || len(events) != 1 {
+		t.Fatalf("clamp without sink mismatch: clamped %v, %d events", result.MinClamped, len(events))
+	}
+}
+
+func TestDifficultyMaxClamp(t *testing.T) {
+	var events []ClampEvent
+	base := testDifficultyConfig().WithClampSink(func(event ClampEvent) {
+		events = append(events, event)
+	})
+	bombed := *base
+	bombed.Bomb = testBomb()
+
+	tests := []struct {
+		name   string
+		config *DifficultyConfig
+		number uint64
+	}{
+		{name: "fast block", config: base, number: 41},
+		{name: "bomb term", config: &bombed, number: 2999},
+	}
+	for _, tt := range tests {
+		uncapped := calcDifficultyFromSolvetime(tt.config, big.NewInt(1000000), tt.number, 1)
+		if tt.config.Bomb != nil && uncapped.BombTerm == nil {
+			t.Fatalf("%s: bomb not active", tt.name)
+		}
+		// A ceiling above the computed difficulty leaves it unchanged
+		above := new(big.Int).Add(uncapped.Difficulty, big.NewInt(1))
+		events = events[:0]
+		if result := calcDifficultyFromSolvetime(tt.config.WithMaxDifficulty(above), big.NewInt(1000000), tt.number, 1); result.MaxClamped || result.Difficulty.Cmp(uncapped.Difficulty) != 0 || len(events) != 0 {
+			t.Fatalf("%s: difficulty below ceiling altered: have %v (clamped %v), want %v", tt.name, result.Difficulty, result.MaxClamped, uncapped.Difficulty)
+		}
+		// A ceiling below the computed difficulty, bomb term included, caps it
+		below := new(big.Int).Sub(uncapped.Difficulty, big.NewInt(1))
+		result := calcDifficultyFromSolvetime(tt.config.WithMaxDifficulty(below), big.NewInt(1000000), tt.number, 1)
+		if !result.MaxClamped || result.Difficulty.Cmp(below) != 0 {
+			t.Fatalf("%s: difficulty above ceiling mismatch: have %v (clamped %v), want %v", tt.name, result.Difficulty, result.MaxClamped, below)
+		}
+		if len(events) != 1 || events[0].Kind != ClampMaximum || events[0].Before.Cmp(uncapped.Difficulty) != 0 || events[0].After.Cmp(below) != 0 {
+			t.Fatalf("%s: clamp events mismatch: have %v", tt.name, events)
+		}
+	}
+}
+
+func TestDifficultySeriesSink(t *testing.T) {
+	type sample struct {
+		number, time uint64
+		diff         *big.Int
+	}
+	var samples []sample
+	config := testDifficultyConfig().WithDifficultySeriesSink(func(number, time uint64, diff *big.Int) {
+		samples = append(samples, sample{number, time, diff})
+	})
+	var want []sample
+	for i, solvetime := range []uint64{2, 12, 40} {
+		parent := testParent(1000000, 1000+solvetime)
+		parent.SetNumber(big.NewInt(int64(i)))
+		want = append(want, sample{uint64(i) + 1, 1000 + solvetime, CalcDifficulty(config, 1000, parent)})
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("sample count mismatch: have %d, want %d", len(samples), len(want))
+	}
+	for i := range want {
+		if samples[i].number != want[i].number || samples[i].time != want[i].time || samples[i].diff.Cmp(want[i].diff) != 0 {
+			t.Errorf("sample %d mismatch: have %+v, want %+v", i, samples[i], want[i])
+		}
+	}
+	// Difficulties are not reported without a sink
+	CalcDifficulty(testDifficultyConfig(), 1000, testParent(1000000, 1012))
+	if len(samples) != len(want) {
+		t.Fatalf("difficulty reported without a sink")
+	}
+}
+
+func TestDifficultyEvaluator(t *testing.T) {
+	config := testDifficultyConfig()
+	config.Bomb = testBomb()
+	evaluate := DifficultyEvaluator(config)
+
+	for _, number := range []uint64{0, 500, 1500} {
+		for _, solvetime := range []uint64{1, 12, 40} {
+			parent := testParent(1000000, 1000+solvetime)
+			parent.SetNumber(new(big.Int).SetUint64(number))
+
+			want := CalcDifficulty(config, 1000, parent)
+			for _, uncled := range []bool{false, true} {
+				if have := evaluate(1000000, number, solvetime, uncled); have.Cmp(want) != 0 {
+					t.Errorf("parent %d, solvetime %d, uncled %v: difficulty mismatch: have %v, want %v", number, solvetime, uncled, have, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCalcDifficultyResult(t *testing.T) {
+	config := testDifficultyConfig()
+	config.BoundDivisor = big.NewInt(2048)
+
+	tests := []struct {
+		parent     *types.Header
+		difficulty int64
+		adjustment int64
+		capped     bool
+		clamped    bool
+	}{
+		{parent: testParent(1000000, 1012), difficulty: 1000000, adjustment: 0},
+		{parent: testParent(1000000, 1002), difficulty: 1000488, adjustment: 1099, capped: true},
+		{parent: testParent(500, 1012), difficulty: 1000, adjustment: 0, clamped: true},
+	}
+	for i, tt := range tests {
+		have := CalcDifficultyResult(config, 1000, tt.parent)
+		if have.Algorithm != DifficultyAlgoLog2 {
+			t.Errorf("test %d: algorithm mismatch: have %s, want %s", i, have.Algorithm, DifficultyAlgoLog2)
+		}
+		if have.Difficulty.Cmp(big.NewInt(tt.difficulty)) != 0 {
+			t.Errorf("test %d: difficulty mismatch: have %v, want %v", i, have.Difficulty, tt.difficulty)
+		}
+		if have.Adjustment.Cmp(big.NewInt(tt.adjustment)) != 0 {
+			t.Errorf("test %d: adjustment mismatch: have %v, want %v", i, have.Adjustment, tt.adjustment)
+		}
+		if have.AdjustCapped != tt.capped || have.MinClamped != tt.clamped {
+			t.Errorf("test %d: clamp flags mismatch: have capped=%v clamped=%v, want capped=%v clamped=%v",
+				i, have.AdjustCapped, have.MinClamped, tt.capped, tt.clamped)
+		}
+	}
+}
+
+func TestCalcDifficultyFromSolvetime(t *testing.T) {
+	config := testDifficultyConfig()
+	for _, difficulty := range []int64{1000, 123456, 1000000, 987654321} {
+		for _, solvetime := range []uint64{0, 1, 5, 12, 30, 600} {
+			want := CalcDifficulty(config, 1000, testParent(difficulty, 1000+solvetime))
+			have := CalcDifficultyFromSolvetime(config, big.NewInt(difficulty), 0, solvetime)
+			if have.Cmp(want) != 0 {
+				t.Errorf("difficulty %d, solvetime %d: mismatch: have %v, want %v", difficulty, solvetime, have, want)
+			}
+		}
+	}
+}
+
+func TestCalcDifficultyMinSolvetime(t *testing.T) {
+	config := testDifficultyConfig()
+	floored := config.WithMinSolvetime(4)
+	if config.MinSolvetime != 0 {
+		t.Fatalf("original config mutated")
+	}
+	parentDiff := big.NewInt(1000000)
+
+	unfloored := CalcDifficultyFromSolvetime(config, parentDiff, 0, 1)
+	have := CalcDifficultyFromSolvetime(floored, parentDiff, 0, 1)
+	if want := CalcDifficultyFromSolvetime(config, parentDiff, 0, 4); have.Cmp(want) != 0 {
+		t.Fatalf("floored difficulty mismatch: have %v, want %v", have, want)
+	}
+	if have.Cmp(unfloored) >= 0 {
+		t.Fatalf("floor did not damp the adjustment: floored %v, unfloored %v", have, unfloored)
+	}
+	// Solvetimes above the floor are unaffected
+	if have, want := CalcDifficultyFromSolvetime(floored, parentDiff, 0, 9), CalcDifficultyFromSolvetime(config, parentDiff, 0, 9); have.Cmp(want) != 0 {
+		t.Fatalf("difficulty above floor mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestDifficultyConfigJSON(t *testing.T) {
+	config := testDifficultyConfig().WithMinSolvetime(3)
+	config.BoundDivisor = big.NewInt(2048)
+	config.Counters = new(DifficultyCounters)
+
+	blob, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	var decoded DifficultyConfig
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if decoded.DurationLimit.Cmp(config.DurationLimit) != 0 ||
+		decoded.MinDifficulty.Cmp(config.MinDifficulty) != 0 ||
+		decoded.BoundDivisor.Cmp(config.BoundDivisor) != 0 ||
+		decoded.MinSolvetime != config.MinSolvetime {
+		t.Fatalf("round trip mismatch: have %s, want %+v", blob, config)
+	}
+	if decoded.Counters != nil {
+		t.Fatalf("runtime counters leaked into the encoding: %s", blob)
+	}
+	// The decoded config drives the algorithm identically
+	parent := testParent(1000000, 1002)
+	if have, want := CalcDifficulty(&decoded, 1000, parent), CalcDifficulty(config, 1000, parent); have.Cmp(want) != 0 {
+		t.Fatalf("decoded config difficulty mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestDifficultyOracle(t *testing.T) {
+	base := testDifficultyConfig()
+	config := base.WithDifficultyOracle(func(number uint64) (*big.Int, bool) {
+		switch number {
+		case 10:
+			return big.NewInt(5000000), true
+		case 20:
+			return big.NewInt(1), true
+		}
+		return nil, false
+	})
+	parentDiff := big.NewInt(1000000)
+
+	// Overridden blocks take the oracle's value, clamped to the minimum
+	result := calcDifficultyFromSolvetime(config, parentDiff, 9, 12)
+	if result.Algorithm != DifficultyAlgoOracle || result.Difficulty.Cmp(big.NewInt(5000000)) != 0 {
+		t.Fatalf("override mismatch: have %s %v, want %s 5000000", result.Algorithm, result.Difficulty, DifficultyAlgoOracle)
+	}
+	result = calcDifficultyFromSolvetime(config, parentDiff, 19, 12)
+	if !result.MinClamped || result.Difficulty.Cmp(base.MinDifficulty) != 0 {
+		t.Fatalf("clamped override mismatch: have %v, clamped %v", result.Difficulty, result.MinClamped)
+	}
+	// Other blocks pass through to the formula
+	for _, solvetime := range []uint64{2, 12, 30} {
+		have := CalcDifficultyFromSolvetime(config, parentDiff, 10, solvetime)
+		want := CalcDifficultyFromSolvetime(base, parentDiff, 10, solvetime)
+		if have.Cmp(want) != 0 {
+			t.Errorf("solvetime %d: pass through mismatch: have %v, want %v", solvetime, have, want)
+		}
+	}
+}
+
+func TestCalcDifficultyEmergencyAdjust(t *testing.T) {
+	base := testDifficultyConfig()
+	config := base.WithEmergencyAdjust(4, 6)
+	parentDiff := big.NewInt(1000000)
+
+	// Solvetimes up to the trigger adjust normally
+	for _, solvetime := range []uint64{2, 12, 72} {
+		result := calcDifficultyFromSolvetime(config, parentDiff, 0, solvetime)
+		if want := CalcDifficultyFromSolvetime(base, parentDiff, 0, solvetime); result.Emergency || result.Difficulty.Cmp(want) != 0 {
+			t.Errorf("solvetime %d: difficulty mismatch: have %v emergency=%v, want %v", solvetime, result.Difficulty, result.Emergency, want)
+		}
+	}
+	// Solvetimes past the trigger divide the difficulty
+	result := calcDifficultyFromSolvetime(config, parentDiff, 0, 73)
+	if !result.Emergency || result.Difficulty.Cmp(big.NewInt(250000)) != 0 {
+		t.Fatalf("emergency difficulty mismatch: have %v emergency=%v, want 250000", result.Difficulty, result.Emergency)
+	}
+	if result.Adjustment.Cmp(big.NewInt(-750000)) != 0 {
+		t.Fatalf("emergency adjustment mismatch: have %v, want -750000", result.Adjustment)
+	}
+	// The emergency drop is still clamped to the minimum
+	result = calcDifficultyFromSolvetime(config, big.NewInt(2000), 0, 1000)
+	if !result.Emergency || !result.MinClamped || result.Difficulty.Cmp(base.MinDifficulty) != 0 {
+		t.Fatalf("clamped emergency mismatch: have %v emergency=%v clamped=%v", result.Difficulty, result.Emergency, result.MinClamped)
+	}
+}
+
+func TestDevnetDifficultyConfig(t *testing.T) {
+	config := NewDevnetDifficultyConfig()
+	if config.Bomb != nil {
+		t.Fatalf("devnet preset has a difficulty bomb")
+	}
+	// Difficulty stays at the minimum however fast or slow blocks come
+	difficulty := new(big.Int).Set(config.MinDifficulty)
+	for number := uint64(0); number < 1000; number++ {
+		result := calcDifficultyFromSolvetime(config, difficulty, number, number%5)
+		if result.Difficulty.Cmp(big.NewInt(1)) != 0 {
+			t.Fatalf("block %d: difficulty mismatch: have %v, want 1", number+1, result.Difficulty)
+		}
+		if result.BombTerm != nil {
+			t.Fatalf("block %d: exponential term applied", number+1)
+		}
+		difficulty = result.Difficulty
+	}
+}
+
+func TestContextTargetTimes(t *testing.T) {
+	if _, err := testDifficultyConfig().WithContextTargetTimes([3]uint64{10, 20, 5}); err != ErrContextTargetTimes {
+		t.Fatalf("unordered target times error mismatch: have %v, want %v", err, ErrContextTargetTimes)
+	}
+	config, err := testDifficultyConfig().WithContextTargetTimes([3]uint64{60, 30, 10})
+	if err != nil {
+		t.Fatalf("failed to set context target times: %v", err)
+	}
+	// The same solvetime is slow for a zone, but fast for a region or prime
+	var (
+		parentDiff = big.NewInt(1000000)
+		prime      = CalcDifficultyFromSolvetime(config.ForContext(common.PRIME_CTX), parentDiff, 0, 20)
+		region     = CalcDifficultyFromSolvetime(config.ForContext(common.REGION_CTX), parentDiff, 0, 20)
+		zone       = CalcDifficultyFromSolvetime(config.ForContext(common.ZONE_CTX), parentDiff, 0, 20)
+	)
+	if zone.Cmp(parentDiff) >= 0 {
+		t.Errorf("zone difficulty not lowered: have %v, parent %v", zone, parentDiff)
+	}
+	if region.Cmp(parentDiff) <= 0 || prime.Cmp(region) <= 0 {
+		t.Errorf("difficulties not raised per target: prime %v, region %v, parent %v", prime, region, parentDiff)
+	}
+	// Zero entries stand for the DurationLimit, of 12 seconds
+	if _, err := testDifficultyConfig().WithContextTargetTimes([3]uint64{0, 0, 5}); err != nil {
+		t.Errorf("zone only override rejected: %v", err)
+	}
+	if _, err := testDifficultyConfig().WithContextTargetTimes([3]uint64{0, 0, 20}); err != ErrContextTargetTimes {
+		t.Errorf("zone override above the duration limit error mismatch: have %v, want %v", err, ErrContextTargetTimes)
+	}
+	// Unset contexts fall back to the DurationLimit
+	unset := testDifficultyConfig()
+	if have, want := CalcDifficultyFromSolvetime(unset.ForContext(common.ZONE_CTX), parentDiff, 0, 20), CalcDifficultyFromSolvetime(unset, parentDiff, 0, 20); have.Cmp(want) != 0 {
+		t.Errorf("unset context difficulty mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestRoundingMode(t *testing.T) {
+	config := testDifficultyConfig()
+	nearest := config.WithRoundingMode(RoundingNearest)
+	parentDiff := big.NewInt(1000000)
+
+	tests := []struct {
+		solvetime uint64
+		truncate  int64
+		nearest   int64
+	}{
+		{solvetime: 2, truncate: 1001099, nearest: 1001100},  // step 1099.54
+		{solvetime: 22, truncate: 998900, nearest: 998900},   // step -1099.54
+		{solvetime: 11, truncate: 1000109, nearest: 1000110}, // step 109.95
+		{solvetime: 12, truncate: 1000000, nearest: 1000000},
+	}
+	for _, tt := range tests {
+		if have := CalcDifficultyFromSolvetime(config, parentDiff, 0, tt.solvetime); have.Int64() != tt.truncate {
+			t.Errorf("solvetime %d: default difficulty mismatch: have %v, want %d", tt.solvetime, have, tt.truncate)
+		}
+		if have := CalcDifficultyFromSolvetime(config.WithRoundingMode(RoundingTruncate), parentDiff, 0, tt.solvetime); have.Int64() != tt.truncate {
+			t.Errorf("solvetime %d: truncated difficulty mismatch: have %v, want %d", tt.solvetime, have, tt.truncate)
+		}
+		if have := CalcDifficultyFromSolvetime(nearest, parentDiff, 0, tt.solvetime); have.Int64() != tt.nearest {
+			t.Errorf("solvetime %d: rounded difficulty mismatch: have %v, want %d", tt.solvetime, have, tt.nearest)
+		}
+	}
+}
+
+func TestDifficultyFreeze(t *testing.T) {
+	config := testDifficultyConfig()
+	config.Bomb = testBomb()
+	config = config.WithDifficultyFreeze(1000, 1009)
+	parentDiff := big.NewInt(1000000)
+
+	for parentNumber := uint64(997); parentNumber <= 1010; parentNumber++ {
+		result := calcDifficultyFromSolvetime(config, parentDiff, parentNumber, 2)
+		if number := parentNumber + 1; number >= 1000 && number <= 1009 {
+			if result.Difficulty.Cmp(parentDiff) != 0 || result.Algorithm != DifficultyAlgoFreeze || result.BombTerm != nil {
+				t.Errorf("block %d: frozen difficulty mismatch: have %v (%s), want %v", number, result.Difficulty, result.Algorithm, parentDiff)
+			}
+		} else if result.Difficulty.Cmp(parentDiff) <= 0 || result.Algorithm != DifficultyAlgoLog2 {
+			t.Errorf("block %d: difficulty not adjusted outside of the freeze: have %v (%s)", number, result.Difficulty, result.Algorithm)
+		}
+	}
+}
+
+func TestLaunchDecay(t *testing.T) {
+	config := testDifficultyConfig().WithLaunchDecay(big.NewInt(1000000), 100)
+
+	tests := []struct {
+		name         string
+		parentDiff   int64
+		parentNumber uint64
+		solvetime    uint64
+		want         int64
+	}{
+		// Slow blocks are held up by the decaying minimum
+		{name: "launch", parentDiff: 1000000, parentNumber: 0, solvetime: 1000, want: 990010},
+		{name: "mid-decay", parentDiff: 500500, parentNumber: 49, solvetime: 1000, want: 500500},
+		{name: "decay end", parentDiff: 1000, parentNumber: 98, solvetime: 1000, want: 10990},
+		{name: "decayed", parentDiff: 1000, parentNumber: 99, solvetime: 1000, want: 1000},
+		{name: "post-decay", parentDiff: 1000, parentNumber: 500, solvetime: 1000, want: 1000},
+		// Difficulties above the decaying minimum adjust normally
+		{name: "launch on target", parentDiff: 2000000, parentNumber: 0, solvetime: 12, want: 2000000},
+	}
+	for _, tt := range tests {
+		result := calcDifficultyFromSolvetime(config, big.NewInt(tt.parentDiff), tt.parentNumber, tt.solvetime)
+		if result.Difficulty.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("%s: difficulty mismatch: have %v, want %v", tt.name, result.Difficulty, tt.want)
+		}
+	}
+	// Without a decay the normal minimum applies from genesis
+	if have := CalcDifficultyFromSolvetime(testDifficultyConfig(), big.NewInt(1000000), 0, 1000); have.Cmp(big.NewInt(990010)) >= 0 {
+		t.Fatalf("undecayed launch difficulty held up: have %v", have)
+	}
+}
+
+func TestPrepareDifficulty(t *testing.T) {
+	config := testDifficultyConfig()
+	headers := testChain(config, []uint64{10, 3, 25})
+	genesis := headers[0].Hash()
+
+	tests := []struct {
+		name                string
+		parent, grandparent *types.Header
+		want                *big.Int
+	}{
+		{name: "genesis parent", parent: headers[0], want: headers[0].Difficulty()},
+		{name: "genesis grandparent", parent: headers[1], grandparent: headers[0], want: headers[1].Difficulty()},
+		{name: "unknown grandparent", parent: headers[2], want: headers[2].Difficulty()},
+		{name: "adjusted", parent: headers[2], grandparent: headers[1], want: CalcDifficulty(config, headers[1].Time(), headers[2])},
+		{name: "adjusted tip", parent: headers[3], grandparent: headers[2], want: CalcDifficulty(config, headers[2].Time(), headers[3])},
+		{name: "no parent", want: config.MinDifficulty},
+	}
+	for _, tt := range tests {
+		if have := PrepareDifficulty(config, tt.parent, tt.grandparent, genesis); have.Cmp(tt.want) != 0 {
+			t.Errorf("%s: difficulty mismatch: have %v, want %v", tt.name, have, tt.want)
+		}
+	}
+	// The prepared difficulties are the ones the chain was built with, from the
+	// children of the genesis onwards
+	for i := 0; i < len(headers)-1; i++ {
+		var grandparent *types.Header
+		if i > 0 {
+			grandparent = headers[i-1]
+		}
+		if have := PrepareDifficulty(config, headers[i], grandparent, genesis); have.Cmp(headers[i+1].Difficulty()) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %v", i+1, have, headers[i+1].Difficulty())
+		}
+	}
+	// The grandchild of the genesis is not adjusted, unlike any later block
+	if headers[2].Difficulty().Cmp(headers[1].Difficulty()) != 0 {
+		t.Errorf("genesis grandchild difficulty mismatch: have %v, want %v", headers[2].Difficulty(), headers[1].Difficulty())
+	}
+	if headers[3].Difficulty().Cmp(headers[2].Difficulty()) == 0 {
+		t.Errorf("block 3 difficulty not adjusted: %v", headers[3].Difficulty())
+	}
+	if PrepareDifficulty(config, nil, nil, genesis) == config.MinDifficulty {
+		t.Fatalf("minimum difficulty aliased")
+	}
+}
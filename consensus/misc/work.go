@@ -0,0 +1,70 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+var (
+	// ErrDifficultyMismatch is returned when a header's difficulty differs from
+	// the one the difficulty adjustment algorithm yields.
+	ErrDifficultyMismatch = errors.New("invalid difficulty")
+
+	// ErrGasLimitDelta is returned when a header's gas limit moved further from
+	// its parent's than the gas limit bound allows.
+	ErrGasLimitDelta = errors.New("gas limit delta out of bounds")
+
+	// ErrInsufficientChainwork is returned when a chain segment carries less
+	// cumulative work than required.
+	ErrInsufficientChainwork = errors.New("insufficient chainwork")
+)
+
+// WorkConfig holds the header work parameters checked by VerifyHeaderWork.
+type WorkConfig struct {
+	Difficulty *DifficultyConfig // Difficulty adjustment parameters
+
+	// GasLimitBoundDivisor optionally bounds the gas limit change of a block to
+	// below parent.GasLimit()/GasLimitBoundDivisor. Zero disables the check.
+	GasLimitBoundDivisor uint64
+}
+
+// VerifyHeaderWork checks the difficulty of header against the one computed
+// from its parent, and optionally its gas limit change, returning the first
+// violation. The time argument is the grandparent timestamp, the one of the
+// parent's own parent, from which CalcDifficulty derives the parent solvetime.
+func VerifyHeaderWork(config *WorkConfig, time uint64, parent, header *types.Header) error {
+	if parent == nil {
+		return ErrNilParent
+	}
+	if expected := CalcDifficulty(config.Difficulty, time, parent); header.Difficulty().Cmp(expected) != 0 {
+		return fmt.Errorf("%w: have %v, want %v", ErrDifficultyMismatch, header.Difficulty(), expected)
+	}
+	if config.GasLimitBoundDivisor != 0 {
+		delta := header.GasLimit() - parent.GasLimit()
+		if header.GasLimit() < parent.GasLimit() {
+			delta = parent.GasLimit() - header.GasLimit()
+		}
+		if limit := parent.GasLimit() / config.GasLimitBoundDivisor; delta >= limit {
+			return fmt.Errorf("%w: have %d, want %d += %d", ErrGasLimitDelta, header.GasLimit(), parent.GasLimit(), limit-1)
+		}
+	}
+	return nil
+}
+
+// VerifyMinChainwork checks that the cumulative difficulty of the headers, the
+// work a syncing node was presented with, reaches minWork. It guards sync
+// validity rather than the difficulty of individual blocks, which it does not
+// check.
+func VerifyMinChainwork(headers []*types.Header, minWork *big.Int) error {
+	work := new(big.Int)
+	for _, header := range headers {
+		work.Add(work, header.Difficulty())
+	}
+	if work.Cmp(minWork) < 0 {
+		return fmt.Errorf("%w: have %v, want %v", ErrInsufficientChainwork, work, minWork)
+	}
+	return nil
+}
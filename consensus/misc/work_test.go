@@ -0,0 +1,58 @@
+package misc
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestVerifyHeaderWork(t *testing.T) {
+	config := &WorkConfig{Difficulty: testDifficultyConfig(), GasLimitBoundDivisor: 1024}
+
+	parent := testParent(1000000, 1002)
+	parent.SetGasLimit(10240000)
+	header := testParent(1001099, 1010)
+	header.SetGasLimit(10249999)
+
+	if err := VerifyHeaderWork(config, 1000, parent, header); err != nil {
+		t.Fatalf("valid header rejected: %v", err)
+	}
+	// A wrong difficulty is rejected
+	header.SetDifficulty(header.Difficulty().Add(header.Difficulty(), big.NewInt(1)))
+	if err := VerifyHeaderWork(config, 1000, parent, header); !errors.Is(err, ErrDifficultyMismatch) {
+		t.Fatalf("wrong difficulty error mismatch: have %v, want %v", err, ErrDifficultyMismatch)
+	}
+	header.SetDifficulty(header.Difficulty().Sub(header.Difficulty(), big.NewInt(1)))
+
+	// Gas limit moves up to the bound are accepted, in both directions
+	header.SetGasLimit(10230001)
+	if err := VerifyHeaderWork(config, 1000, parent, header); err != nil {
+		t.Fatalf("bounded gas limit decrease rejected: %v", err)
+	}
+	header.SetGasLimit(10250000)
+	if err := VerifyHeaderWork(config, 1000, parent, header); !errors.Is(err, ErrGasLimitDelta) {
+		t.Fatalf("over delta gas limit error mismatch: have %v, want %v", err, ErrGasLimitDelta)
+	}
+	// The gas limit is not checked unless configured
+	config.GasLimitBoundDivisor = 0
+	if err := VerifyHeaderWork(config, 1000, parent, header); err != nil {
+		t.Fatalf("unchecked gas limit rejected: %v", err)
+	}
+}
+
+func TestVerifyMinChainwork(t *testing.T) {
+	headers := testChain(testDifficultyConfig(), []uint64{10, 3, 25, 12})
+	work := new(big.Int)
+	for _, header := range headers {
+		work.Add(work, header.Difficulty())
+	}
+	if err := VerifyMinChainwork(headers, work); err != nil {
+		t.Fatalf("chain meeting the minimum rejected: %v", err)
+	}
+	if err := VerifyMinChainwork(headers, new(big.Int).Add(work, big.NewInt(1))); !errors.Is(err, ErrInsufficientChainwork) {
+		t.Fatalf("chain short of the minimum error mismatch: have %v, want %v", err, ErrInsufficientChainwork)
+	}
+	if err := VerifyMinChainwork(nil, big.NewInt(1)); !errors.Is(err, ErrInsufficientChainwork) {
+		t.Fatalf("empty chain error mismatch: have %v, want %v", err, ErrInsufficientChainwork)
+	}
+}
@@ -2,6 +2,7 @@ package progpow
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -18,7 +19,6 @@ import (
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/trie"
-	"modernc.org/mathutil"
 )
 
 // Progpow proof-of-work protocol constants.
@@ -339,40 +339,37 @@ func (progpow *Progpow) CalcDifficulty(chain consensus.ChainHeaderReader, parent
 		return nil
 	}
 
-	///// Algorithm:
-	///// e = (DurationLimit - (parent.Time() - parentOfParent.Time())) * parent.Difficulty()
-	///// k = Floor(BinaryLog(parent.Difficulty()))/(DurationLimit*DifficultyAdjustmentFactor*AdjustmentPeriod)
-	///// Difficulty = Max(parent.Difficulty() + e * k, MinimumDifficulty)
-
-	if parent.Hash() == chain.Config().GenesisHash {
-		return parent.Difficulty()
+	if parent == nil {
+		log.Error("Cannot CalcDifficulty without a parent header")
+		return new(big.Int).Set(progpow.config.MinDifficulty)
 	}
-	parentOfParent := chain.GetHeaderByHash(parent.ParentHash())
-	if parentOfParent == nil || parentOfParent.Hash() == chain.Config().GenesisHash {
-		return parent.Difficulty()
+	var parentOfParent *types.Header
+	if parent.Hash() != chain.Config().GenesisHash {
+		parentOfParent = chain.GetHeaderByHash(parent.ParentHash())
 	}
+	return misc.PrepareDifficulty(progpow.difficultyConfig().ForContext(nodeCtx), parent, parentOfParent, chain.Config().GenesisHash)
+}
 
-	time := parent.Time()
-	bigTime := new(big.Int).SetUint64(time)
-	bigParentTime := new(big.Int).SetUint64(parentOfParent.Time())
+// difficultyConfig returns the difficulty adjustment parameters of the engine.
+func (progpow *Progpow) difficultyConfig() *misc.DifficultyConfig {
+	return &misc.DifficultyConfig{
+		DurationLimit:      progpow.config.DurationLimit,
+		MinDifficulty:      progpow.config.MinDifficulty,
+		ContextTargetTimes: progpow.config.ContextTargetTimes,
+		Counters:           progpow.difficultyCounters,
+	}
+}
 
-	// holds intermediate values to make the algo easier to read & audit
-	x := new(big.Int)
-	x.Sub(bigTime, bigParentTime)
-	x.Sub(progpow.config.DurationLimit, x)
-	x.Mul(x, parent.Difficulty())
-	k, _ := mathutil.BinaryLog(new(big.Int).Set(parent.Difficulty()), 64)
-	x.Mul(x, big.NewInt(int64(k)))
-	x.Div(x, progpow.config.DurationLimit)
-	x.Div(x, big.NewInt(params.DifficultyAdjustmentFactor))
-	x.Div(x, params.DifficultyAdjustmentPeriod)
-	x.Add(x, parent.Difficulty())
+// DifficultyParams returns the JSON encoding of the difficulty adjustment
+// parameters in effect, allowing operators to detect config drift across nodes.
+func (progpow *Progpow) DifficultyParams() ([]byte, error) {
+	return json.Marshal(progpow.difficultyConfig())
+}
 
-	// minimum difficulty can ever be (before exponential factor)
-	if x.Cmp(progpow.config.MinDifficulty) < 0 {
-		x.Set(progpow.config.MinDifficulty)
-	}
-	return x
+// DifficultyMetrics returns a snapshot of how often the difficulty adjustment
+// was clamped. The counters are only tracked when metrics are enabled.
+func (progpow *Progpow) DifficultyMetrics() misc.DifficultyMetrics {
+	return progpow.difficultyConfig().Metrics()
 }
 
 func (progpow *Progpow) IsDomCoincident(chain consensus.ChainHeaderReader, header *types.Header) bool {
@@ -453,7 +450,9 @@ func (progpow *Progpow) verifySeal(header *types.Header) (common.Hash, error) {
 // Prepare implements consensus.Engine, initializing the difficulty field of a
 // header to conform to the progpow protocol. The changes are done inline.
 func (progpow *Progpow) Prepare(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header) error {
-	header.SetDifficulty(progpow.CalcDifficulty(chain, parent))
+	difficulty := progpow.CalcDifficulty(chain, parent)
+	header.SetDifficulty(difficulty)
+	misc.LogDifficultyContext(progpow.config.Log, progpow.difficultyConfig().ForContext(common.NodeLocation.Context()), header.NumberU64(), difficulty)
 	return nil
 }
 
@@ -17,6 +17,7 @@ import (
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/hexutil"
 	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/metrics"
 	"github.com/dominant-strategies/go-quai/rpc"
@@ -160,6 +161,12 @@ type Config struct {
 	GasCeil        uint64
 	MinDifficulty  *big.Int
 
+	// ContextTargetTimes optionally overrides the DurationLimit per chain
+	// context, in seconds. Zero entries keep the DurationLimit. Difficulty is
+	// only computed in zone context, so only the zone entry takes effect, the
+	// others merely bounding it.
+	ContextTargetTimes [common.HierarchyDepth]uint64
+
 	// When set, notifications sent by the remote sealer will
 	// be block header JSON objects instead of work package arrays.
 	NotifyFull bool
@@ -180,6 +187,8 @@ type Progpow struct {
 	hashrate metrics.Meter // Meter tracking the average hashrate
 	remote   *remoteSealer
 
+	difficultyCounters *misc.DifficultyCounters // Difficulty clamp counters, nil if metrics are disabled
+
 	// The fields below are hooks for testing
 	shared    *Progpow      // Shared PoW verifier to avoid cache regeneration
 	fakeFail  uint64        // Block number which fails PoW check even in fake mode
@@ -196,6 +205,10 @@ func New(config Config, notify []string, noverify bool) *Progpow {
 	if config.Log == nil {
 		config.Log = &log.Log
 	}
+	if err := misc.ValidateContextTargetTimes(config.ContextTargetTimes, config.DurationLimit); err != nil {
+		config.Log.Error("Ignoring invalid context target times", "times", config.ContextTargetTimes, "err", err)
+		config.ContextTargetTimes = [common.HierarchyDepth]uint64{}
+	}
 	if config.CachesInMem <= 0 {
 		config.Log.Warn("One ethash cache must always be in memory", "requested", config.CachesInMem)
 		config.CachesInMem = 1
@@ -209,6 +222,9 @@ func New(config Config, notify []string, noverify bool) *Progpow {
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeterForced(),
 	}
+	if metrics.Enabled {
+		progpow.difficultyCounters = new(misc.DifficultyCounters)
+	}
 	if config.PowMode == ModeShared {
 		progpow.shared = sharedProgpow
 	}
@@ -29,6 +29,7 @@ import (
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/hexutil"
 	"github.com/dominant-strategies/go-quai/common/math"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
 	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
@@ -265,7 +266,13 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	head.SetNonce(types.EncodeNonce(g.Nonce))
 	head.SetTime(g.Timestamp)
 	head.SetExtra(g.ExtraData)
-	head.SetDifficulty(g.Difficulty)
+	// An explicit genesis difficulty is used as is, as clamping it would change
+	// the hash of existing genesis specs
+	if g.Difficulty != nil {
+		head.SetDifficulty(g.Difficulty)
+	} else {
+		head.SetDifficulty(misc.GenesisDifficulty(g.Config))
+	}
 	head.SetCoinbase(common.ZeroAddr)
 	head.SetGasLimit(g.GasLimit)
 	head.SetGasUsed(0)
@@ -112,9 +112,9 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllProgpowProtocolChanges = &ChainConfig{big.NewInt(1337), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation}
+	AllProgpowProtocolChanges = &ChainConfig{big.NewInt(1337), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation}
+	TestChainConfig = &ChainConfig{big.NewInt(1), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Hash{}, common.NodeLocation, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -131,6 +131,8 @@ type ChainConfig struct {
 	Progpow         *ProgpowConfig   `json:"progpow,omitempty"`
 	GenesisHash     common.Hash
 	Location        common.Location
+
+	GenesisDifficulty *big.Int `json:"genesisDifficulty,omitempty"` // Difficulty of the genesis block, at least MinimumDifficulty
 }
 
 // SetLocation sets the location on the chain config